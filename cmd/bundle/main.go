@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/bundle"
+	k8s_installer "github.com/Killer-Feature/PaaS_ClientSide/pkg/k8s-installer"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/distro"
+)
+
+// bundle builds offline bundle for nodes without access to the internet. It is run on machine of the same distro
+// as nodes with docker and kubeadm of the bundle minor version, path of the built bundle is set as
+// offlineBundle of cluster settings
+func main() {
+	out := flag.String("out", "bundle.tar.gz", "path of built bundle")
+	kubernetesVersion := flag.String("kubernetes-version", models.DefaultKubernetesVersion, "kubernetes version of cluster settings")
+	cni := flag.String("cni", string(models.DefaultCNI), "network plugin of cluster settings, flannel or calico")
+	kubeadm := flag.String("kubeadm", "kubeadm", "kubeadm binary listing control plane images")
+	flag.Parse()
+
+	data, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		log.Fatal(err)
+	}
+	release, err := distro.ParseOSRelease(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	d, err := distro.Detect(release)
+	if err != nil {
+		log.Fatal(err)
+	}
+	commandLib, err := distro.CommandLib(d)
+	if err != nil {
+		log.Fatal(err)
+	}
+	version, err := k8s_installer.ParseVersion(*kubernetesVersion)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	b := &bundle.Builder{
+		CommandLib:     commandLib,
+		Distro:         string(d),
+		MinorVersion:   version.MinorString(),
+		VersionPin:     version.PackagePin(),
+		CNI:            models.CNI(*cni),
+		CNIVersion:     k8s_installer.CNIVersion(models.CNI(*cni)),
+		CNIManifestURL: k8s_installer.CNIManifestURL(models.CNI(*cni)),
+		Charts:         k8s_installer.BundleCharts,
+		Kubeadm:        *kubeadm,
+		Log:            os.Stderr,
+	}
+	if err = b.Build(context.Background(), *out); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("bundle for %s is written to %s", d, *out)
+}
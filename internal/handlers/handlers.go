@@ -3,7 +3,10 @@ package handlers
 import (
 	"context"
 	"embed"
+	"errors"
 	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/distro"
 	"github.com/gorilla/websocket"
 	echo "github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -12,6 +15,7 @@ import (
 	"net"
 	"net/http"
 	"net/netip"
+	"strconv"
 	"time"
 )
 
@@ -59,20 +63,36 @@ func (h *Handler) Register(s *echo.Echo) {
 
 	s.POST("/api/addNode", h.AddNode, h.AuthMW)
 	s.POST("/api/addNodeToCluster", h.AddNodeToCluster, h.AuthMW)
+	s.POST("/api/provisionCluster", h.ProvisionCluster, h.AuthMW)
 
 	s.POST("/api/removeNode", h.RemoveNode, h.AuthMW)
 	s.POST("/api/removeNodeFromCluster", h.RemoveNodeFromCluster, h.AuthMW)
 
+	s.POST("/api/planAddNodeToCluster", h.PlanAddNodeToCluster, h.AuthMW)
+	s.POST("/api/planRemoveNodeFromCluster", h.PlanRemoveNodeFromCluster, h.AuthMW)
+
 	s.POST("/api/addResource", h.AddResource, h.AuthMW)
 	s.POST("/api/removeResource", h.RemoveResource, h.AuthMW)
 	s.GET("/api/getResources", h.GetResources, h.AuthMW)
 
 	s.GET("/api/getAdminConfig", h.GetAdminConfig, h.AuthMW)
 
+	s.GET("/api/getClusterSettings", h.GetClusterSettings, h.AuthMW)
+	s.POST("/api/setClusterSettings", h.SetClusterSettings, h.AuthMW)
+	s.GET("/api/kubeadmConfigs", h.GetKubeadmConfigs, h.AuthMW)
+	s.POST("/api/rotateJoinToken", h.RotateJoinToken, h.AuthMW)
+	s.POST("/api/upgradeCluster", h.UpgradeCluster, h.AuthMW)
+	s.POST("/api/destroyCluster", h.DestroyCluster, h.AuthMW)
+	s.POST("/api/installMonitoring", h.InstallMonitoring, h.AuthMW)
+
 	s.GET("/api/getServices", h.GetServices, h.AuthMW)
 
 	s.GET("/api/getProgress", h.GetProgress)
 
+	s.GET("/api/tasks", h.GetTasks, h.AuthMW)
+	s.GET("/api/tasks/:id", h.GetTask, h.AuthMW)
+	s.GET("/api/tasks/:id/log", h.DownloadTaskLog, h.AuthMW)
+
 	fsys, err := fs.Sub(ui, "dist")
 	if err != nil {
 		log.Fatal(err)
@@ -115,16 +135,24 @@ type InputNode struct {
 	Password string `json:"password"`
 }
 
+// AddNodeToClusterData is body of requests adding node to cluster, ControlPlane joins node as additional master
+type AddNodeToClusterData struct {
+	ID           int  `json:"id"`
+	ControlPlane bool `json:"controlPlane"`
+}
+
 // AddNodeToCluster adds new node to huginn database and checks is it available via ssh connecting
 func (h *Handler) AddNodeToCluster(ctx echo.Context) error {
-	nodeData := NodeID{}
+	nodeData := AddNodeToClusterData{}
 	if err := ctx.Bind(&nodeData); err != nil {
 		h.logger.Error("error occurred during parsing nodeData", zap.Error(err))
 		return ctx.NoContent(http.StatusInternalServerError)
 	}
 
-	nodeID, err := h.u.AddNodeToCurrentCluster(ctx.Request().Context(), nodeData.ID)
-
+	nodeID, err := h.u.AddNodeToCurrentCluster(ctx.Request().Context(), nodeData.ID, nodeData.ControlPlane)
+	if errors.Is(err, internal.ErrUpgradeInProgress) || errors.Is(err, internal.ErrDestroyInProgress) {
+		return ctx.HTML(http.StatusConflict, err.Error())
+	}
 	if err != nil {
 		return ctx.NoContent(http.StatusInternalServerError)
 	}
@@ -148,6 +176,9 @@ func (h *Handler) AddNode(ctx echo.Context) error {
 		Login:    nodeData.Login,
 		Password: nodeData.Password,
 	})
+	if errors.Is(err, distro.ErrUnsupportedDistro) {
+		return ctx.HTML(http.StatusBadRequest, err.Error())
+	}
 	if err != nil {
 		return ctx.NoContent(http.StatusInternalServerError)
 	}
@@ -158,6 +189,13 @@ type NodeID struct {
 	ID int `json:"id"`
 }
 
+// RemoveNodeFromClusterData is request to remove node from cluster, DestroyCluster confirms removal
+// of the last master together with all workers
+type RemoveNodeFromClusterData struct {
+	ID             int  `json:"id"`
+	DestroyCluster bool `json:"destroyCluster"`
+}
+
 func (h *Handler) RemoveNode(ctx echo.Context) error {
 	nodeData := NodeID{}
 	if err := ctx.Bind(&nodeData); err != nil {
@@ -172,20 +210,84 @@ func (h *Handler) RemoveNode(ctx echo.Context) error {
 }
 
 func (h *Handler) RemoveNodeFromCluster(ctx echo.Context) error {
-	nodeData := NodeID{}
+	nodeData := RemoveNodeFromClusterData{}
 	if err := ctx.Bind(&nodeData); err != nil {
 		h.logger.Error("error occurred during parsing nodeData", zap.Error(err))
 		return ctx.NoContent(http.StatusInternalServerError)
 	}
 
-	nodeID, err := h.u.RemoveNodeFromCurrentCluster(ctx.Request().Context(), nodeData.ID)
-
+	nodeID, err := h.u.RemoveNodeFromCurrentCluster(ctx.Request().Context(), nodeData.ID, nodeData.DestroyCluster)
+	if errors.Is(err, internal.ErrClusterHasWorkers) || errors.Is(err, internal.ErrUpgradeInProgress) || errors.Is(err, internal.ErrDestroyInProgress) {
+		return ctx.HTML(http.StatusConflict, err.Error())
+	}
 	if err != nil {
 		return ctx.NoContent(http.StatusInternalServerError)
 	}
 	return ctx.JSON(http.StatusOK, nodeID)
 }
 
+// ProvisionClusterData is request to add several nodes to cluster, MasterID is required when cluster doesn't exist.
+// Parallelism limits number of workers joining at once, default is used when it is 0
+type ProvisionClusterData struct {
+	MasterID    int   `json:"masterID"`
+	WorkerIDs   []int `json:"workerIDs"`
+	Parallelism int   `json:"parallelism"`
+}
+
+// ProvisionCluster bootstraps master and then joins workers concurrently, returns ids of node tasks in history
+func (h *Handler) ProvisionCluster(ctx echo.Context) error {
+	data := ProvisionClusterData{}
+	if err := ctx.Bind(&data); err != nil {
+		h.logger.Error("error occurred during parsing ProvisionClusterData", zap.Error(err))
+		return ctx.NoContent(http.StatusInternalServerError)
+	}
+
+	taskIDs, err := h.u.ProvisionCluster(ctx.Request().Context(), data.MasterID, data.WorkerIDs, data.Parallelism)
+	if errors.Is(err, internal.ErrInvalidProvision) {
+		return ctx.HTML(http.StatusBadRequest, err.Error())
+	}
+	if errors.Is(err, internal.ErrUpgradeInProgress) || errors.Is(err, internal.ErrDestroyInProgress) {
+		return ctx.HTML(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, taskIDs)
+}
+
+// PlanAddNodeToCluster returns commands and helm charts which adding node to cluster would run without running them
+func (h *Handler) PlanAddNodeToCluster(ctx echo.Context) error {
+	nodeData := AddNodeToClusterData{}
+	if err := ctx.Bind(&nodeData); err != nil {
+		h.logger.Error("error occurred during parsing nodeData", zap.Error(err))
+		return ctx.NoContent(http.StatusInternalServerError)
+	}
+
+	plan, err := h.u.PlanAddNodeToCurrentCluster(ctx.Request().Context(), nodeData.ID, nodeData.ControlPlane)
+	if err != nil {
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, plan)
+}
+
+// PlanRemoveNodeFromCluster returns commands which removing node from cluster would run without running them
+func (h *Handler) PlanRemoveNodeFromCluster(ctx echo.Context) error {
+	nodeData := RemoveNodeFromClusterData{}
+	if err := ctx.Bind(&nodeData); err != nil {
+		h.logger.Error("error occurred during parsing nodeData", zap.Error(err))
+		return ctx.NoContent(http.StatusInternalServerError)
+	}
+
+	plan, err := h.u.PlanRemoveNodeFromCurrentCluster(ctx.Request().Context(), nodeData.ID, nodeData.DestroyCluster)
+	if errors.Is(err, internal.ErrClusterHasWorkers) || errors.Is(err, internal.ErrUpgradeInProgress) || errors.Is(err, internal.ErrDestroyInProgress) {
+		return ctx.HTML(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, plan)
+}
+
 type ResourceData struct {
 	Type string `json:"type"`
 	Name string `json:"name"`
@@ -246,6 +348,113 @@ func (h *Handler) GetAdminConfig(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, conf)
 }
 
+func (h *Handler) GetClusterSettings(ctx echo.Context) error {
+	settings, err := h.u.GetClusterSettings(ctx.Request().Context(), 1)
+	if err != nil {
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) SetClusterSettings(ctx echo.Context) error {
+	settings := models.ClusterSettings{}
+	if err := ctx.Bind(&settings); err != nil {
+		h.logger.Error("error occurred during parsing ClusterSettings", zap.Error(err))
+		return ctx.NoContent(http.StatusInternalServerError)
+	}
+
+	err := h.u.SetClusterSettings(ctx.Request().Context(), 1, settings)
+	if errors.Is(err, internal.ErrInvalidClusterSettings) {
+		return ctx.HTML(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+// GetKubeadmConfigs returns kubeadm configs rendered while nodes were added to cluster, query param nodeID selects configs of the node
+func (h *Handler) GetKubeadmConfigs(ctx echo.Context) error {
+	nodeID := 0
+	if value := ctx.QueryParam("nodeID"); value != "" {
+		var err error
+		if nodeID, err = strconv.Atoi(value); err != nil {
+			return ctx.HTML(http.StatusBadRequest, "invalid nodeID: "+err.Error())
+		}
+	}
+
+	configs, err := h.u.GetKubeadmConfigs(ctx.Request().Context(), nodeID)
+	if err != nil {
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, configs)
+}
+
+// RotateJoinToken creates new join token on master, the previous one is deleted
+func (h *Handler) RotateJoinToken(ctx echo.Context) error {
+	err := h.u.RotateJoinToken(ctx.Request().Context(), 1)
+	if errors.Is(err, internal.ErrClusterNotExists) {
+		return ctx.HTML(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		h.logger.Error("error rotating join token", zap.Error(err))
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.NoContent(http.StatusOK)
+}
+
+type UpgradeClusterData struct {
+	Version string `json:"version"`
+}
+
+// UpgradeCluster queues upgrade of all cluster nodes to Kubernetes version and returns id of the task in history
+func (h *Handler) UpgradeCluster(ctx echo.Context) error {
+	data := UpgradeClusterData{}
+	if err := ctx.Bind(&data); err != nil {
+		h.logger.Error("error occurred during parsing UpgradeClusterData", zap.Error(err))
+		return ctx.NoContent(http.StatusInternalServerError)
+	}
+
+	taskID, err := h.u.UpgradeCluster(ctx.Request().Context(), 1, data.Version)
+	switch {
+	case errors.Is(err, internal.ErrInvalidUpgrade), errors.Is(err, internal.ErrClusterNotExists):
+		return ctx.HTML(http.StatusBadRequest, err.Error())
+	case errors.Is(err, internal.ErrUpgradeInProgress), errors.Is(err, internal.ErrDestroyInProgress), errors.Is(err, internal.ErrClusterBusy):
+		return ctx.HTML(http.StatusConflict, err.Error())
+	case err != nil:
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, taskID)
+}
+
+// DestroyCluster queues reset of all cluster nodes and removal of cluster state and returns id of the task in history
+func (h *Handler) DestroyCluster(ctx echo.Context) error {
+	taskID, err := h.u.DestroyCluster(ctx.Request().Context(), 1)
+	switch {
+	case errors.Is(err, internal.ErrClusterNotExists):
+		return ctx.HTML(http.StatusBadRequest, err.Error())
+	case errors.Is(err, internal.ErrUpgradeInProgress), errors.Is(err, internal.ErrDestroyInProgress), errors.Is(err, internal.ErrClusterBusy):
+		return ctx.HTML(http.StatusConflict, err.Error())
+	case err != nil:
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, taskID)
+}
+
+// InstallMonitoring queues installation of monitoring add-on configured by cluster settings and returns id of the task in history
+func (h *Handler) InstallMonitoring(ctx echo.Context) error {
+	taskID, err := h.u.InstallMonitoring(ctx.Request().Context(), 1)
+	switch {
+	case errors.Is(err, internal.ErrClusterNotExists):
+		return ctx.HTML(http.StatusBadRequest, err.Error())
+	case errors.Is(err, internal.ErrAddonInProgress), errors.Is(err, internal.ErrUpgradeInProgress), errors.Is(err, internal.ErrDestroyInProgress):
+		return ctx.HTML(http.StatusConflict, err.Error())
+	case err != nil:
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, taskID)
+}
+
 func (h *Handler) GetResources(ctx echo.Context) error {
 	resources, err := h.u.GetResources(ctx.Request().Context())
 	if err != nil {
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	echo "github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// GetTasks returns tasks history filtered by query params type, status, nodeID, clusterID, since (RFC3339) and limit
+func (h *Handler) GetTasks(ctx echo.Context) error {
+	filter := internal.TaskFilter{
+		Type:   internal.TaskType(ctx.QueryParam("type")),
+		Status: internal.TaskStatus(ctx.QueryParam("status")),
+	}
+
+	var err error
+	for param, target := range map[string]*int{"nodeID": &filter.NodeID, "clusterID": &filter.ClusterID, "limit": &filter.Limit} {
+		if value := ctx.QueryParam(param); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return ctx.HTML(http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", param, err.Error()))
+			}
+		}
+	}
+	if since := ctx.QueryParam("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return ctx.HTML(http.StatusBadRequest, "invalid since: "+err.Error())
+		}
+	}
+
+	tasks, err := h.u.GetTasks(ctx.Request().Context(), filter)
+	if err != nil {
+		h.logger.Error("error getting tasks", zap.Error(err))
+		return ctx.HTML(http.StatusInternalServerError, err.Error())
+	}
+	return ctx.JSON(http.StatusOK, tasks)
+}
+
+// GetTask returns task from history with its full log
+func (h *Handler) GetTask(ctx echo.Context) error {
+	task, err := h.getTask(ctx)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, task)
+}
+
+// DownloadTaskLog returns full log of the task as a file
+func (h *Handler) DownloadTaskLog(ctx echo.Context) error {
+	task, err := h.getTask(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"task-%d.log\"", task.ID))
+	return ctx.Blob(http.StatusOK, "text/plain; charset=utf-8", []byte(task.Log))
+}
+
+func (h *Handler) getTask(ctx echo.Context) (internal.Task, error) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return internal.Task{}, echo.NewHTTPError(http.StatusBadRequest, "invalid task id")
+	}
+
+	task, err := h.u.GetTask(ctx.Request().Context(), id)
+	if errors.Is(err, internal.ErrTaskNotFound) {
+		return internal.Task{}, echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		h.logger.Error("error getting task", zap.Error(err))
+		return internal.Task{}, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return task, nil
+}
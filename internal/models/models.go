@@ -9,3 +9,128 @@ type ResourceData struct {
 	Name   string `json:"name"`
 	Status string `json:"status"`
 }
+
+type CNI string
+
+const (
+	CNIFlannel CNI = "flannel"
+	CNICalico  CNI = "calico"
+	CNICilium  CNI = "cilium"
+)
+
+const (
+	DefaultKubernetesVersion = "1.30"
+	DefaultCRIOVersion       = "1.30"
+	DefaultCNI               = CNIFlannel
+	DefaultPodCIDR           = "10.244.0.0/16"
+	DefaultServiceCIDR       = "10.96.0.0/12"
+	DefaultCgroupDriver      = CgroupDriverSystemd
+	DefaultContainerRuntime  = ContainerRuntimeCRIO
+	DefaultDrainTimeout      = "5m"
+	DefaultMonitoringStorage = "/devkube/grafana"
+)
+
+type CgroupDriver string
+
+const (
+	CgroupDriverSystemd  CgroupDriver = "systemd"
+	CgroupDriverCgroupfs CgroupDriver = "cgroupfs"
+)
+
+type ContainerRuntime string
+
+const (
+	ContainerRuntimeCRIO       ContainerRuntime = "cri-o"
+	ContainerRuntimeContainerd ContainerRuntime = "containerd"
+)
+
+// ClusterSettings are chosen by user for the whole cluster and used while nodes are provisioned
+type ClusterSettings struct {
+	// KubernetesVersion is "<major>.<minor>" or "<major>.<minor>.<patch>", packages are pinned to it
+	KubernetesVersion string `json:"kubernetesVersion"`
+	// CRIOVersion is "<major>.<minor>" of CRI-O container runtime
+	CRIOVersion string `json:"crioVersion"`
+	// ContainerRuntime is installed on every node, it can't be changed after cluster creation.
+	// Containerd is installed from the distro repository, so CRIOVersion is used only for CRI-O
+	ContainerRuntime ContainerRuntime `json:"containerRuntime"`
+	// CNI is network plugin installed on control plane, it can't be changed after cluster creation
+	CNI         CNI    `json:"cni"`
+	PodCIDR     string `json:"podCIDR"`
+	ServiceCIDR string `json:"serviceCIDR"`
+	// ControlPlaneEndpoint is "<host>:<port>" of load balancer or DNS name in front of all masters.
+	// It is required for highly available clusters with several masters and can't be changed after cluster creation
+	ControlPlaneEndpoint string `json:"controlPlaneEndpoint"`
+	// APIServerCertSANs are additional IPs and DNS names of kube-apiserver serving certificate
+	APIServerCertSANs []string `json:"apiServerCertSANs,omitempty"`
+	// FeatureGates are set for kube-apiserver, kube-controller-manager, kube-scheduler and kubelet
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+	// CgroupDriver is used by kubelet and container runtime, it can't be changed after cluster creation
+	CgroupDriver CgroupDriver `json:"cgroupDriver"`
+	// KubeletMaxPods limits pods on every node, kubelet default is used when it is 0
+	KubeletMaxPods int `json:"kubeletMaxPods,omitempty"`
+	// DrainTimeout is duration like "5m" which node drain waits for evicted pods before it fails
+	DrainTimeout string `json:"drainTimeout"`
+	// Monitoring configures Grafana add-on
+	Monitoring MonitoringSettings `json:"monitoring"`
+	// OfflineBundle is path of bundle archive on the server. When it is set, nodes are installed from the bundle
+	// without access to the internet: packages, images and network plugin are uploaded to them and charts are
+	// installed from its archives
+	OfflineBundle string `json:"offlineBundle,omitempty"`
+}
+
+// MonitoringSettings configure Grafana add-on which can be installed with cluster or later
+type MonitoringSettings struct {
+	// Enabled installs monitoring right after cluster creation
+	Enabled bool `json:"enabled"`
+	// IngressHost is host of Grafana ingress, "grafana.<BaseDomain>" is used when it is empty.
+	// Ingress isn't created when both are empty
+	IngressHost string `json:"ingressHost,omitempty"`
+	BaseDomain  string `json:"baseDomain,omitempty"`
+	// StoragePath is directory of local persistent volume of Grafana
+	StoragePath string `json:"storagePath"`
+	// StorageNodeID is node where persistent volume is stored, the first master is used when it is 0
+	StorageNodeID int `json:"storageNodeID,omitempty"`
+}
+
+// GrafanaHost returns host of Grafana ingress, it is empty when ingress isn't created
+func (m MonitoringSettings) GrafanaHost() string {
+	if m.IngressHost != "" {
+		return m.IngressHost
+	}
+	if m.BaseDomain != "" {
+		return "grafana." + m.BaseDomain
+	}
+	return ""
+}
+
+// WithDefaults returns settings where empty fields are set to default values
+func (s ClusterSettings) WithDefaults() ClusterSettings {
+	if s.KubernetesVersion == "" {
+		s.KubernetesVersion = DefaultKubernetesVersion
+	}
+	if s.CRIOVersion == "" {
+		s.CRIOVersion = DefaultCRIOVersion
+	}
+	if s.CNI == "" {
+		s.CNI = DefaultCNI
+	}
+	if s.PodCIDR == "" {
+		s.PodCIDR = DefaultPodCIDR
+	}
+	if s.ServiceCIDR == "" {
+		s.ServiceCIDR = DefaultServiceCIDR
+	}
+	if s.CgroupDriver == "" {
+		s.CgroupDriver = DefaultCgroupDriver
+	}
+	if s.ContainerRuntime == "" {
+		s.ContainerRuntime = DefaultContainerRuntime
+	}
+	if s.DrainTimeout == "" {
+		s.DrainTimeout = DefaultDrainTimeout
+	}
+	if s.Monitoring.StoragePath == "" {
+		s.Monitoring.StoragePath = DefaultMonitoringStorage
+	}
+	return s
+}
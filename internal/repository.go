@@ -15,17 +15,34 @@ type Repository interface {
 	IsNodeExists(ctx context.Context, ip netip.Addr) (int, error)
 	SetNodeClusterID(ctx context.Context, id int, clusterID int) error
 	ResetNodeCluster(ctx context.Context, id int) error
+	SetNodeMaster(ctx context.Context, id int, isMaster bool) error
+	GetControlPlaneNodes(ctx context.Context, clusterID int) ([]FullNode, error)
 
 	AddResource(ctx context.Context, rType, name string) error
 	GetResources(ctx context.Context) ([]models.ResourceData, error)
+	DeleteResources(ctx context.Context) error
 
 	AddCluster(ctx context.Context, clusterName string) (int, error)
 	GetClusterID(ctx context.Context, clusterName string) (int, error)
 	GetClusterName(ctx context.Context, id int) (string, error)
-	AddClusterTokenIPAndHash(ctx context.Context, clusterID int, token, masterIP, hash string) error
+	AddClusterTokenIPAndHash(ctx context.Context, clusterID, masterID int, token, masterIP, hash string) error
+	UpdateClusterTokenAndHash(ctx context.Context, clusterID int, token, hash string) error
 	CheckClusterTokenIPAndHash(ctx context.Context, clusterID int) (bool, error)
 	GetClusterTokenIPAndHash(ctx context.Context, clusterID int) (token, masterIP, hash string, err error)
 	DeleteClusterTokenIPAndHash(ctx context.Context, clusterID int) (err error)
+	SetClusterCertificateKey(ctx context.Context, clusterID int, key string) error
+	GetClusterCertificateKey(ctx context.Context, clusterID int) (string, error)
+	GetClusterSettings(ctx context.Context, clusterID int) (models.ClusterSettings, error)
+	SetClusterSettings(ctx context.Context, clusterID int, settings models.ClusterSettings) error
+
+	AddTask(ctx context.Context, task Task) (int, error)
+	StartTask(ctx context.Context, id int) error
+	AppendTaskLog(ctx context.Context, id int, log string) error
+	FinishTask(ctx context.Context, id int, status TaskStatus, taskErr string) error
+	GetTasks(ctx context.Context, filter TaskFilter) ([]Task, error)
+	GetTask(ctx context.Context, id int) (Task, error)
+	AddKubeadmConfig(ctx context.Context, config KubeadmConfig) (int, error)
+	GetKubeadmConfigs(ctx context.Context, nodeID int) ([]KubeadmConfig, error)
 
 	AddAdmin(ctx context.Context, user, password string) error
 	ExistSession(ctx context.Context, session string) (bool, error)
@@ -41,7 +58,10 @@ type FullNode struct {
 	Login     string
 	Password  string
 	ClusterID int
-	IsMaster  bool
+	// IsMaster is true for every control-plane member of the cluster
+	IsMaster bool
+	// OS is distro detected from /etc/os-release when the node is registered, it chooses command library of the node
+	OS string
 }
 
 type Session struct {
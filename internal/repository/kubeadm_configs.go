@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+)
+
+func (r *Repository) AddKubeadmConfig(ctx context.Context, config internal.KubeadmConfig) (int, error) {
+	sqlScript := "INSERT INTO kubeadm_configs(node_id, cluster_id, kind, config, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
+	var id int
+	err := r.db.QueryRowContext(ctx, sqlScript, config.NodeID, config.ClusterID, config.Kind, config.Config, time.Now()).Scan(&id)
+	if err != nil {
+		r.l.Error("error during adding kubeadm config to database", zap.Error(err))
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetKubeadmConfigs returns configs rendered for the node starting from the latest one, configs of all nodes are returned when nodeID is 0
+func (r *Repository) GetKubeadmConfigs(ctx context.Context, nodeID int) ([]internal.KubeadmConfig, error) {
+	sqlScript := "SELECT id, node_id, cluster_id, kind, config, created_at FROM kubeadm_configs WHERE $1 = 0 OR node_id = $1 ORDER BY id DESC"
+
+	rows, err := r.db.QueryContext(ctx, sqlScript, nodeID)
+	if err != nil {
+		r.l.Error("error in db query during getting kubeadm configs", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	configs := make([]internal.KubeadmConfig, 0)
+	for rows.Next() {
+		var config internal.KubeadmConfig
+		if err = rows.Scan(&config.ID, &config.NodeID, &config.ClusterID, &config.Kind, &config.Config, &config.CreatedAt); err != nil {
+			r.l.Error("error during scanning kubeadm config from database", zap.Error(err))
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
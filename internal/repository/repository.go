@@ -3,10 +3,13 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/netip"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
 
@@ -54,6 +57,17 @@ func Create(l *zap.Logger) (internal.Repository, error) {
 		l.Error("error occurred during execution cluster table creating statement", zap.Error(err))
 		return nil, err
 	}
+	err = addColumnIfNotExists(db, "clusters", "settings", "TEXT DEFAULT '{}'")
+	if err != nil {
+		l.Error("error occurred during adding settings column to cluster table", zap.Error(err))
+		return nil, err
+	}
+	err = addColumnIfNotExists(db, "clusters", "certificate_key", "TEXT")
+	if err != nil {
+		l.Error("error occurred during adding certificate_key column to cluster table", zap.Error(err))
+		return nil, err
+	}
+
 	createNodesTableSQL := `CREATE TABLE IF NOT EXISTS nodes (
 		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
 		"name" TEXT,
@@ -75,6 +89,11 @@ func Create(l *zap.Logger) (internal.Repository, error) {
 		l.Error("error occurred during execution table creating statement", zap.Error(err))
 		return nil, err
 	}
+	err = addColumnIfNotExists(db, "nodes", "os", "TEXT DEFAULT ''")
+	if err != nil {
+		l.Error("error occurred during adding os column to nodes table", zap.Error(err))
+		return nil, err
+	}
 
 	createResourcesTableSQL := `CREATE TABLE IF NOT EXISTS resources (
 		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
@@ -121,6 +140,55 @@ func Create(l *zap.Logger) (internal.Repository, error) {
 		return nil, err
 	}
 
+	createTasksTableSQL := `CREATE TABLE IF NOT EXISTS tasks (
+		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"type" TEXT,
+		"node_id" integer,
+		"cluster_id" integer,
+		"status" TEXT,
+		"error" TEXT DEFAULT '',
+		"log" TEXT DEFAULT '',
+		"created_at" DATETIME,
+		"started_at" DATETIME,
+		"finished_at" DATETIME
+	  );`
+
+	statement, err = db.Prepare(createTasksTableSQL)
+	if err != nil {
+		l.Error("error occurred during preparing table creating statement", zap.Error(err))
+	}
+	_, err = statement.Exec()
+	if err != nil {
+		l.Error("error occurred during execution table creating statement", zap.Error(err))
+		return nil, err
+	}
+
+	createKubeadmConfigsTableSQL := `CREATE TABLE IF NOT EXISTS kubeadm_configs (
+		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"node_id" integer,
+		"cluster_id" integer,
+		"kind" TEXT,
+		"config" TEXT,
+		"created_at" DATETIME
+	  );`
+
+	statement, err = db.Prepare(createKubeadmConfigsTableSQL)
+	if err != nil {
+		l.Error("error occurred during preparing table creating statement", zap.Error(err))
+	}
+	_, err = statement.Exec()
+	if err != nil {
+		l.Error("error occurred during execution table creating statement", zap.Error(err))
+		return nil, err
+	}
+
+	// tasks which were running when the server stopped will never finish
+	_, err = db.Exec("UPDATE tasks SET status = $1, error = $2, finished_at = $3 WHERE finished_at IS NULL", internal.STATUS_ERROR, errTaskInterrupted, time.Now())
+	if err != nil {
+		l.Error("error occurred during marking interrupted tasks", zap.Error(err))
+		return nil, err
+	}
+
 	l.Debug("repository created")
 
 	r := &Repository{
@@ -131,8 +199,20 @@ func Create(l *zap.Logger) (internal.Repository, error) {
 	return r, nil
 }
 
+// addColumnIfNotExists migrates tables of databases which were created by previous versions
+func addColumnIfNotExists(db *sql.DB, table, column, definition string) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM pragma_table_info($1) WHERE name = $2)", table, column).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func (r *Repository) GetNodes(ctx context.Context) ([]internal.FullNode, error) {
-	sqlScript := "SELECT id, name, ip_port, login, password, cluster_id, is_master FROM nodes;"
+	sqlScript := "SELECT id, name, ip_port, login, password, cluster_id, is_master, os FROM nodes;"
 
 	rows, err := r.db.QueryContext(ctx, sqlScript)
 	if err != nil {
@@ -147,11 +227,13 @@ func (r *Repository) GetNodes(ctx context.Context) ([]internal.FullNode, error)
 		var ip string
 		var clusterId sql.NullString
 		var isMaster sql.NullBool
-		if err = rows.Scan(&singleNode.ID, &singleNode.Name, &ip, &singleNode.Login, &singleNode.Password, &clusterId, &isMaster); err != nil {
+		var osName sql.NullString
+		if err = rows.Scan(&singleNode.ID, &singleNode.Name, &ip, &singleNode.Login, &singleNode.Password, &clusterId, &isMaster, &osName); err != nil {
 			r.l.Error("error during scanning node from database", zap.Error(err))
 			return nil, err
 		}
 		singleNode.IsMaster = isMaster.Bool
+		singleNode.OS = osName.String
 		singleNode.IP, err = netip.ParseAddrPort(ip)
 		singleNode.ClusterID, _ = strconv.Atoi(clusterId.String)
 		if err != nil {
@@ -164,19 +246,21 @@ func (r *Repository) GetNodes(ctx context.Context) ([]internal.FullNode, error)
 }
 
 func (r *Repository) GetFullNode(ctx context.Context, id int) (internal.FullNode, error) {
-	sqlScript := "SELECT id, name, ip_port, login, password, cluster_id, is_master FROM nodes WHERE id = $1"
+	sqlScript := "SELECT id, name, ip_port, login, password, cluster_id, is_master, os FROM nodes WHERE id = $1"
 
 	var singleNode internal.FullNode
 	var ip string
 	var clusterId sql.NullString
 	var isMaster sql.NullBool
-	err := r.db.QueryRowContext(ctx, sqlScript, id).Scan(&singleNode.ID, &singleNode.Name, &ip, &singleNode.Login, &singleNode.Password, &clusterId, &isMaster)
+	var osName sql.NullString
+	err := r.db.QueryRowContext(ctx, sqlScript, id).Scan(&singleNode.ID, &singleNode.Name, &ip, &singleNode.Login, &singleNode.Password, &clusterId, &isMaster, &osName)
 	if err != nil {
 		r.l.Error("error in db query during getting nodes", zap.Error(err))
 		return internal.FullNode{}, err
 	}
 	singleNode.IP, err = netip.ParseAddrPort(ip)
 	singleNode.IsMaster = isMaster.Bool
+	singleNode.OS = osName.String
 	singleNode.ClusterID, _ = strconv.Atoi(clusterId.String)
 	if err != nil {
 		r.l.Error("error during parsing ip from database", zap.Error(err))
@@ -191,8 +275,8 @@ type Repository struct {
 }
 
 func (r *Repository) AddNode(ctx context.Context, node internal.FullNode) (int, error) {
-	sqlScript := "INSERT INTO nodes(name, ip_port, login, password, ip) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
-	err := r.db.QueryRowContext(ctx, sqlScript, node.Name, node.IP.String(), node.Login, node.Password, node.IP.Addr().String()).Scan(&node.ID)
+	sqlScript := "INSERT INTO nodes(name, ip_port, login, password, ip, os) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;"
+	err := r.db.QueryRowContext(ctx, sqlScript, node.Name, node.IP.String(), node.Login, node.Password, node.IP.Addr().String(), node.OS).Scan(&node.ID)
 	if err != nil {
 		r.l.Error("error during adding node to database", zap.Error(err))
 		return 0, err
@@ -224,6 +308,54 @@ func (r *Repository) ResetNodeCluster(ctx context.Context, id int) error {
 	return nil
 }
 
+// SetNodeMaster sets control-plane membership of the node
+func (r *Repository) SetNodeMaster(ctx context.Context, id int, isMaster bool) error {
+	sqlScript := "UPDATE nodes SET is_master = $1 WHERE id = $2"
+	_, err := r.db.ExecContext(ctx, sqlScript, isMaster, id)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetControlPlaneNodes returns control-plane members of the cluster, the master which created cluster is the first one
+func (r *Repository) GetControlPlaneNodes(ctx context.Context, clusterID int) ([]internal.FullNode, error) {
+	sqlScript := `SELECT n.id, n.name, n.ip_port, n.login, n.password, n.cluster_id, n.is_master, n.os FROM nodes n
+		LEFT JOIN clusters c ON c.id = $1
+		WHERE n.is_master AND (n.cluster_id = $1 OR n.id = c.master_id)
+		ORDER BY n.id = c.master_id DESC, n.id`
+
+	rows, err := r.db.QueryContext(ctx, sqlScript, clusterID)
+	if err != nil {
+		r.l.Error("error in db query during getting control plane nodes", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var selectedNodes []internal.FullNode
+	for rows.Next() {
+		var singleNode internal.FullNode
+		var ip string
+		var nodeClusterID sql.NullInt64
+		var isMaster sql.NullBool
+		var osName sql.NullString
+		if err = rows.Scan(&singleNode.ID, &singleNode.Name, &ip, &singleNode.Login, &singleNode.Password, &nodeClusterID, &isMaster, &osName); err != nil {
+			r.l.Error("error during scanning node from database", zap.Error(err))
+			return nil, err
+		}
+		singleNode.IsMaster = isMaster.Bool
+		singleNode.OS = osName.String
+		singleNode.ClusterID = int(nodeClusterID.Int64)
+		singleNode.IP, err = netip.ParseAddrPort(ip)
+		if err != nil {
+			r.l.Error("error during parsing ip from database", zap.Error(err))
+			return nil, err
+		}
+		selectedNodes = append(selectedNodes, singleNode)
+	}
+	return selectedNodes, nil
+}
+
 func (r *Repository) IsNodeExists(ctx context.Context, ip netip.Addr) (int, error) {
 	sqlScript := "SELECT id FROM nodes WHERE ip=$1"
 	rows, err := r.db.QueryContext(ctx, sqlScript, ip.String())
@@ -341,17 +473,16 @@ func (r *Repository) GetClusterName(ctx context.Context, id int) (string, error)
 //	return selectedNodes, nil
 //}
 
-func (r *Repository) AddClusterTokenIPAndHash(ctx context.Context, clusterID int, token, masterIP, hash string) error {
-	masterID := 0
-	sqlScript := "UPDATE nodes SET is_master = $1 WHERE ip = $2 RETURNING id"
-	masterAddrPort, _ := netip.ParseAddrPort(masterIP)
-	err := r.db.QueryRowContext(ctx, sqlScript, true, masterAddrPort.Addr().String()).Scan(&masterID)
+// AddClusterTokenIPAndHash saves join data of the cluster created on master node.
+// masterIP is host and port of the control plane which nodes join, it is the control-plane endpoint of HA clusters
+func (r *Repository) AddClusterTokenIPAndHash(ctx context.Context, clusterID, masterID int, token, masterIP, hash string) error {
+	err := r.SetNodeMaster(ctx, masterID, true)
 	if err != nil {
 		r.l.Error("error during add cluster master to database", zap.Error(err))
 		return err
 	}
 
-	sqlScript = "UPDATE clusters SET token = $1, hash = $2, master_ip=$3, master_id=$4 WHERE id = $5"
+	sqlScript := "UPDATE clusters SET token = $1, hash = $2, master_ip=$3, master_id=$4 WHERE id = $5"
 	_, err = r.db.ExecContext(ctx, sqlScript, token, hash, masterIP, masterID, clusterID)
 	if err != nil {
 		r.l.Error("error during add cluster master to database", zap.Error(err))
@@ -360,6 +491,60 @@ func (r *Repository) AddClusterTokenIPAndHash(ctx context.Context, clusterID int
 	return nil
 }
 
+func (r *Repository) SetClusterCertificateKey(ctx context.Context, clusterID int, key string) error {
+	sqlScript := "UPDATE clusters SET certificate_key = $1 WHERE id = $2"
+	_, err := r.db.ExecContext(ctx, sqlScript, key, clusterID)
+	if err != nil {
+		r.l.Error("error during setting cluster certificate key in database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetClusterCertificateKey(ctx context.Context, clusterID int) (string, error) {
+	sqlScript := "SELECT certificate_key FROM clusters WHERE id = $1"
+	var key sql.NullString
+	err := r.db.QueryRowContext(ctx, sqlScript, clusterID).Scan(&key)
+	if err != nil {
+		r.l.Error("error during getting cluster certificate key from database", zap.Error(err))
+		return "", err
+	}
+	return key.String, nil
+}
+
+func (r *Repository) GetClusterSettings(ctx context.Context, clusterID int) (models.ClusterSettings, error) {
+	sqlScript := "SELECT settings FROM clusters WHERE id = $1"
+	var rawSettings sql.NullString
+	err := r.db.QueryRowContext(ctx, sqlScript, clusterID).Scan(&rawSettings)
+	if err != nil {
+		r.l.Error("error during getting cluster settings from database", zap.Error(err))
+		return models.ClusterSettings{}, err
+	}
+
+	var settings models.ClusterSettings
+	if rawSettings.Valid && rawSettings.String != "" {
+		if err = json.Unmarshal([]byte(rawSettings.String), &settings); err != nil {
+			r.l.Error("error during parsing cluster settings from database", zap.Error(err))
+			return models.ClusterSettings{}, err
+		}
+	}
+	return settings, nil
+}
+
+func (r *Repository) SetClusterSettings(ctx context.Context, clusterID int, settings models.ClusterSettings) error {
+	rawSettings, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	sqlScript := "UPDATE clusters SET settings = $1 WHERE id = $2"
+	_, err = r.db.ExecContext(ctx, sqlScript, string(rawSettings), clusterID)
+	if err != nil {
+		r.l.Error("error during setting cluster settings in database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 func (r *Repository) CheckClusterTokenIPAndHash(ctx context.Context, clusterID int) (bool, error) {
 	token, masterIP, hash, err := r.GetClusterTokenIPAndHash(ctx, clusterID)
 	if err != nil {
@@ -387,8 +572,19 @@ func (r *Repository) GetClusterTokenIPAndHash(ctx context.Context, clusterID int
 	return
 }
 
+// UpdateClusterTokenAndHash replaces join token and CA cert hash of existing cluster
+func (r *Repository) UpdateClusterTokenAndHash(ctx context.Context, clusterID int, token, hash string) error {
+	sqlScript := "UPDATE clusters SET token = $1, hash = $2 WHERE id = $3"
+	_, err := r.db.ExecContext(ctx, sqlScript, token, hash, clusterID)
+	if err != nil {
+		r.l.Error("error during updating cluster token in database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 func (r *Repository) DeleteClusterTokenIPAndHash(ctx context.Context, clusterID int) (err error) {
-	sqlScript := `UPDATE clusters SET token = "", hash = "", master_ip="", master_id=0 WHERE id = $1`
+	sqlScript := `UPDATE clusters SET token = "", hash = "", master_ip="", master_id=0, certificate_key="" WHERE id = $1`
 	_, err = r.db.ExecContext(ctx, sqlScript, clusterID)
 	return
 }
@@ -426,6 +622,16 @@ func (r *Repository) AddResource(ctx context.Context, rType, name string) error
 	return nil
 }
 
+func (r *Repository) DeleteResources(ctx context.Context) error {
+	sqlScript := "DELETE FROM resources;"
+	_, err := r.db.ExecContext(ctx, sqlScript)
+	if err != nil {
+		r.l.Error("error during deleting resources from database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
 func (r *Repository) ExistSession(ctx context.Context, session string) (bool, error) {
 	sqlScript := "SELECT EXISTS(SELECT 1 FROM sessions WHERE session = $1)"
 	exist := false
@@ -1,7 +1,61 @@
 package repository
 
-import "testing"
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+)
 
 func TestCreate(t *testing.T) {
-	Create()
+	Create(zap.NewNop())
+}
+
+func TestTaskHistory(t *testing.T) {
+	r, err := Create(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	id, err := r.AddTask(ctx, internal.Task{Type: internal.TASK_ADD_NODE_TO_CLUSTER, NodeID: 42, ClusterID: 1, Status: internal.STATUS_IN_QUEUE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.StartTask(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	for _, delta := range []string{"$ sudo apt update\n", "done\n"} {
+		if err = r.AppendTaskLog(ctx, id, delta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = r.FinishTask(ctx, id, internal.STATUS_ERROR, "exec failed"); err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := r.GetTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Log != "$ sudo apt update\ndone\n" || task.Status != internal.STATUS_ERROR || task.Error != "exec failed" {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if task.StartedAt == nil || task.FinishedAt == nil {
+		t.Errorf("task times are not set: %+v", task)
+	}
+
+	tasks, err := r.GetTasks(ctx, internal.TaskFilter{Type: internal.TASK_ADD_NODE_TO_CLUSTER, NodeID: 42, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != id || tasks[0].Log != "" {
+		t.Errorf("unexpected tasks %+v", tasks)
+	}
+
+	if _, err = r.GetTask(ctx, -1); err != internal.ErrTaskNotFound {
+		t.Errorf("expected ErrTaskNotFound, got %v", err)
+	}
 }
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+)
+
+const errTaskInterrupted = "task was interrupted by server restart"
+
+func (r *Repository) AddTask(ctx context.Context, task internal.Task) (int, error) {
+	sqlScript := "INSERT INTO tasks(type, node_id, cluster_id, status, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id;"
+	var id int
+	err := r.db.QueryRowContext(ctx, sqlScript, task.Type, task.NodeID, task.ClusterID, task.Status, time.Now()).Scan(&id)
+	if err != nil {
+		r.l.Error("error during adding task to database", zap.Error(err))
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *Repository) StartTask(ctx context.Context, id int) error {
+	sqlScript := "UPDATE tasks SET status = $1, started_at = $2 WHERE id = $3"
+	_, err := r.db.ExecContext(ctx, sqlScript, internal.STATUS_IN_PROCESS, time.Now(), id)
+	if err != nil {
+		r.l.Error("error during starting task in database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) AppendTaskLog(ctx context.Context, id int, log string) error {
+	sqlScript := "UPDATE tasks SET log = log || $1 WHERE id = $2"
+	_, err := r.db.ExecContext(ctx, sqlScript, log, id)
+	if err != nil {
+		r.l.Error("error during appending task log in database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) FinishTask(ctx context.Context, id int, status internal.TaskStatus, taskErr string) error {
+	sqlScript := "UPDATE tasks SET status = $1, error = $2, finished_at = $3 WHERE id = $4"
+	_, err := r.db.ExecContext(ctx, sqlScript, status, taskErr, time.Now(), id)
+	if err != nil {
+		r.l.Error("error during finishing task in database", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetTasks(ctx context.Context, filter internal.TaskFilter) ([]internal.Task, error) {
+	conditions := make([]string, 0, 5)
+	args := make([]interface{}, 0, 6)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" $"+strconv.Itoa(len(args)))
+	}
+
+	if filter.Type != "" {
+		addCondition("type =", filter.Type)
+	}
+	if filter.Status != "" {
+		addCondition("status =", filter.Status)
+	}
+	if filter.NodeID != 0 {
+		addCondition("node_id =", filter.NodeID)
+	}
+	if filter.ClusterID != 0 {
+		addCondition("cluster_id =", filter.ClusterID)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >=", filter.Since)
+	}
+
+	sqlScript := "SELECT id, type, node_id, cluster_id, status, error, created_at, started_at, finished_at FROM tasks"
+	if len(conditions) != 0 {
+		sqlScript += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlScript += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		sqlScript += " LIMIT $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlScript, args...)
+	if err != nil {
+		r.l.Error("error in db query during getting tasks", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := make([]internal.Task, 0)
+	for rows.Next() {
+		var task internal.Task
+		var startedAt, finishedAt sql.NullTime
+		if err = rows.Scan(&task.ID, &task.Type, &task.NodeID, &task.ClusterID, &task.Status, &task.Error, &task.CreatedAt, &startedAt, &finishedAt); err != nil {
+			r.l.Error("error during scanning task from database", zap.Error(err))
+			return nil, err
+		}
+		task.StartedAt, task.FinishedAt = nullTimeToPtr(startedAt), nullTimeToPtr(finishedAt)
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+func (r *Repository) GetTask(ctx context.Context, id int) (internal.Task, error) {
+	sqlScript := "SELECT id, type, node_id, cluster_id, status, error, log, created_at, started_at, finished_at FROM tasks WHERE id = $1"
+
+	var task internal.Task
+	var startedAt, finishedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, sqlScript, id).Scan(&task.ID, &task.Type, &task.NodeID, &task.ClusterID, &task.Status, &task.Error, &task.Log, &task.CreatedAt, &startedAt, &finishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return internal.Task{}, internal.ErrTaskNotFound
+	}
+	if err != nil {
+		r.l.Error("error in db query during getting task", zap.Error(err))
+		return internal.Task{}, err
+	}
+	task.StartedAt, task.FinishedAt = nullTimeToPtr(startedAt), nullTimeToPtr(finishedAt)
+	return task, nil
+}
+
+func nullTimeToPtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/taskmanager"
+)
+
+const addonMonitoring = "monitoring"
+
+// InstallMonitoring queues installation of monitoring add-on to existing cluster. Returns id of the task in history
+func (s *Service) InstallMonitoring(ctx context.Context, clusterID int) (int, error) {
+	isClusterExists, err := s.r.CheckClusterTokenIPAndHash(ctx, clusterID)
+	if err != nil {
+		return 0, err
+	}
+	if !isClusterExists {
+		return 0, internal.ErrClusterNotExists
+	}
+	masters, err := s.r.GetControlPlaneNodes(ctx, clusterID)
+	if err != nil {
+		return 0, err
+	}
+	if len(masters) == 0 {
+		return 0, internal.ErrClusterNotExists
+	}
+
+	taskID, err := s.addClusterTask(ctx, opAddon, internal.Task{Type: internal.TASK_INSTALL_MONITORING, ClusterID: clusterID}, masters[0], func(historyID int) func(taskID taskmanager.ID) error {
+		return s.addonProgressTask(addonMonitoring, historyID, s.k8sInstaller.InstallMonitoring)
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.sm.Send(&socketmanager.Message{Type: internal.AddonT, Payload: internal.AddonProgressMsg{Addon: addonMonitoring, Status: internal.STATUS_IN_QUEUE}})
+	return taskID, nil
+}
+
+func (s *Service) addonProgressTask(addon string, historyID int, install func(ctx context.Context, sendProgress func(percent int, status internal.TaskStatus, log string, err string), sendLog func(stream internal.LogStream, line string)) error) func(taskId taskmanager.ID) error {
+	return func(taskID taskmanager.ID) error {
+		sendProgress := func(percent int, status internal.TaskStatus, log string, err string) {
+			log = s.redactLog(log)
+			msg := socketmanager.Message{Type: internal.AddonT, Payload: internal.AddonProgressMsg{Addon: addon, Status: status, Percent: percent, Log: log, Error: err}}
+			if status == internal.STATUS_ERROR || status == internal.STATUS_SUCCESS {
+				msg.MustSent = true
+			}
+			s.sm.Send(&msg)
+			s.initMsg.PushAddon(addon, &msg)
+			s.recordProgress(historyID, status, log)
+		}
+		sendLog := func(stream internal.LogStream, line string) {
+			s.sm.Send(&socketmanager.Message{Type: internal.AddonLogT, Payload: internal.AddonLogMsg{Addon: addon, Stream: stream, Line: line}})
+		}
+
+		sendProgress(1, internal.STATUS_START, "", "")
+		return install(context.Background(), sendProgress, sendLog)
+	}
+}
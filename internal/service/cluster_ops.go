@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/taskmanager"
+)
+
+// clusterOp is kind of cluster-wide operation guarded by clusterOps
+type clusterOp int
+
+const (
+	opNone clusterOp = iota
+	opUpgrade
+	opDestroy
+	// opNodeTask is adding node to cluster or removing it, node tasks run beside each other
+	opNodeTask
+	opAddon
+)
+
+// clusterOps is state of cluster-wide operations which are queued or running. Upgrade and destroy run alone,
+// node tasks and add-on installation don't run beside them
+type clusterOps struct {
+	mu        sync.Mutex
+	exclusive clusterOp
+	nodeTasks int
+	addon     bool
+}
+
+// begin marks operation as started or returns error describing operation which prevents it
+func (o *clusterOps) begin(op clusterOp) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch o.exclusive {
+	case opUpgrade:
+		return internal.ErrUpgradeInProgress
+	case opDestroy:
+		return internal.ErrDestroyInProgress
+	}
+	switch op {
+	case opUpgrade, opDestroy:
+		if o.nodeTasks > 0 || o.addon {
+			return internal.ErrClusterBusy
+		}
+		o.exclusive = op
+	case opNodeTask:
+		o.nodeTasks++
+	case opAddon:
+		if o.addon {
+			return internal.ErrAddonInProgress
+		}
+		o.addon = true
+	}
+	return nil
+}
+
+// end marks operation started by begin as finished
+func (o *clusterOps) end(op clusterOp) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	switch op {
+	case opUpgrade, opDestroy:
+		o.exclusive = opNone
+	case opNodeTask:
+		o.nodeTasks--
+	case opAddon:
+		o.addon = false
+	}
+}
+
+// addClusterTask is addHistoryTask of cluster-wide operation, the operation is held from queueing until the task finishes
+func (s *Service) addClusterTask(ctx context.Context, op clusterOp, task internal.Task, node internal.FullNode, process func(historyID int) func(taskID taskmanager.ID) error) (int, error) {
+	if err := s.ops.begin(op); err != nil {
+		return 0, err
+	}
+	taskID, err := s.addHistoryTask(ctx, task, node, func(historyID int) func(taskID taskmanager.ID) error {
+		run := process(historyID)
+		return func(taskID taskmanager.ID) error {
+			defer s.ops.end(op)
+			return run(taskID)
+		}
+	})
+	if err != nil {
+		s.ops.end(op)
+	}
+	return taskID, err
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+)
+
+func TestClusterOps(t *testing.T) {
+	tests := []struct {
+		name    string
+		running []clusterOp
+		op      clusterOp
+		wantErr error
+	}{
+		{name: "upgrade on idle cluster", op: opUpgrade},
+		{name: "node tasks beside each other", running: []clusterOp{opNodeTask}, op: opNodeTask},
+		{name: "add-on beside node task", running: []clusterOp{opNodeTask}, op: opAddon},
+		{name: "node task while destroying", running: []clusterOp{opDestroy}, op: opNodeTask, wantErr: internal.ErrDestroyInProgress},
+		{name: "add-on while upgrading", running: []clusterOp{opUpgrade}, op: opAddon, wantErr: internal.ErrUpgradeInProgress},
+		{name: "destroy while upgrading", running: []clusterOp{opUpgrade}, op: opDestroy, wantErr: internal.ErrUpgradeInProgress},
+		{name: "upgrade while destroying", running: []clusterOp{opDestroy}, op: opUpgrade, wantErr: internal.ErrDestroyInProgress},
+		{name: "destroy while node task runs", running: []clusterOp{opNodeTask}, op: opDestroy, wantErr: internal.ErrClusterBusy},
+		{name: "upgrade while add-on installs", running: []clusterOp{opAddon}, op: opUpgrade, wantErr: internal.ErrClusterBusy},
+		{name: "second add-on", running: []clusterOp{opAddon}, op: opAddon, wantErr: internal.ErrAddonInProgress},
+	}
+	for _, tt := range tests {
+		var ops clusterOps
+		for _, op := range tt.running {
+			if err := ops.begin(op); err != nil {
+				t.Fatalf("%s: begin %d: %v", tt.name, op, err)
+			}
+		}
+		if err := ops.begin(tt.op); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClusterOpsEnd(t *testing.T) {
+	var ops clusterOps
+	for _, op := range []clusterOp{opNodeTask, opNodeTask} {
+		if err := ops.begin(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ops.end(opNodeTask)
+	if err := ops.begin(opDestroy); !errors.Is(err, internal.ErrClusterBusy) {
+		t.Errorf("destroy with one node task left: error = %v, want %v", err, internal.ErrClusterBusy)
+	}
+	ops.end(opNodeTask)
+	if err := ops.begin(opDestroy); err != nil {
+		t.Fatalf("destroy after node tasks finished: %v", err)
+	}
+	ops.end(opDestroy)
+	if err := ops.begin(opNodeTask); err != nil {
+		t.Errorf("node task after destroy finished: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/taskmanager"
+)
+
+// DestroyCluster queues reset of all nodes of the cluster and removal of its state. Returns id of the task in history
+func (s *Service) DestroyCluster(ctx context.Context, clusterID int) (int, error) {
+	isClusterExists, err := s.r.CheckClusterTokenIPAndHash(ctx, clusterID)
+	if err != nil {
+		return 0, err
+	}
+	if !isClusterExists {
+		return 0, internal.ErrClusterNotExists
+	}
+
+	masters, err := s.r.GetControlPlaneNodes(ctx, clusterID)
+	if err != nil {
+		return 0, err
+	}
+	if len(masters) == 0 {
+		return 0, internal.ErrClusterNotExists
+	}
+
+	taskID, err := s.addClusterTask(ctx, opDestroy, internal.Task{Type: internal.TASK_DESTROY_CLUSTER, ClusterID: clusterID}, masters[0], func(historyID int) func(taskID taskmanager.ID) error {
+		return s.destroyClusterProgressTask(context.Background(), historyID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.sm.Send(&socketmanager.Message{Type: internal.DestroyClusterT, Payload: internal.DestroyClusterProgressMsg{Status: internal.STATUS_IN_QUEUE}})
+	return taskID, nil
+}
+
+func (s *Service) destroyClusterProgressTask(ctx context.Context, historyID int) func(taskId taskmanager.ID) error {
+	return func(taskID taskmanager.ID) error {
+		// nodes are reset in parallel, init messages and task log aren't safe for concurrent use
+		var mu sync.Mutex
+		sendProgress := func(nodeID int, percent int, status internal.TaskStatus, log string, err string) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			log = s.redactLog(log)
+			msg := socketmanager.Message{Type: internal.DestroyClusterT, Payload: internal.DestroyClusterProgressMsg{NodeID: nodeID, Status: status, Percent: percent, Log: log, Error: err}}
+			if status == internal.STATUS_ERROR || status == internal.STATUS_SUCCESS {
+				msg.MustSent = true
+			}
+			s.sm.Send(&msg)
+			s.initMsg.PushDestroyCluster(nodeID, &msg)
+			// only progress of the whole cluster starts history task, nodes append their logs
+			if nodeID != 0 && status == internal.STATUS_START {
+				status = internal.STATUS_IN_PROCESS
+			}
+			s.recordProgress(historyID, status, log)
+		}
+		sendLog := func(nodeID int, stream internal.LogStream, line string) {
+			s.sm.Send(&socketmanager.Message{Type: internal.DestroyClusterLogT, Payload: internal.TaskLogMsg{NodeID: nodeID, Stream: stream, Line: line}})
+		}
+
+		err := s.k8sInstaller.DestroyCluster(ctx, sendProgress, sendLog)
+		s.hi.SetNewConfig()
+		return err
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"github.com/Killer-Feature/PaaS_ClientSide/internal"
 	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
+	"sync"
 	"time"
 )
 
@@ -13,18 +14,35 @@ const (
 	removeFromClusterProgress          = 30 * time.Second
 	successRemoveFromClusterProgress   = 5 * time.Second
 	errRemoveFromClusterProgress       = 10 * time.Second
+	upgradeClusterProgress             = 30 * time.Second
+	successUpgradeClusterProgress      = 5 * time.Second
+	errUpgradeClusterProgress          = 10 * time.Second
+	destroyClusterProgress             = 30 * time.Second
+	successDestroyClusterProgress      = 5 * time.Second
+	errDestroyClusterProgress          = 10 * time.Second
+	addonProgress                      = 30 * time.Second
+	successAddonProgress               = 5 * time.Second
+	errAddonProgress                   = 10 * time.Second
 	metricsTimeout                     = 30 * time.Second
 )
 
 type messageWithTimeout struct {
 	msg     *socketmanager.Message
 	expired time.Time
+	// init is sent to new sockets instead of msg, it holds the whole task log
+	// because progress messages carry only the log delta
+	init *socketmanager.Message
 }
 
+// initMessages is used by tasks running concurrently, mu guards all its fields
 type initMessages struct {
+	mu                        sync.Mutex
 	metrics                   messageWithTimeout
 	addToClusterProgress      map[int]messageWithTimeout
 	removeFromClusterProgress map[int]messageWithTimeout
+	upgradeClusterProgress    map[int]messageWithTimeout
+	destroyClusterProgress    map[int]messageWithTimeout
+	addonProgress             map[string]messageWithTimeout
 }
 
 func newInitMessages() *initMessages {
@@ -32,16 +50,28 @@ func newInitMessages() *initMessages {
 		metrics:                   messageWithTimeout{},
 		addToClusterProgress:      make(map[int]messageWithTimeout),
 		removeFromClusterProgress: make(map[int]messageWithTimeout),
+		upgradeClusterProgress:    make(map[int]messageWithTimeout),
+		destroyClusterProgress:    make(map[int]messageWithTimeout),
+		addonProgress:             make(map[string]messageWithTimeout),
 	}
 }
 
 func (i *initMessages) PushMetrics(msg *socketmanager.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	i.metrics = messageWithTimeout{msg: msg, expired: time.Now().Add(metricsTimeout)}
 }
 
 func (i *initMessages) PushAddToCluster(nodeID int, msg *socketmanager.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	payload := msg.Payload.(internal.AddNodeToClusterProgressMsg)
+	if prev, ok := i.addToClusterProgress[nodeID]; ok && payload.Status != internal.STATUS_START {
+		payload.Log = prev.init.Payload.(internal.AddNodeToClusterProgressMsg).Log + payload.Log
+	}
+
 	expired := time.Now()
-	switch msg.Payload.(internal.AddNodeToClusterProgressMsg).Status {
+	switch payload.Status {
 	case internal.STATUS_ERROR:
 		expired = expired.Add(errAddToClusterProgressTimeout)
 	case internal.STATUS_SUCCESS:
@@ -49,12 +79,19 @@ func (i *initMessages) PushAddToCluster(nodeID int, msg *socketmanager.Message)
 	default:
 		expired = expired.Add(addToClusterProgressTimeout)
 	}
-	i.addToClusterProgress[nodeID] = messageWithTimeout{msg: msg, expired: expired}
+	i.addToClusterProgress[nodeID] = messageWithTimeout{msg: msg, expired: expired, init: &socketmanager.Message{Type: msg.Type, Payload: payload}}
 }
 
 func (i *initMessages) PushRemoveFromCluster(nodeID int, msg *socketmanager.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	payload := msg.Payload.(internal.RemoveNodeFromClusterMsg)
+	if prev, ok := i.removeFromClusterProgress[nodeID]; ok && payload.Status != internal.STATUS_START {
+		payload.Log = prev.init.Payload.(internal.RemoveNodeFromClusterMsg).Log + payload.Log
+	}
+
 	expired := time.Now()
-	switch msg.Payload.(internal.RemoveNodeFromClusterMsg).Status {
+	switch payload.Status {
 	case internal.STATUS_ERROR:
 		expired = expired.Add(errRemoveFromClusterProgress)
 	case internal.STATUS_SUCCESS:
@@ -62,16 +99,78 @@ func (i *initMessages) PushRemoveFromCluster(nodeID int, msg *socketmanager.Mess
 	default:
 		expired = expired.Add(removeFromClusterProgress)
 	}
-	i.removeFromClusterProgress[nodeID] = messageWithTimeout{msg: msg, expired: expired}
+	i.removeFromClusterProgress[nodeID] = messageWithTimeout{msg: msg, expired: expired, init: &socketmanager.Message{Type: msg.Type, Payload: payload}}
+}
+
+func (i *initMessages) PushUpgradeCluster(nodeID int, msg *socketmanager.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	payload := msg.Payload.(internal.UpgradeClusterProgressMsg)
+	if prev, ok := i.upgradeClusterProgress[nodeID]; ok && payload.Status != internal.STATUS_START {
+		payload.Log = prev.init.Payload.(internal.UpgradeClusterProgressMsg).Log + payload.Log
+	}
+
+	expired := time.Now()
+	switch payload.Status {
+	case internal.STATUS_ERROR:
+		expired = expired.Add(errUpgradeClusterProgress)
+	case internal.STATUS_SUCCESS:
+		expired = expired.Add(successUpgradeClusterProgress)
+	default:
+		expired = expired.Add(upgradeClusterProgress)
+	}
+	i.upgradeClusterProgress[nodeID] = messageWithTimeout{msg: msg, expired: expired, init: &socketmanager.Message{Type: msg.Type, Payload: payload}}
+}
+
+func (i *initMessages) PushDestroyCluster(nodeID int, msg *socketmanager.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	payload := msg.Payload.(internal.DestroyClusterProgressMsg)
+	if prev, ok := i.destroyClusterProgress[nodeID]; ok && payload.Status != internal.STATUS_START {
+		payload.Log = prev.init.Payload.(internal.DestroyClusterProgressMsg).Log + payload.Log
+	}
+
+	expired := time.Now()
+	switch payload.Status {
+	case internal.STATUS_ERROR:
+		expired = expired.Add(errDestroyClusterProgress)
+	case internal.STATUS_SUCCESS:
+		expired = expired.Add(successDestroyClusterProgress)
+	default:
+		expired = expired.Add(destroyClusterProgress)
+	}
+	i.destroyClusterProgress[nodeID] = messageWithTimeout{msg: msg, expired: expired, init: &socketmanager.Message{Type: msg.Type, Payload: payload}}
+}
+
+func (i *initMessages) PushAddon(addon string, msg *socketmanager.Message) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	payload := msg.Payload.(internal.AddonProgressMsg)
+	if prev, ok := i.addonProgress[addon]; ok && payload.Status != internal.STATUS_START {
+		payload.Log = prev.init.Payload.(internal.AddonProgressMsg).Log + payload.Log
+	}
+
+	expired := time.Now()
+	switch payload.Status {
+	case internal.STATUS_ERROR:
+		expired = expired.Add(errAddonProgress)
+	case internal.STATUS_SUCCESS:
+		expired = expired.Add(successAddonProgress)
+	default:
+		expired = expired.Add(addonProgress)
+	}
+	i.addonProgress[addon] = messageWithTimeout{msg: msg, expired: expired, init: &socketmanager.Message{Type: msg.Type, Payload: payload}}
 }
 
 func (i *initMessages) GetInitMessages() []*socketmanager.Message {
-	msgs := append(make([]*socketmanager.Message, 0, len(i.addToClusterProgress)+len(i.removeFromClusterProgress)+1))
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	msgs := make([]*socketmanager.Message, 0, len(i.addToClusterProgress)+len(i.removeFromClusterProgress)+len(i.upgradeClusterProgress)+len(i.destroyClusterProgress)+len(i.addonProgress)+1)
 
 	getValid := func(m map[int]messageWithTimeout) {
 		for key, msg := range m {
 			if time.Now().Before(msg.expired) || (!msg.msg.Sent && msg.msg.MustSent) {
-				msgs = append(msgs, msg.msg)
+				msgs = append(msgs, msg.init)
 			} else {
 				delete(m, key)
 			}
@@ -80,6 +179,15 @@ func (i *initMessages) GetInitMessages() []*socketmanager.Message {
 
 	getValid(i.addToClusterProgress)
 	getValid(i.removeFromClusterProgress)
+	getValid(i.upgradeClusterProgress)
+	getValid(i.destroyClusterProgress)
+	for addon, msg := range i.addonProgress {
+		if time.Now().Before(msg.expired) || (!msg.msg.Sent && msg.msg.MustSent) {
+			msgs = append(msgs, msg.init)
+		} else {
+			delete(i.addonProgress, addon)
+		}
+	}
 
 	if i.metrics.msg != nil {
 		msgs = append(msgs, i.metrics.msg)
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/taskmanager"
+)
+
+const (
+	defaultProvisionParallelism = 3
+	maxProvisionParallelism     = 20
+)
+
+// provisionNode is node of provisioning request with its task in history
+type provisionNode struct {
+	node         internal.FullNode
+	controlPlane bool
+	historyID    int
+}
+
+// ProvisionCluster adds nodes to the cluster. The master is bootstrapped first, or joins as additional master
+// when the cluster exists, then workers join concurrently, at most parallelism at once. Every node is queued as its own task
+// by its address. Returns ids of node tasks in history, the master's is first
+func (s *Service) ProvisionCluster(ctx context.Context, masterID int, workerIDs []int, parallelism int) ([]int, error) {
+	if parallelism == 0 {
+		parallelism = defaultProvisionParallelism
+	}
+	if parallelism < 0 || parallelism > maxProvisionParallelism {
+		return nil, fmt.Errorf("%w: parallelism must be between 1 and %d", internal.ErrInvalidProvision, maxProvisionParallelism)
+	}
+
+	isClusterExists, err := s.r.CheckClusterTokenIPAndHash(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	if !isClusterExists && masterID == 0 {
+		return nil, fmt.Errorf("%w: master is required to create cluster", internal.ErrInvalidProvision)
+	}
+
+	ids := workerIDs
+	if masterID != 0 {
+		ids = append([]int{masterID}, workerIDs...)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: no nodes", internal.ErrInvalidProvision)
+	}
+
+	seen := make(map[int]bool, len(ids))
+	nodes := make([]internal.FullNode, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: node %d is listed twice", internal.ErrInvalidProvision, id)
+		}
+		seen[id] = true
+
+		node, err := s.r.GetFullNode(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: node %d: %s", internal.ErrInvalidProvision, id, err.Error())
+		}
+		if node.ClusterID != 0 {
+			return nil, fmt.Errorf("%w: node %s is already in cluster", internal.ErrInvalidProvision, node.Name)
+		}
+		nodes = append(nodes, node)
+	}
+
+	if err = s.ops.begin(opNodeTask); err != nil {
+		return nil, err
+	}
+	provisioned := make([]provisionNode, 0, len(nodes))
+	historyIDs := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		historyID, err := s.r.AddTask(ctx, internal.Task{Type: internal.TASK_ADD_NODE_TO_CLUSTER, NodeID: node.ID, ClusterID: 1, Status: internal.STATUS_IN_QUEUE})
+		if err != nil {
+			for _, p := range provisioned {
+				_ = s.r.FinishTask(ctx, p.historyID, internal.STATUS_ERROR, err.Error())
+			}
+			s.ops.end(opNodeTask)
+			return nil, err
+		}
+		provisioned = append(provisioned, provisionNode{node: node, historyID: historyID})
+		historyIDs = append(historyIDs, historyID)
+	}
+
+	var master *provisionNode
+	workers := provisioned
+	if masterID != 0 {
+		// master creates the cluster by kubeadm init or joins existing one as additional master, installer decides it
+		// after taking its init lock, so cluster created meanwhile by another task is joined
+		master, workers = &provisioned[0], provisioned[1:]
+		master.controlPlane = true
+	}
+
+	install := func(p provisionNode) func(taskID taskmanager.ID) error {
+		return s.recordTask(p.historyID, s.addNodeToCurrentClusterProgressTask(context.Background(), p.node, p.controlPlane, p.historyID))
+	}
+	masterTask, workerTasks := provisionTasks(master, workers, parallelism, install, s.failProvisionNode)
+
+	// node tasks of provisioning are held as one cluster operation until the last of them finishes
+	var wg sync.WaitGroup
+	queue := func(p provisionNode, task func(taskID taskmanager.ID) error) error {
+		wg.Add(1)
+		_, err := s.tm.AddTask(func(taskID taskmanager.ID) error {
+			defer wg.Done()
+			return task(taskID)
+		}, p.node.IP)
+		if err != nil {
+			wg.Done()
+		}
+		return err
+	}
+	if master != nil {
+		if err = queue(*master, masterTask); err != nil {
+			for _, p := range provisioned {
+				_ = s.r.FinishTask(ctx, p.historyID, internal.STATUS_ERROR, err.Error())
+			}
+			s.ops.end(opNodeTask)
+			return nil, err
+		}
+	}
+	for i, worker := range workers {
+		if err := queue(worker, workerTasks[i]); err != nil {
+			s.failProvisionNode(worker, err.Error())
+		}
+	}
+	go func() {
+		wg.Wait()
+		s.ops.end(opNodeTask)
+	}()
+
+	for _, node := range nodes {
+		s.sm.Send(&socketmanager.Message{Type: internal.AddNodeToClusterT, Payload: internal.AddNodeToClusterProgressMsg{NodeID: node.ID, Status: internal.STATUS_IN_QUEUE, Percent: 0}})
+	}
+	return historyIDs, nil
+}
+
+// provisionTasks returns task of the master and tasks of workers which are queued by their nodes. Workers wait
+// until the master task finishes and are failed without installation when it fails, at most parallelism of them
+// are installed at once
+func provisionTasks(master *provisionNode, workers []provisionNode, parallelism int, install func(p provisionNode) func(taskID taskmanager.ID) error, fail func(p provisionNode, reason string)) (func(taskID taskmanager.ID) error, []func(taskID taskmanager.ID) error) {
+	masterDone := make(chan struct{})
+	var masterErr error
+	var masterTask func(taskID taskmanager.ID) error
+	if master == nil {
+		close(masterDone)
+	} else {
+		masterTask = func(taskID taskmanager.ID) error {
+			defer close(masterDone)
+			masterErr = install(*master)(taskID)
+			return masterErr
+		}
+	}
+
+	sem := make(chan struct{}, parallelism)
+	workerTasks := make([]func(taskID taskmanager.ID) error, 0, len(workers))
+	for _, worker := range workers {
+		worker := worker
+		workerTasks = append(workerTasks, func(taskID taskmanager.ID) error {
+			<-masterDone
+			if masterErr != nil {
+				err := fmt.Errorf("master %s wasn't bootstrapped: %w", master.node.Name, masterErr)
+				fail(worker, err.Error())
+				return err
+			}
+			sem <- struct{}{}
+			defer func() {
+				<-sem
+			}()
+			return install(worker)(taskID)
+		})
+	}
+	return masterTask, workerTasks
+}
+
+// failProvisionNode finishes task of the node which wasn't started because of failed dependency
+func (s *Service) failProvisionNode(p provisionNode, reason string) {
+	msg := socketmanager.Message{Type: internal.AddNodeToClusterT, Payload: internal.AddNodeToClusterProgressMsg{NodeID: p.node.ID, Status: internal.STATUS_ERROR, Error: reason}, MustSent: true}
+	s.sm.Send(&msg)
+	s.initMsg.PushAddToCluster(p.node.ID, &msg)
+	_ = s.r.FinishTask(context.Background(), p.historyID, internal.STATUS_ERROR, reason)
+}
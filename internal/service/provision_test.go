@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/taskmanager"
+)
+
+// provisionRecorder is fake installation of provisioned nodes recording their order and concurrency
+type provisionRecorder struct {
+	mu        sync.Mutex
+	installed []string
+	failed    []string
+	running   int
+	maxActive int
+	// errs are errors of installation by node name
+	errs map[string]error
+}
+
+func (r *provisionRecorder) install(p provisionNode) func(taskID taskmanager.ID) error {
+	return func(taskID taskmanager.ID) error {
+		r.mu.Lock()
+		r.running++
+		if r.running > r.maxActive {
+			r.maxActive = r.running
+		}
+		r.mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.running--
+		r.installed = append(r.installed, p.node.Name)
+		return r.errs[p.node.Name]
+	}
+}
+
+func (r *provisionRecorder) fail(p provisionNode, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = append(r.failed, p.node.Name)
+}
+
+func provisionNodes(prefix string, n int) []provisionNode {
+	nodes := make([]provisionNode, n)
+	for i := range nodes {
+		nodes[i] = provisionNode{node: internal.FullNode{ID: i + 1, Name: fmt.Sprintf("%s%d", prefix, i+1)}}
+	}
+	return nodes
+}
+
+// runProvisionTasks runs tasks concurrently as taskmanager does for different nodes, workers are started first
+func runProvisionTasks(masterTask func(taskID taskmanager.ID) error, workerTasks []func(taskID taskmanager.ID) error) {
+	var wg sync.WaitGroup
+	for _, task := range workerTasks {
+		wg.Add(1)
+		go func(task func(taskID taskmanager.ID) error) {
+			defer wg.Done()
+			_ = task(0)
+		}(task)
+	}
+	if masterTask != nil {
+		time.Sleep(10 * time.Millisecond)
+		_ = masterTask(0)
+	}
+	wg.Wait()
+}
+
+func TestProvisionTasksMasterFirst(t *testing.T) {
+	r := &provisionRecorder{}
+	master := provisionNode{node: internal.FullNode{Name: "master"}, controlPlane: true}
+	masterTask, workerTasks := provisionTasks(&master, provisionNodes("worker", 4), 2, r.install, r.fail)
+	runProvisionTasks(masterTask, workerTasks)
+
+	if len(r.installed) != 5 || r.installed[0] != "master" {
+		t.Errorf("installed %v, want master first and 4 workers", r.installed)
+	}
+	if r.maxActive > 2 {
+		t.Errorf("%d nodes were installed at once, want at most 2", r.maxActive)
+	}
+	if len(r.failed) != 0 {
+		t.Errorf("failed %v, want none", r.failed)
+	}
+}
+
+func TestProvisionTasksParallelism(t *testing.T) {
+	tests := []struct {
+		name        string
+		workers     int
+		parallelism int
+		wantActive  int
+	}{
+		{name: "limited", workers: 6, parallelism: 3, wantActive: 3},
+		{name: "one at once", workers: 3, parallelism: 1, wantActive: 1},
+		{name: "fewer workers than limit", workers: 2, parallelism: 5, wantActive: 2},
+	}
+	for _, tt := range tests {
+		r := &provisionRecorder{}
+		masterTask, workerTasks := provisionTasks(nil, provisionNodes("worker", tt.workers), tt.parallelism, r.install, r.fail)
+		if masterTask != nil {
+			t.Errorf("%s: master task is returned without master", tt.name)
+		}
+		runProvisionTasks(nil, workerTasks)
+
+		if len(r.installed) != tt.workers {
+			t.Errorf("%s: installed %v, want %d workers", tt.name, r.installed, tt.workers)
+		}
+		if r.maxActive != tt.wantActive {
+			t.Errorf("%s: %d workers were installed at once, want %d", tt.name, r.maxActive, tt.wantActive)
+		}
+	}
+}
+
+func TestProvisionTasksMasterFailed(t *testing.T) {
+	errInit := errors.New("kubeadm init failed")
+	r := &provisionRecorder{errs: map[string]error{"master": errInit}}
+	master := provisionNode{node: internal.FullNode{Name: "master"}, controlPlane: true}
+	masterTask, workerTasks := provisionTasks(&master, provisionNodes("worker", 3), 2, r.install, r.fail)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(workerTasks))
+	for i, task := range workerTasks {
+		wg.Add(1)
+		go func(i int, task func(taskID taskmanager.ID) error) {
+			defer wg.Done()
+			errs[i] = task(0)
+		}(i, task)
+	}
+	if err := masterTask(0); !errors.Is(err, errInit) {
+		t.Errorf("master error = %v, want %v", err, errInit)
+	}
+	wg.Wait()
+
+	if len(r.installed) != 1 {
+		t.Errorf("installed %v, want only master", r.installed)
+	}
+	if len(r.failed) != 3 {
+		t.Errorf("failed %v, want all workers", r.failed)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, errInit) {
+			t.Errorf("worker %d error = %v, want %v", i+1, err, errInit)
+		}
+	}
+}
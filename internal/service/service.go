@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
@@ -22,7 +23,8 @@ import (
 	"github.com/Killer-Feature/PaaS_ClientSide/pkg/helm"
 	k8s_installer "github.com/Killer-Feature/PaaS_ClientSide/pkg/k8s-installer"
 
-	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/distro"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/remote_exec"
 	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
 	cconn "github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
 	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn/ssh"
@@ -45,6 +47,9 @@ type Service struct {
 	hi           *helm.HelmInstaller
 	k8sInstaller *k8s_installer.Installer
 	initMsg      *initMessages
+
+	// ops guards cluster-wide operations from running beside each other
+	ops clusterOps
 }
 
 // NewService returns instance of Huginn service
@@ -86,36 +91,44 @@ func (s *Service) GetClusterNodes(ctx context.Context) ([]internal.Node, error)
 			Name:      node.Name,
 			ClusterID: node.ClusterID,
 			IsMaster:  node.IsMaster,
+			OS:        node.OS,
 		}
 	}
 
 	return respNodes, nil
 }
 
-func (s *Service) AddNodeToCurrentCluster(ctx context.Context, id int) (int, error) {
+func (s *Service) AddNodeToCurrentCluster(ctx context.Context, id int, controlPlane bool) (int, error) {
 	node, err := s.r.GetFullNode(ctx, id)
 	if err != nil {
 		return 0, err
 	}
 
-	taskID, err := s.tm.AddTask(s.addNodeToCurrentClusterProgressTask(context.Background(), node), node.IP)
+	taskID, err := s.addClusterTask(ctx, opNodeTask, internal.Task{Type: internal.TASK_ADD_NODE_TO_CLUSTER, NodeID: node.ID, ClusterID: 1}, node, func(historyID int) func(taskID taskmanager.ID) error {
+		return s.addNodeToCurrentClusterProgressTask(context.Background(), node, controlPlane, historyID)
+	})
 
 	if err == nil {
 		s.sm.Send(&socketmanager.Message{Type: internal.AddNodeToClusterT, Payload: internal.AddNodeToClusterProgressMsg{NodeID: node.ID, Status: internal.STATUS_IN_QUEUE, Percent: 0}})
 	}
 
-	return int(taskID), err
+	return taskID, err
 }
 
-func (s *Service) addNodeToCurrentClusterProgressTask(ctx context.Context, node internal.FullNode) func(taskId taskmanager.ID) error {
+func (s *Service) addNodeToCurrentClusterProgressTask(ctx context.Context, node internal.FullNode, controlPlane bool, historyID int) func(taskId taskmanager.ID) error {
 	return func(taskID taskmanager.ID) error {
 		sendProgress := func(percent int, status internal.TaskStatus, log string, err string) {
+			log = s.redactLog(log)
 			msg := socketmanager.Message{Type: internal.AddNodeToClusterT, Payload: internal.AddNodeToClusterProgressMsg{NodeID: node.ID, Status: status, Percent: percent, Log: log, Error: err}}
 			if status == internal.STATUS_ERROR || status == internal.STATUS_SUCCESS {
 				msg.MustSent = true
 			}
 			s.sm.Send(&msg)
 			s.initMsg.PushAddToCluster(node.ID, &msg)
+			s.recordProgress(historyID, status, log)
+		}
+		sendLog := func(stream internal.LogStream, line string) {
+			s.sm.Send(&socketmanager.Message{Type: internal.AddNodeToClusterLogT, Payload: internal.TaskLogMsg{NodeID: node.ID, Stream: stream, Line: line}})
 		}
 		sendProgress(1, internal.STATUS_START, "", "")
 		sshBuilder := ssh.NewSSHBuilder()
@@ -129,7 +142,7 @@ func (s *Service) addNodeToCurrentClusterProgressTask(ctx context.Context, node
 			_ = cc.Close()
 		}(cc)
 
-		err = s.k8sInstaller.InstallK8S(cc, node.ID, node.IP.Addr().String(), sendProgress)
+		err = s.k8sInstaller.InstallK8S(cc, node, controlPlane, sendProgress, sendLog)
 
 		return err
 	}
@@ -140,10 +153,43 @@ func (s *Service) AddNode(ctx context.Context, node internal.FullNode) (int, err
 	if err != nil {
 		return 0, err
 	}
-	if exists == 0 {
-		return s.r.AddNode(ctx, node)
+	if exists != 0 {
+		return 0, internal.ErrNodeExists
+	}
+
+	d, err := s.detectDistro(node)
+	if err != nil {
+		return 0, err
 	}
-	return 0, internal.ErrNodeExists
+	node.OS = string(d)
+	return s.r.AddNode(ctx, node)
+}
+
+// detectDistro reads /etc/os-release of the node, unsupported distros are rejected with distro.ErrUnsupportedDistro
+func (s *Service) detectDistro(node internal.FullNode) (distro.Distro, error) {
+	cc, err := ssh.NewSSHBuilder().CreateCC(node.IP, node.Login, node.Password)
+	if err != nil {
+		return "", err
+	}
+	defer func(cc cconn.ClientConn) {
+		_ = cc.Close()
+	}(cc)
+
+	result, err := remote_exec.Run(cc, string(distro.ReadOSRelease().Command), nil, nil)
+	if err != nil {
+		s.l.Error("error reading os-release", zap.String("node", node.IP.String()), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", result.Stderr))
+		return "", err
+	}
+	release, err := distro.ParseOSRelease(result.Stdout)
+	if err != nil {
+		return "", err
+	}
+	d, err := distro.Detect(release)
+	if err != nil {
+		s.l.Warn("node distro isn't supported", zap.String("node", node.IP.String()), zap.String("distro", release.PrettyName))
+		return "", err
+	}
+	return d, nil
 }
 
 func (s *Service) RemoveNode(ctx context.Context, id int) error {
@@ -151,6 +197,9 @@ func (s *Service) RemoveNode(ctx context.Context, id int) error {
 }
 
 func (s *Service) AddResource(ctx context.Context, rType internal.ResourceType, name string) error {
+	if err := s.k8sInstaller.UseBundleCharts(ctx); err != nil {
+		return err
+	}
 	err := s.hi.Install(name, rType)
 	if err != nil {
 		return err
@@ -177,29 +226,9 @@ func (s *Service) RemoveResource(ctx context.Context, rType internal.ResourceTyp
 	return s.hi.UninstallChart(name)
 }
 
+// GetAdminConfig reads admin.conf from the first available master, ./config saved earlier is returned when all masters are unavailable
 func (s *Service) GetAdminConfig(ctx context.Context, clusterId int) (*models.AdminConfig, error) {
-	_, masterIpStr, _, err := s.r.GetClusterTokenIPAndHash(ctx, clusterId)
-	if err != nil {
-		return nil, err
-	}
-
-	ipport, err := netip.ParseAddrPort(masterIpStr)
-	if err != nil {
-		return nil, err
-	}
-
-	masterId, err := s.r.IsNodeExists(ctx, ipport.Addr())
-	if err != nil {
-		return nil, err
-	}
-
-	node, err := s.r.GetFullNode(ctx, masterId)
-	if err != nil {
-		return nil, err
-	}
-
-	sshBuilder := ssh.NewSSHBuilder()
-	cc, err := sshBuilder.CreateCC(node.IP, node.Login, node.Password)
+	cc, master, err := s.k8sInstaller.ConnectToControlPlane(ctx, clusterId)
 
 	if err != nil {
 		configFile, err := os.ReadFile("./config")
@@ -213,7 +242,7 @@ func (s *Service) GetAdminConfig(ctx context.Context, clusterId int) (*models.Ad
 		_ = cc.Close()
 	}(cc)
 
-	output, err := s.getAdminConf(ctx, cc)
+	output, err := s.getAdminConf(ctx, cc, master)
 
 	if err != nil {
 		configFile, err := os.ReadFile("./config")
@@ -227,15 +256,17 @@ func (s *Service) GetAdminConfig(ctx context.Context, clusterId int) (*models.Ad
 	return &models.AdminConfig{Config: string(output)}, nil
 }
 
-func (s *Service) getAdminConf(ctx context.Context, cc cconn.ClientConn) ([]byte, error) {
-	cl := ubuntu.Ubuntu2004CommandLib{}
-	getAdminConfCommand := cl.CatAdminConfFile()
-	output, err := cc.Exec(string(getAdminConfCommand.Command))
+func (s *Service) getAdminConf(ctx context.Context, cc cconn.ClientConn, master internal.FullNode) ([]byte, error) {
+	cl, err := distro.CommandLib(distro.Distro(master.OS))
+	if err != nil {
+		return nil, err
+	}
+	result, err := remote_exec.Run(cc, string(cl.CatAdminConfFile().Command), nil, nil)
 	if err != nil {
-		s.l.Error("error getting admin.conf", zap.String("error", err.Error()))
+		s.l.Error("error getting admin.conf", zap.Error(err), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", result.Stderr))
 		return nil, err
 	}
-	return output, nil
+	return result.Stdout, nil
 }
 
 func (s *Service) GetResources(ctx context.Context) ([]internal.Resource, error) {
@@ -296,73 +327,219 @@ func (s *Service) GetServices(ctx context.Context) ([]internal.Service, error) {
 	return serviceList, nil
 }
 
-func (s *Service) RemoveNodeFromCurrentCluster(ctx context.Context, id int) (int, error) {
+// RemoveNodeFromCurrentCluster removes node from the cluster. The last master can be removed while workers remain
+// only with destroyCluster confirmation, then workers are reset before it and the whole cluster is removed
+func (s *Service) RemoveNodeFromCurrentCluster(ctx context.Context, id int, destroyCluster bool) (int, error) {
 	node, err := s.r.GetFullNode(ctx, id)
 	if err != nil {
 		return 0, err
 	}
+	workers, err := s.workersToDestroy(ctx, node, destroyCluster)
+	if err != nil {
+		return 0, err
+	}
 
-	taskID, err := s.tm.AddTask(s.removeNodeFromCurrentClusterProgressTask(context.Background(), node), node.IP)
+	taskID, err := s.addClusterTask(ctx, opNodeTask, internal.Task{Type: internal.TASK_REMOVE_NODE_FROM_CLUSTER, NodeID: node.ID, ClusterID: node.ClusterID}, node, func(historyID int) func(taskID taskmanager.ID) error {
+		return s.removeNodeFromCurrentClusterProgressTask(context.Background(), node, workers, historyID)
+	})
 	if err == nil {
-		s.sm.Send(&socketmanager.Message{Type: internal.RemoveNodeFromClusterT, Payload: internal.RemoveNodeFromClusterMsg{NodeID: node.ID, Status: internal.STATUS_IN_QUEUE, Percent: 0}})
+		for _, n := range append(workers, node) {
+			s.sm.Send(&socketmanager.Message{Type: internal.RemoveNodeFromClusterT, Payload: internal.RemoveNodeFromClusterMsg{NodeID: n.ID, Status: internal.STATUS_IN_QUEUE, Percent: 0}})
+		}
 	}
-	return int(taskID), err
+	return taskID, err
 }
 
-func (s *Service) removeNodeFromCurrentClusterProgressTask(ctx context.Context, node internal.FullNode) func(taskId taskmanager.ID) error {
-	return func(taskID taskmanager.ID) error {
-		sendProgress := func(percent int, status internal.TaskStatus, log string, err string) {
-			msg := socketmanager.Message{Type: internal.RemoveNodeFromClusterT, Payload: internal.RemoveNodeFromClusterMsg{NodeID: node.ID, Status: status, Percent: percent, Log: log, Error: err}}
-			if status == internal.STATUS_ERROR || status == internal.STATUS_SUCCESS {
-				msg.MustSent = true
-			}
-			s.sm.Send(&msg)
-			s.initMsg.PushRemoveFromCluster(node.ID, &msg)
-		}
+// workersToDestroy returns workers which are reset together with the last master. Removal of the last master
+// is rejected while workers remain unless destroying the cluster is confirmed
+func (s *Service) workersToDestroy(ctx context.Context, node internal.FullNode, destroyCluster bool) ([]internal.FullNode, error) {
+	lastMaster, err := s.isLastMaster(ctx, node)
+	if err != nil || !lastMaster {
+		return nil, err
+	}
 
-		sendProgress(1, internal.STATUS_START, "", "")
-		sshBuilder := ssh.NewSSHBuilder()
-		cc, err := sshBuilder.CreateCC(node.IP, node.Login, node.Password)
-		if err != nil {
-			return err
+	nodes, err := s.r.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var workers []internal.FullNode
+	for _, n := range nodes {
+		if n.ClusterID == 1 && !n.IsMaster && n.ID != node.ID {
+			workers = append(workers, n)
 		}
-		defer func(cc cconn.ClientConn) {
-			_ = cc.Close()
-		}(cc)
-		err = s.k8sInstaller.RemoveK8S(cc, sendProgress)
-		if err != nil {
-			return err
+	}
+	if len(workers) > 0 && !destroyCluster {
+		return nil, internal.ErrClusterHasWorkers
+	}
+	return workers, nil
+}
+
+func (s *Service) removeNodeProgressFuncs(nodeID, historyID int) (func(percent int, status internal.TaskStatus, log string, err string), func(stream internal.LogStream, line string)) {
+	sendProgress := func(percent int, status internal.TaskStatus, log string, err string) {
+		log = s.redactLog(log)
+		msg := socketmanager.Message{Type: internal.RemoveNodeFromClusterT, Payload: internal.RemoveNodeFromClusterMsg{NodeID: nodeID, Status: status, Percent: percent, Log: log, Error: err}}
+		if status == internal.STATUS_ERROR || status == internal.STATUS_SUCCESS {
+			msg.MustSent = true
 		}
+		s.sm.Send(&msg)
+		s.initMsg.PushRemoveFromCluster(nodeID, &msg)
+		s.recordProgress(historyID, status, log)
+	}
+	sendLog := func(stream internal.LogStream, line string) {
+		s.sm.Send(&socketmanager.Message{Type: internal.RemoveNodeFromClusterLogT, Payload: internal.TaskLogMsg{NodeID: nodeID, Stream: stream, Line: line}})
+	}
+	return sendProgress, sendLog
+}
 
-		defer func(r internal.Repository, ctx context.Context, id int) {
-			_ = r.ResetNodeCluster(ctx, id)
-		}(s.r, ctx, node.ID)
+// resetNode resets kubeadm on the node, clusterDestroyed skips drain and Node deletion as API server is removed too
+func (s *Service) resetNode(ctx context.Context, node internal.FullNode, clusterDestroyed bool, historyID int) error {
+	sendProgress, sendLog := s.removeNodeProgressFuncs(node.ID, historyID)
 
-		_, masterIpStr, _, err := s.r.GetClusterTokenIPAndHash(ctx, 1)
+	sendProgress(1, internal.STATUS_START, "", "")
+	sshBuilder := ssh.NewSSHBuilder()
+	cc, err := sshBuilder.CreateCC(node.IP, node.Login, node.Password)
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
+	defer func(cc cconn.ClientConn) {
+		_ = cc.Close()
+	}(cc)
+
+	err = s.k8sInstaller.RemoveK8S(cc, node, clusterDestroyed, sendProgress, sendLog)
+	if err != nil {
+		return err
+	}
+	return s.r.ResetNodeCluster(ctx, node.ID)
+}
+
+func (s *Service) removeNodeFromCurrentClusterProgressTask(ctx context.Context, node internal.FullNode, workers []internal.FullNode, historyID int) func(taskId taskmanager.ID) error {
+	return func(taskID taskmanager.ID) error {
+		lastMaster, err := s.isLastMaster(ctx, node)
 		if err != nil {
 			return err
 		}
 
-		ipport, err := netip.ParseAddrPort(masterIpStr)
+		// unreachable workers can't be reset, they are removed from the cluster in DB anyway
+		// to not leave them pointing at destroyed control plane
+		var workersErr error
+		for _, worker := range workers {
+			if err = s.resetNode(ctx, worker, true, historyID); err != nil {
+				s.l.Error("worker reset failed while destroying cluster", zap.Int("node", worker.ID), zap.Error(err))
+				workersErr = errors.Join(workersErr, fmt.Errorf("worker %s: %w", worker.Name, err))
+				if err = s.r.ResetNodeCluster(ctx, worker.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		err = s.resetNode(ctx, node, lastMaster, historyID)
 		if err != nil {
-			return err
+			return errors.Join(workersErr, err)
+		}
+		if !node.IsMaster {
+			return nil
 		}
 
-		masterId, err := s.r.IsNodeExists(ctx, ipport.Addr())
+		// join data is kept while other masters of HA cluster remain
+		masters, err := s.r.GetControlPlaneNodes(ctx, 1)
 		if err != nil {
 			return err
 		}
-
-		if masterId == node.ID {
+		if len(masters) == 0 {
 			s.l.Info("Removing cluster token and hash from DB")
-			err = s.r.DeleteClusterTokenIPAndHash(ctx, masterId)
+			err = s.r.DeleteClusterTokenIPAndHash(ctx, 1)
 			if err != nil {
 				return err
 			}
 		}
 
-		return nil
+		return workersErr
+	}
+}
+
+// isLastMaster checks whether the node is the only control-plane node of the cluster
+func (s *Service) isLastMaster(ctx context.Context, node internal.FullNode) (bool, error) {
+	if !node.IsMaster {
+		return false, nil
+	}
+	masters, err := s.r.GetControlPlaneNodes(ctx, 1)
+	if err != nil {
+		return false, err
+	}
+	return len(masters) <= 1, nil
+}
+
+func (s *Service) GetClusterSettings(ctx context.Context, clusterID int) (models.ClusterSettings, error) {
+	settings, err := s.r.GetClusterSettings(ctx, clusterID)
+	if err != nil {
+		return models.ClusterSettings{}, err
+	}
+	return settings.WithDefaults(), nil
+}
+
+func (s *Service) SetClusterSettings(ctx context.Context, clusterID int, settings models.ClusterSettings) error {
+	if err := k8s_installer.ValidateClusterSettings(settings); err != nil {
+		return fmt.Errorf("%w: %s", internal.ErrInvalidClusterSettings, err.Error())
+	}
+
+	isClusterExists, err := s.r.CheckClusterTokenIPAndHash(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+	if isClusterExists {
+		current, err := s.GetClusterSettings(ctx, clusterID)
+		if err != nil {
+			return err
+		}
+		newSettings := settings.WithDefaults()
+		if newSettings.CNI != current.CNI || newSettings.PodCIDR != current.PodCIDR || newSettings.ServiceCIDR != current.ServiceCIDR ||
+			newSettings.ControlPlaneEndpoint != current.ControlPlaneEndpoint || newSettings.ContainerRuntime != current.ContainerRuntime ||
+			newSettings.CgroupDriver != current.CgroupDriver {
+			return fmt.Errorf("%w: cni, cidrs, control-plane endpoint, container runtime and cgroup driver can't be changed after cluster creation", internal.ErrInvalidClusterSettings)
+		}
+	}
+	return s.r.SetClusterSettings(ctx, clusterID, settings)
+}
+
+func (s *Service) GetKubeadmConfigs(ctx context.Context, nodeID int) ([]internal.KubeadmConfig, error) {
+	return s.r.GetKubeadmConfigs(ctx, nodeID)
+}
+
+// RotateJoinToken replaces join token of created cluster with new one
+func (s *Service) RotateJoinToken(ctx context.Context, clusterID int) error {
+	isClusterExists, err := s.r.CheckClusterTokenIPAndHash(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+	if !isClusterExists {
+		return internal.ErrClusterNotExists
+	}
+	return s.k8sInstaller.RotateJoinToken(ctx)
+}
+
+func (s *Service) PlanAddNodeToCurrentCluster(ctx context.Context, id int, controlPlane bool) (internal.Plan, error) {
+	node, err := s.r.GetFullNode(ctx, id)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	return s.k8sInstaller.PlanInstallK8S(node, controlPlane)
+}
+
+func (s *Service) PlanRemoveNodeFromCurrentCluster(ctx context.Context, id int, destroyCluster bool) (internal.Plan, error) {
+	node, err := s.r.GetFullNode(ctx, id)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	lastMaster, err := s.isLastMaster(ctx, node)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	workers, err := s.workersToDestroy(ctx, node, destroyCluster)
+	if err != nil {
+		return internal.Plan{}, err
 	}
+	return s.k8sInstaller.PlanRemoveK8S(node, lastMaster, workers)
 }
 
 func (s *Service) GetProgress(ctx context.Context, socket *websocket.Conn) error {
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+// settingsRepository is repository keeping settings of one cluster, other methods aren't implemented
+type settingsRepository struct {
+	internal.Repository
+	clusterExists bool
+	settings      models.ClusterSettings
+}
+
+func (r *settingsRepository) CheckClusterTokenIPAndHash(ctx context.Context, clusterID int) (bool, error) {
+	return r.clusterExists, nil
+}
+
+func (r *settingsRepository) GetClusterSettings(ctx context.Context, clusterID int) (models.ClusterSettings, error) {
+	return r.settings, nil
+}
+
+func (r *settingsRepository) SetClusterSettings(ctx context.Context, clusterID int, settings models.ClusterSettings) error {
+	r.settings = settings
+	return nil
+}
+
+func TestSetClusterSettings(t *testing.T) {
+	tests := []struct {
+		name          string
+		clusterExists bool
+		settings      models.ClusterSettings
+		wantErr       error
+	}{
+		{name: "runtime before creation", settings: models.ClusterSettings{ContainerRuntime: models.ContainerRuntimeContainerd}},
+		{name: "cgroup driver before creation", settings: models.ClusterSettings{CgroupDriver: models.CgroupDriverCgroupfs}},
+		{name: "defaults of created cluster", clusterExists: true, settings: models.ClusterSettings{ContainerRuntime: models.DefaultContainerRuntime}},
+		{name: "drain timeout of created cluster", clusterExists: true, settings: models.ClusterSettings{DrainTimeout: "10m"}},
+		{name: "runtime of created cluster", clusterExists: true, settings: models.ClusterSettings{ContainerRuntime: models.ContainerRuntimeContainerd}, wantErr: internal.ErrInvalidClusterSettings},
+		{name: "cgroup driver of created cluster", clusterExists: true, settings: models.ClusterSettings{CgroupDriver: models.CgroupDriverCgroupfs}, wantErr: internal.ErrInvalidClusterSettings},
+		{name: "cni of created cluster", clusterExists: true, settings: models.ClusterSettings{CNI: models.CNICalico}, wantErr: internal.ErrInvalidClusterSettings},
+	}
+	for _, tt := range tests {
+		r := &settingsRepository{clusterExists: tt.clusterExists}
+		s := &Service{r: r, l: zap.NewNop()}
+		err := s.SetClusterSettings(context.Background(), 1, tt.settings)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(r.settings, tt.settings) {
+			t.Errorf("%s: settings aren't saved", tt.name)
+		}
+		if err != nil && !reflect.DeepEqual(r.settings, models.ClusterSettings{}) {
+			t.Errorf("%s: rejected settings are saved", tt.name)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	k8s_installer "github.com/Killer-Feature/PaaS_ClientSide/pkg/k8s-installer"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/taskmanager"
+)
+
+// addHistoryTask saves task to history and puts it in the taskmanager queue.
+// Returns id of the task in history
+func (s *Service) addHistoryTask(ctx context.Context, task internal.Task, node internal.FullNode, process func(historyID int) func(taskID taskmanager.ID) error) (int, error) {
+	task.Status = internal.STATUS_IN_QUEUE
+	historyID, err := s.r.AddTask(ctx, task)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = s.tm.AddTask(s.recordTask(historyID, process(historyID)), node.IP)
+	if err != nil {
+		_ = s.r.FinishTask(ctx, historyID, internal.STATUS_ERROR, err.Error())
+		return 0, err
+	}
+	return historyID, nil
+}
+
+// recordTask saves final status of the task to history. Returned error is used as source of truth
+// because task may fail without sending progress with error status
+func (s *Service) recordTask(historyID int, process func(taskID taskmanager.ID) error) func(taskID taskmanager.ID) error {
+	return func(taskID taskmanager.ID) error {
+		err := process(taskID)
+
+		status, taskErr := internal.STATUS_SUCCESS, ""
+		if err != nil {
+			status, taskErr = internal.STATUS_ERROR, err.Error()
+		}
+		_ = s.r.FinishTask(context.Background(), historyID, status, taskErr)
+		return err
+	}
+}
+
+// redactLog replaces bootstrap tokens and certificate key of the cluster in log delta of progress message. Installer
+// redacts secrets of commands it runs, the log is checked again before it is saved to history and replayed to new sockets
+func (s *Service) redactLog(log string) string {
+	if log == "" {
+		return log
+	}
+	var secrets []string
+	if certificateKey, err := s.r.GetClusterCertificateKey(context.Background(), 1); err == nil {
+		secrets = append(secrets, certificateKey)
+	}
+	return string(k8s_installer.RedactSecrets([]byte(log), secrets))
+}
+
+// recordProgress saves log delta of progress message to history, callers redact it by redactLog before
+func (s *Service) recordProgress(historyID int, status internal.TaskStatus, log string) {
+	ctx := context.Background()
+	if status == internal.STATUS_START {
+		_ = s.r.StartTask(ctx, historyID)
+	}
+	if log != "" {
+		_ = s.r.AppendTaskLog(ctx, historyID, log)
+	}
+}
+
+func (s *Service) GetTasks(ctx context.Context, filter internal.TaskFilter) ([]internal.Task, error) {
+	return s.r.GetTasks(ctx, filter)
+}
+
+func (s *Service) GetTask(ctx context.Context, id int) (internal.Task, error) {
+	return s.r.GetTask(ctx, id)
+}
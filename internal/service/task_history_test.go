@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
+)
+
+const (
+	testToken          = "abcdef.0123456789abcdef"
+	testCertificateKey = "f2a4ba2e5e12b0a73a1e38ad6a6c8a5c04fa3e4ad3f3ed5ac1ea3e5d8a9c7b16"
+)
+
+// kubeadmInitOutput is part of kubeadm init output uploading certificates
+const kubeadmInitOutput = "[upload-certs] Using certificate key:\n" + testCertificateKey + "\n" +
+	"  kubeadm join 10.0.0.10:6443 --token " + testToken + " \\\n" +
+	"\t--control-plane --certificate-key " + testCertificateKey + "\n"
+
+// historyRepository is repository keeping task log of one task and certificate key of the cluster,
+// other methods aren't implemented
+type historyRepository struct {
+	internal.Repository
+	log string
+}
+
+func (r *historyRepository) StartTask(ctx context.Context, id int) error {
+	return nil
+}
+
+func (r *historyRepository) AppendTaskLog(ctx context.Context, id int, log string) error {
+	r.log += log
+	return nil
+}
+
+func (r *historyRepository) GetClusterCertificateKey(ctx context.Context, clusterID int) (string, error) {
+	return testCertificateKey, nil
+}
+
+func TestProgressLogRedacted(t *testing.T) {
+	r := &historyRepository{}
+	s := &Service{r: r, l: zap.NewNop(), sm: socketmanager.NewSocketManager(zap.NewNop()), initMsg: newInitMessages()}
+	task := s.addonProgressTask("monitoring", 1, func(ctx context.Context, sendProgress func(percent int, status internal.TaskStatus, log string, err string), sendLog func(stream internal.LogStream, line string)) error {
+		sendProgress(50, internal.STATUS_IN_PROCESS, "$ sudo kubeadm init --upload-certs\n"+kubeadmInitOutput, "")
+		return nil
+	})
+	if err := task(0); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := map[string]string{"task history": r.log}
+	for _, msg := range s.initMsg.GetInitMessages() {
+		if payload, ok := msg.Payload.(internal.AddonProgressMsg); ok {
+			logs["init message"] = payload.Log
+		}
+	}
+	if len(logs) != 2 {
+		t.Fatalf("init message of add-on progress isn't pushed")
+	}
+	for name, log := range logs {
+		if !strings.Contains(log, "kubeadm join 10.0.0.10:6443") {
+			t.Errorf("%s misses kubeadm output:\n%s", name, log)
+		}
+		for _, secret := range []string{testToken, testCertificateKey} {
+			if strings.Contains(log, secret) {
+				t.Errorf("%s contains secret %q:\n%s", name, secret, log)
+			}
+		}
+	}
+}
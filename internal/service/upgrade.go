@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	k8s_installer "github.com/Killer-Feature/PaaS_ClientSide/pkg/k8s-installer"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/taskmanager"
+)
+
+// UpgradeCluster queues upgrade of all nodes of the cluster to Kubernetes version. Returns id of the task in history
+func (s *Service) UpgradeCluster(ctx context.Context, clusterID int, version string) (int, error) {
+	isClusterExists, err := s.r.CheckClusterTokenIPAndHash(ctx, clusterID)
+	if err != nil {
+		return 0, err
+	}
+	if !isClusterExists {
+		return 0, internal.ErrClusterNotExists
+	}
+
+	current, err := s.GetClusterSettings(ctx, clusterID)
+	if err != nil {
+		return 0, err
+	}
+	upgraded, err := k8s_installer.ValidateUpgrade(current, version)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", internal.ErrInvalidUpgrade, err.Error())
+	}
+
+	masters, err := s.r.GetControlPlaneNodes(ctx, clusterID)
+	if err != nil {
+		return 0, err
+	}
+	if len(masters) == 0 {
+		return 0, k8s_installer.ErrNoControlPlaneAvailable
+	}
+
+	return s.addClusterTask(ctx, opUpgrade, internal.Task{Type: internal.TASK_UPGRADE_CLUSTER, ClusterID: clusterID}, masters[0], func(historyID int) func(taskID taskmanager.ID) error {
+		return s.upgradeClusterProgressTask(context.Background(), upgraded, historyID)
+	})
+}
+
+func (s *Service) upgradeClusterProgressTask(ctx context.Context, upgraded models.ClusterSettings, historyID int) func(taskId taskmanager.ID) error {
+	return func(taskID taskmanager.ID) error {
+		sendProgress := func(nodeID int, percent int, status internal.TaskStatus, log string, err string) {
+			log = s.redactLog(log)
+			msg := socketmanager.Message{Type: internal.UpgradeClusterT, Payload: internal.UpgradeClusterProgressMsg{NodeID: nodeID, Status: status, Percent: percent, Log: log, Error: err}}
+			if status == internal.STATUS_ERROR || status == internal.STATUS_SUCCESS {
+				msg.MustSent = true
+			}
+			s.sm.Send(&msg)
+			s.initMsg.PushUpgradeCluster(nodeID, &msg)
+			// history task is started once for the whole cluster, nodes only append their logs
+			s.recordProgress(historyID, internal.STATUS_IN_PROCESS, log)
+		}
+		sendLog := func(nodeID int, stream internal.LogStream, line string) {
+			s.sm.Send(&socketmanager.Message{Type: internal.UpgradeClusterLogT, Payload: internal.TaskLogMsg{NodeID: nodeID, Stream: stream, Line: line}})
+		}
+
+		s.recordProgress(historyID, internal.STATUS_START, "")
+		return s.k8sInstaller.UpgradeCluster(ctx, upgraded, sendProgress, sendLog)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/Killer-Feature/PaaS_ClientSide/pkg/socketmanager"
 	"github.com/gorilla/websocket"
 	"net/netip"
+	"time"
 
 	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
 )
@@ -17,13 +18,25 @@ type Usecase interface {
 	GetClusterNodes(ctx context.Context) ([]Node, error)
 	AddNode(ctx context.Context, node FullNode) (int, error)
 	RemoveNode(ctx context.Context, id int) error
-	AddNodeToCurrentCluster(ctx context.Context, id int) (int, error)
+	AddNodeToCurrentCluster(ctx context.Context, id int, controlPlane bool) (int, error)
+	ProvisionCluster(ctx context.Context, masterID int, workerIDs []int, parallelism int) ([]int, error)
 	AddResource(ctx context.Context, rType ResourceType, name string) error
 	RemoveResource(ctx context.Context, rType ResourceType, name string) error
 	GetAdminConfig(ctx context.Context, clusterId int) (*models.AdminConfig, error)
 	GetResources(ctx context.Context) ([]Resource, error)
 	GetServices(ctx context.Context) ([]Service, error)
-	RemoveNodeFromCurrentCluster(ctx context.Context, id int) (int, error)
+	RemoveNodeFromCurrentCluster(ctx context.Context, id int, destroyCluster bool) (int, error)
+	PlanAddNodeToCurrentCluster(ctx context.Context, id int, controlPlane bool) (Plan, error)
+	PlanRemoveNodeFromCurrentCluster(ctx context.Context, id int, destroyCluster bool) (Plan, error)
+	GetClusterSettings(ctx context.Context, clusterID int) (models.ClusterSettings, error)
+	SetClusterSettings(ctx context.Context, clusterID int, settings models.ClusterSettings) error
+	GetTasks(ctx context.Context, filter TaskFilter) ([]Task, error)
+	GetTask(ctx context.Context, id int) (Task, error)
+	GetKubeadmConfigs(ctx context.Context, nodeID int) ([]KubeadmConfig, error)
+	RotateJoinToken(ctx context.Context, clusterID int) error
+	UpgradeCluster(ctx context.Context, clusterID int, version string) (int, error)
+	DestroyCluster(ctx context.Context, clusterID int) (int, error)
+	InstallMonitoring(ctx context.Context, clusterID int) (int, error)
 	GetProgress(ctx context.Context, socket *websocket.Conn) error
 	IsAdmin(ctx context.Context, session string) (bool, error)
 	Login(ctx context.Context, data LoginData) (string, error)
@@ -31,7 +44,19 @@ type Usecase interface {
 }
 
 var (
-	ErrNodeExists = errors.New("node with current ip exists")
+	ErrNodeExists   = errors.New("node with current ip exists")
+	ErrTaskNotFound = errors.New("task not found")
+
+	ErrClusterNotExists  = errors.New("cluster doesn't exist")
+	ErrInvalidProvision  = errors.New("invalid provisioning request")
+	ErrUpgradeInProgress = errors.New("cluster upgrade is already in progress")
+	ErrInvalidUpgrade    = errors.New("invalid upgrade")
+	ErrDestroyInProgress = errors.New("cluster destroying is already in progress")
+	ErrAddonInProgress   = errors.New("add-on installation is already in progress")
+	ErrClusterBusy       = errors.New("nodes or add-ons of the cluster are being changed")
+	ErrClusterHasWorkers = errors.New("the last master can't be removed while workers remain in the cluster, confirm destroying the cluster")
+
+	ErrInvalidClusterSettings = errors.New("invalid cluster settings")
 )
 
 type Node struct {
@@ -41,6 +66,7 @@ type Node struct {
 	Name      string         `json:"name"`
 	ClusterID int            `json:"clusterID"`
 	IsMaster  bool           `json:"isMaster"`
+	OS        string         `json:"os"`
 }
 
 type ResourceType int
@@ -86,6 +112,57 @@ const (
 	STATUS_SUCCESS    TaskStatus = "success"
 )
 
+type TaskType string
+
+const (
+	TASK_ADD_NODE_TO_CLUSTER      TaskType = "addNodeToCluster"
+	TASK_REMOVE_NODE_FROM_CLUSTER TaskType = "removeNodeFromCluster"
+	TASK_UPGRADE_CLUSTER          TaskType = "upgradeCluster"
+	TASK_DESTROY_CLUSTER          TaskType = "destroyCluster"
+	TASK_INSTALL_MONITORING       TaskType = "installMonitoring"
+)
+
+// Task is a record of task history, Log is filled only for a single task
+type Task struct {
+	ID         int        `json:"id"`
+	Type       TaskType   `json:"type"`
+	NodeID     int        `json:"nodeID"`
+	ClusterID  int        `json:"clusterID"`
+	Status     TaskStatus `json:"status"`
+	Error      string     `json:"error"`
+	Log        string     `json:"log,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// TaskFilter selects tasks from history, zero fields are not used for filtering
+type TaskFilter struct {
+	Type      TaskType
+	Status    TaskStatus
+	NodeID    int
+	ClusterID int
+	Since     time.Time
+	Limit     int
+}
+
+type KubeadmConfigKind string
+
+const (
+	KUBEADM_CONFIG_INIT KubeadmConfigKind = "init"
+	KUBEADM_CONFIG_JOIN KubeadmConfigKind = "join"
+)
+
+// KubeadmConfig is kubeadm config file rendered for the node, secrets are redacted in it
+type KubeadmConfig struct {
+	ID        int               `json:"id"`
+	NodeID    int               `json:"nodeID"`
+	ClusterID int               `json:"clusterID"`
+	Kind      KubeadmConfigKind `json:"kind"`
+	Config    string            `json:"config"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
 type AddNodeToClusterProgressMsg struct {
 	Log     string     `json:"log"`
 	Percent int        `json:"percent"`
@@ -102,12 +179,106 @@ type RemoveNodeFromClusterMsg struct {
 	NodeID  int        `json:"nodeID"`
 }
 
+// UpgradeClusterProgressMsg is progress of upgrade of a single node of the cluster
+type UpgradeClusterProgressMsg struct {
+	Log     string     `json:"log"`
+	Percent int        `json:"percent"`
+	Error   string     `json:"error"`
+	Status  TaskStatus `json:"status"`
+	NodeID  int        `json:"nodeID"`
+}
+
+// DestroyClusterProgressMsg is progress of reset of a single node, NodeID is 0 for progress of the whole cluster
+type DestroyClusterProgressMsg struct {
+	Log     string     `json:"log"`
+	Percent int        `json:"percent"`
+	Error   string     `json:"error"`
+	Status  TaskStatus `json:"status"`
+	NodeID  int        `json:"nodeID"`
+}
+
+// AddonProgressMsg is progress of installation of cluster add-on like monitoring
+type AddonProgressMsg struct {
+	Addon   string     `json:"addon"`
+	Log     string     `json:"log"`
+	Percent int        `json:"percent"`
+	Error   string     `json:"error"`
+	Status  TaskStatus `json:"status"`
+}
+
+// AddonLogMsg is a single line of remote command output of add-on installation
+type AddonLogMsg struct {
+	Addon  string    `json:"addon"`
+	Stream LogStream `json:"stream"`
+	Line   string    `json:"line"`
+}
+
+type LogStream string
+
+const (
+	STREAM_STDOUT LogStream = "stdout"
+	STREAM_STDERR LogStream = "stderr"
+)
+
+// TaskLogMsg is a single line of remote command output sent while the command is running
+type TaskLogMsg struct {
+	Stream LogStream `json:"stream"`
+	Line   string    `json:"line"`
+	NodeID int       `json:"nodeID"`
+}
+
 const (
-	AddNodeToClusterT      socketmanager.MessageType = "addNodeToCluster"
-	RemoveNodeFromClusterT socketmanager.MessageType = "removeNodeFromCluster"
-	MetricsT               socketmanager.MessageType = "Metrics"
+	AddNodeToClusterT         socketmanager.MessageType = "addNodeToCluster"
+	AddNodeToClusterLogT      socketmanager.MessageType = "addNodeToClusterLog"
+	RemoveNodeFromClusterT    socketmanager.MessageType = "removeNodeFromCluster"
+	RemoveNodeFromClusterLogT socketmanager.MessageType = "removeNodeFromClusterLog"
+	UpgradeClusterT           socketmanager.MessageType = "upgradeCluster"
+	UpgradeClusterLogT        socketmanager.MessageType = "upgradeClusterLog"
+	DestroyClusterT           socketmanager.MessageType = "destroyCluster"
+	DestroyClusterLogT        socketmanager.MessageType = "destroyClusterLog"
+	AddonT                    socketmanager.MessageType = "addon"
+	AddonLogT                 socketmanager.MessageType = "addonLog"
+	MetricsT                  socketmanager.MessageType = "Metrics"
 )
 
+type PlanStepType string
+
+const (
+	PLAN_STEP_COMMAND      PlanStepType = "command"
+	PLAN_STEP_HELM_CHART   PlanStepType = "helmChart"
+	PLAN_STEP_PORT_FORWARD PlanStepType = "portForward"
+	PLAN_STEP_REPOSITORY   PlanStepType = "repository"
+	// PLAN_STEP_KUBERNETES_API is a request to API server of the cluster
+	PLAN_STEP_KUBERNETES_API PlanStepType = "kubernetesAPI"
+	// PLAN_STEP_MANIFEST is server-side apply of rendered manifest
+	PLAN_STEP_MANIFEST PlanStepType = "manifest"
+	// PLAN_STEP_UPLOAD is file uploaded to the node by SFTP
+	PLAN_STEP_UPLOAD PlanStepType = "upload"
+)
+
+// PlanStep is a single step of an operation which is shown to user instead of running it.
+// Secrets are replaced with "<redacted>"
+type PlanStep struct {
+	Type        PlanStepType           `json:"type"`
+	Description string                 `json:"description,omitempty"`
+	Command     string                 `json:"command,omitempty"`
+	Condition   string                 `json:"condition,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Chart       string                 `json:"chart,omitempty"`
+	Values      map[string]interface{} `json:"values,omitempty"`
+	Manifest    string                 `json:"manifest,omitempty"`
+	Path        string                 `json:"path,omitempty"`
+	Mode        string                 `json:"mode,omitempty"`
+	Owner       string                 `json:"owner,omitempty"`
+	Content     string                 `json:"content,omitempty"`
+}
+
+type Plan struct {
+	NodeID int        `json:"nodeID"`
+	Role   string     `json:"role"`
+	Steps  []PlanStep `json:"steps"`
+}
+
 type LoginData struct {
 	User     string `json:"user"`
 	Password string `json:"password"`
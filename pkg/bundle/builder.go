@@ -0,0 +1,346 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"sigs.k8s.io/yaml"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+var kubeadmPackageRe = regexp.MustCompile(`^kubeadm[_-](\d+\.\d+\.\d+)-`)
+
+// ChartSource is chart of helm repository which is put into bundle, Repo is repository name used by the installer
+type ChartSource struct {
+	Repo string
+	URL  string
+	Name string
+}
+
+// Builder builds bundle on machine with access to the internet. The machine has to run the same distro as nodes
+// because packages are downloaded by its package manager, and it needs docker and kubeadm of the same minor version
+// which lists control plane images
+type Builder struct {
+	CommandLib cl.CommandLib
+	Distro     string
+	// MinorVersion is version of pkgs.k8s.io repository like "v1.30", VersionPin is package pattern like "1.30.*"
+	MinorVersion string
+	VersionPin   string
+	CNI          models.CNI
+	CNIVersion   string
+	// CNIManifestURL is downloaded and applied on nodes by AddFlannel or AddCalico
+	CNIManifestURL string
+	Charts         []ChartSource
+	Kubeadm        string
+	// Log receives output of commands run by the builder
+	Log io.Writer
+}
+
+// Build downloads everything to temporary directory and writes bundle archive to out
+func (b *Builder) Build(ctx context.Context, out string) error {
+	if b.CNI == models.CNICilium {
+		return fmt.Errorf("%w: %s network plugin isn't supported offline", ErrInvalidBundle, b.CNI)
+	}
+	dir, err := os.MkdirTemp("", "paas-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	m := &Manifest{
+		Distro:           b.Distro,
+		ContainerRuntime: models.ContainerRuntimeContainerd,
+		CNI:              b.CNI,
+		CNIVersion:       b.CNIVersion,
+	}
+	if err = b.downloadPackages(ctx, dir, m); err != nil {
+		return err
+	}
+	images, err := b.controlPlaneImages(ctx, m.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+	cniImages, err := b.downloadCNIManifest(ctx, dir, m)
+	if err != nil {
+		return err
+	}
+	images = append(images, cniImages...)
+	for _, source := range b.Charts {
+		chartImages, err := b.pullChart(dir, source, m)
+		if err != nil {
+			return fmt.Errorf("chart %s/%s: %w", source.Repo, source.Name, err)
+		}
+		images = append(images, chartImages...)
+	}
+	if err = b.saveImages(ctx, dir, images, m); err != nil {
+		return err
+	}
+	return writeArchive(out, dir, m)
+}
+
+func (b *Builder) downloadPackages(ctx context.Context, dir string, m *Manifest) error {
+	packagesDir := filepath.Join(dir, PackagesDir)
+	for _, command := range []cl.CommandAndParser{
+		b.CommandLib.DownloadK8SSigningKey(b.MinorVersion),
+		b.CommandLib.AddK8SRepo(b.MinorVersion),
+		b.CommandLib.SudoUpdate(),
+		b.CommandLib.DownloadPackages(packagesDir, b.VersionPin),
+	} {
+		if err := b.runLocal(ctx, command); err != nil && command.Condition != cl.Anyway {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(packagesDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			m.Packages = append(m.Packages, path.Join(PackagesDir, entry.Name()))
+		}
+		if match := kubeadmPackageRe.FindStringSubmatch(entry.Name()); match != nil {
+			m.KubernetesVersion = match[1]
+		}
+	}
+	if m.KubernetesVersion == "" {
+		return fmt.Errorf("%w: kubeadm package isn't downloaded", ErrInvalidBundle)
+	}
+	return nil
+}
+
+// controlPlaneImages lists images which kubeadm of version pulls, pause image is one of them
+func (b *Builder) controlPlaneImages(ctx context.Context, version string) ([]string, error) {
+	output, err := exec.CommandContext(ctx, b.Kubeadm, "config", "images", "list", "--kubernetes-version=v"+version).Output()
+	if err != nil {
+		return nil, fmt.Errorf("kubeadm config images list: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+func (b *Builder) downloadCNIManifest(ctx context.Context, dir string, m *Manifest) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.CNIManifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", b.CNIManifestURL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.CNIManifest = path.Join(CNIDir, path.Base(req.URL.Path))
+	if err = writeFile(filepath.Join(dir, m.CNIManifest), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return Images(data)
+}
+
+// pullChart downloads the latest version of chart and returns images of its manifests rendered with default values
+func (b *Builder) pullChart(dir string, source ChartSource, m *Manifest) ([]string, error) {
+	pullDir, err := os.MkdirTemp(dir, "pull-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(pullDir)
+
+	pull := action.NewPullWithOpts(action.WithConfig(new(action.Configuration)))
+	pull.Settings = cli.New()
+	pull.RepoURL = source.URL
+	pull.DestDir = pullDir
+	if _, err = pull.Run(source.Name); err != nil {
+		return nil, err
+	}
+	archives, err := filepath.Glob(filepath.Join(pullDir, "*.tgz"))
+	if err != nil || len(archives) != 1 {
+		return nil, fmt.Errorf("pulled archive isn't found in %s", pullDir)
+	}
+	ch, err := loader.Load(archives[0])
+	if err != nil {
+		return nil, err
+	}
+
+	chart := Chart{Repo: source.Repo, Name: source.Name, Version: ch.Metadata.Version}
+	chart.File = path.Join(ChartsDir, fmt.Sprintf("%s-%s-%s.tgz", chart.Repo, chart.Name, chart.Version))
+	if err = os.MkdirAll(filepath.Join(dir, ChartsDir), 0755); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(archives[0], filepath.Join(dir, chart.File)); err != nil {
+		return nil, err
+	}
+	m.Charts = append(m.Charts, chart)
+
+	cfg := &action.Configuration{Log: func(string, ...interface{}) {}}
+	install := action.NewInstall(cfg)
+	install.DryRun, install.ClientOnly, install.Replace = true, true, true
+	install.ReleaseName, install.Namespace = source.Name, "default"
+	if kubeVersion, err := chartutil.ParseKubeVersion("v" + m.KubernetesVersion); err == nil {
+		install.KubeVersion = kubeVersion
+	}
+	release, err := install.Run(ch, nil)
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	rendered := release.Manifest
+	for _, hook := range release.Hooks {
+		rendered += "\n---\n" + hook.Manifest
+	}
+	return Images([]byte(rendered))
+}
+
+// saveImages pulls images by docker and saves every image to its own tarball, so nodes import them one by one
+func (b *Builder) saveImages(ctx context.Context, dir string, images []string, m *Manifest) error {
+	sort.Strings(images)
+	for i, ref := range images {
+		if i > 0 && ref == images[i-1] {
+			continue
+		}
+		image := Image{Ref: ref, File: imageFile(ref)}
+		if err := os.MkdirAll(filepath.Join(dir, ImagesDir), 0755); err != nil {
+			return err
+		}
+		if err := b.run(ctx, cl.NewCmd("docker", "pull", ref).String()); err != nil {
+			return err
+		}
+		if err := b.run(ctx, cl.NewCmd("docker", "save", "-o", filepath.Join(dir, image.File), ref).String()); err != nil {
+			return err
+		}
+		m.Images = append(m.Images, image)
+	}
+	return nil
+}
+
+// runLocal runs command of the command library on this machine, file of the command is written by install
+// as it is uploaded to nodes
+func (b *Builder) runLocal(ctx context.Context, command cl.CommandAndParser) error {
+	if file := command.File; file != nil {
+		install := cl.NewCmd("install", "-D", "-m", fmt.Sprintf("%04o", file.Mode.Perm()))
+		if file.Owner != "" {
+			owner, group, _ := strings.Cut(file.Owner, ":")
+			install.Flag("owner", owner)
+			if group != "" {
+				install.Flag("group", group)
+			}
+		}
+		install.Arg("/dev/stdin", file.Path).Sudo()
+		if err := b.runStdin(ctx, install.String(), bytes.NewReader(file.Content)); err != nil {
+			return err
+		}
+		if command.Command == "" {
+			return nil
+		}
+	}
+	return b.run(ctx, string(command.Command))
+}
+
+func (b *Builder) run(ctx context.Context, command string) error {
+	return b.runStdin(ctx, command, nil)
+}
+
+// runStdin runs command by cl.Strict like commands run on nodes, so failed download isn't hidden by later lines
+func (b *Builder) runStdin(ctx context.Context, command string, stdin io.Reader) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", cl.Strict(command))
+	cmd.Stdin = stdin
+	cmd.Stdout, cmd.Stderr = b.Log, b.Log
+	if b.Log != nil {
+		_, _ = fmt.Fprintf(b.Log, "$ %s\n", command)
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}
+
+// writeArchive writes manifest and files of dir to gzipped tarball. Manifest is the first file, checksums of all
+// files are the last one, so nodes check extracted files by sha256sum -c
+func writeArchive(out, dir string, m *Manifest) (err error) {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	var sums strings.Builder
+	add := func(name string, size int64, r io.Reader) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		h := sha256.New()
+		if _, err := io.Copy(tw, io.TeeReader(r, h)); err != nil {
+			return err
+		}
+		sums.WriteString(hex.EncodeToString(h.Sum(nil)) + "  " + name + "\n")
+		return nil
+	}
+
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err = add(ManifestFile, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return err
+	}
+	err = filepath.WalkDir(dir, func(p string, entry os.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return add(filepath.ToSlash(rel), info.Size(), file)
+	})
+	if err != nil {
+		return err
+	}
+	if err = add(ChecksumsFile, int64(sums.Len()), strings.NewReader(sums.String())); err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
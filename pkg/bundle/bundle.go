@@ -0,0 +1,220 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+// Layout of bundle archive. Manifest is the first file of the archive, so it is read without reading images
+const (
+	ManifestFile  = "bundle.yaml"
+	ChecksumsFile = "SHA256SUMS"
+	PackagesDir   = "packages"
+	ChartsDir     = "charts"
+	ImagesDir     = "images"
+	CNIDir        = "cni"
+)
+
+var ErrInvalidBundle = errors.New("invalid offline bundle")
+
+// Manifest describes content of bundle, paths of files are relative to the root of the archive
+type Manifest struct {
+	// Distro is distro of nodes which packages are built for, like "ubuntu-22.04"
+	Distro string `json:"distro"`
+	// KubernetesVersion is "<major>.<minor>.<patch>" of kubeadm package, images of control plane are of this version
+	KubernetesVersion string                  `json:"kubernetesVersion"`
+	ContainerRuntime  models.ContainerRuntime `json:"containerRuntime"`
+	CNI               models.CNI              `json:"cni"`
+	CNIVersion        string                  `json:"cniVersion"`
+	// CNIManifest is manifest of network plugin which is applied instead of the one of GitHub
+	CNIManifest string   `json:"cniManifest"`
+	Packages    []string `json:"packages"`
+	Charts      []Chart  `json:"charts"`
+	Images      []Image  `json:"images"`
+}
+
+// Chart is archive of chart which is installed instead of chart of Repo
+type Chart struct {
+	Repo    string `json:"repo"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	File    string `json:"file"`
+}
+
+// Image is tarball of image saved by docker save, it is imported to container runtime of every node
+type Image struct {
+	Ref  string `json:"ref"`
+	File string `json:"file"`
+}
+
+// PauseImage returns reference of pause image of kubeadm, container runtime has to use it as sandbox image
+// because other one can't be pulled
+func (m *Manifest) PauseImage() string {
+	for _, image := range m.Images {
+		if strings.Contains(image.Ref, "/pause:") {
+			return image.Ref
+		}
+	}
+	return ""
+}
+
+// Validate checks that bundle fits cluster settings. Only containerd is supported because CRI-O has no command
+// importing image tarballs, cilium isn't supported because its CLI installs images by itself
+func (m *Manifest) Validate(settings models.ClusterSettings) error {
+	settings = settings.WithDefaults()
+	if m.KubernetesVersion != settings.KubernetesVersion && !strings.HasPrefix(m.KubernetesVersion, settings.KubernetesVersion+".") {
+		return fmt.Errorf("%w: bundle has kubernetes %s, cluster settings have %s", ErrInvalidBundle, m.KubernetesVersion, settings.KubernetesVersion)
+	}
+	if settings.ContainerRuntime != models.ContainerRuntimeContainerd || m.ContainerRuntime != models.ContainerRuntimeContainerd {
+		return fmt.Errorf("%w: only %s container runtime is supported offline", ErrInvalidBundle, models.ContainerRuntimeContainerd)
+	}
+	if settings.CNI == models.CNICilium || m.CNI != settings.CNI {
+		return fmt.Errorf("%w: bundle has %s network plugin, cluster settings have %s", ErrInvalidBundle, m.CNI, settings.CNI)
+	}
+	return nil
+}
+
+// ValidateDistro checks that packages of bundle are built for distro of the node
+func (m *Manifest) ValidateDistro(distro string) error {
+	if m.Distro != distro {
+		return fmt.Errorf("%w: bundle is built for %s, node is %s", ErrInvalidBundle, m.Distro, distro)
+	}
+	return nil
+}
+
+// Open reads manifest of bundle archive at path
+func Open(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr, err := newReader(f)
+	if err != nil {
+		return nil, err
+	}
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if header.Name != ManifestFile {
+		return nil, fmt.Errorf("%w: first file is %s, not %s", ErrInvalidBundle, header.Name, ManifestFile)
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+
+	var m Manifest
+	if err = yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidBundle, ManifestFile, err)
+	}
+	if m.Distro == "" || m.KubernetesVersion == "" || len(m.Packages) == 0 {
+		return nil, fmt.Errorf("%w: %s has no distro, kubernetes version or packages", ErrInvalidBundle, ManifestFile)
+	}
+	return &m, nil
+}
+
+// ExtractCharts extracts chart archives of bundle at archivePath to dir and returns their paths by "<repo>/<name>"
+// like helm references charts of repositories
+func ExtractCharts(archivePath, dir string) (map[string]string, error) {
+	m, err := Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(m.Charts))
+	for _, chart := range m.Charts {
+		files[chart.File] = chart.Repo + "/" + chart.Name
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tr, err := newReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	charts := make(map[string]string, len(m.Charts))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		ref, ok := files[header.Name]
+		if !ok {
+			continue
+		}
+		// file names of the manifest aren't trusted, only base name is used
+		target := filepath.Join(dir, path.Base(header.Name))
+		if err = writeFile(target, tr); err != nil {
+			return nil, err
+		}
+		charts[ref] = target
+		if len(charts) == len(files) {
+			break
+		}
+	}
+	for file, ref := range files {
+		if _, ok := charts[ref]; !ok {
+			return nil, fmt.Errorf("%w: chart %s isn't found in archive", ErrInvalidBundle, file)
+		}
+	}
+	return charts, nil
+}
+
+// Checksum returns hex encoded SHA-256 of file at path, bundle isn't uploaded to node which has it already
+func Checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func newReader(r io.Reader) (*tar.Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	return tar.NewReader(gz), nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,229 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+var testManifest = Manifest{
+	Distro:            "ubuntu-22.04",
+	KubernetesVersion: "1.30.4",
+	ContainerRuntime:  models.ContainerRuntimeContainerd,
+	CNI:               models.CNIFlannel,
+	CNIVersion:        "v0.25.6",
+	CNIManifest:       "cni/kube-flannel.yml",
+	Packages:          []string{"packages/kubeadm_1.30.4-1.1_amd64.deb"},
+	Charts:            []Chart{{Repo: "bitnami", Name: "grafana", Version: "11.3.20", File: "charts/bitnami-grafana-11.3.20.tgz"}},
+	Images:            []Image{{Ref: "registry.k8s.io/pause:3.9", File: "images/registry.k8s.io_pause_3.9.tar"}},
+}
+
+// writeTestBundle writes bundle of testManifest with file contents equal to their paths
+func writeTestBundle(t *testing.T) string {
+	dir := t.TempDir()
+	files := append([]string{testManifest.CNIManifest, testManifest.Charts[0].File, testManifest.Images[0].File}, testManifest.Packages...)
+	for _, file := range files {
+		if err := writeFile(filepath.Join(dir, "content", file), strings.NewReader(file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "bundle.tar.gz")
+	m := testManifest
+	if err := writeArchive(out, filepath.Join(dir, "content"), &m); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestOpen(t *testing.T) {
+	m, err := Open(writeTestBundle(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*m, testManifest) {
+		t.Errorf("Open() = %+v, want %+v", *m, testManifest)
+	}
+	if got, want := m.PauseImage(), "registry.k8s.io/pause:3.9"; got != want {
+		t.Errorf("PauseImage() = %s, want %s", got, want)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	dir := t.TempDir()
+	notGzip := filepath.Join(dir, "bundle.yaml")
+	if err := os.WriteFile(notGzip, []byte("distro: ubuntu-22.04\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(notGzip); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Open() of not gzipped file error = %v, want %v", err, ErrInvalidBundle)
+	}
+
+	noManifest := filepath.Join(dir, "bundle.tar.gz")
+	if err := writeArchive(noManifest, t.TempDir(), &Manifest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(noManifest); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Open() of empty manifest error = %v, want %v", err, ErrInvalidBundle)
+	}
+}
+
+func TestArchiveChecksums(t *testing.T) {
+	f, err := os.Open(writeTestBundle(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	sums := map[string]string{}
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == ChecksumsFile {
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				sum, name, _ := strings.Cut(line, "  ")
+				if sums[name] != sum {
+					t.Errorf("%s has checksum %s in %s, want %s", name, sum, ChecksumsFile, sums[name])
+				}
+				delete(sums, name)
+			}
+			continue
+		}
+		h := sha256.Sum256(data)
+		sums[header.Name] = hex.EncodeToString(h[:])
+	}
+	if names[0] != ManifestFile || names[len(names)-1] != ChecksumsFile {
+		t.Errorf("archive files are %v, want %s first and %s last", names, ManifestFile, ChecksumsFile)
+	}
+	if len(sums) > 0 {
+		t.Errorf("files %v have no checksums", sums)
+	}
+}
+
+func TestExtractCharts(t *testing.T) {
+	dir := t.TempDir()
+	charts, err := ExtractCharts(writeTestBundle(t), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"bitnami/grafana": filepath.Join(dir, "bitnami-grafana-11.3.20.tgz")}
+	if !reflect.DeepEqual(charts, want) {
+		t.Fatalf("ExtractCharts() = %v, want %v", charts, want)
+	}
+	data, err := os.ReadFile(want["bitnami/grafana"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testManifest.Charts[0].File {
+		t.Errorf("extracted chart is %q, want %q", data, testManifest.Charts[0].File)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	containerd := models.ContainerRuntimeContainerd
+	tests := []struct {
+		name     string
+		settings models.ClusterSettings
+		distro   string
+		err      error
+	}{
+		{name: "minor version", settings: models.ClusterSettings{KubernetesVersion: "1.30", ContainerRuntime: containerd}, distro: "ubuntu-22.04"},
+		{name: "patch version", settings: models.ClusterSettings{KubernetesVersion: "1.30.4", ContainerRuntime: containerd}, distro: "ubuntu-22.04"},
+		{name: "other patch version", settings: models.ClusterSettings{KubernetesVersion: "1.30.5", ContainerRuntime: containerd}, distro: "ubuntu-22.04", err: ErrInvalidBundle},
+		{name: "other minor version", settings: models.ClusterSettings{KubernetesVersion: "1.3", ContainerRuntime: containerd}, distro: "ubuntu-22.04", err: ErrInvalidBundle},
+		{name: "cri-o", settings: models.ClusterSettings{ContainerRuntime: models.ContainerRuntimeCRIO}, distro: "ubuntu-22.04", err: ErrInvalidBundle},
+		{name: "other cni", settings: models.ClusterSettings{CNI: models.CNICalico, ContainerRuntime: containerd}, distro: "ubuntu-22.04", err: ErrInvalidBundle},
+		{name: "other distro", settings: models.ClusterSettings{ContainerRuntime: containerd}, distro: "debian-12", err: ErrInvalidBundle},
+	}
+	for _, tt := range tests {
+		err := testManifest.Validate(tt.settings)
+		if err == nil {
+			err = testManifest.ValidateDistro(tt.distro)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: DaemonSet
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: install-cni
+        image: docker.io/flannel/flannel-cni-plugin:v1.5.1
+      containers:
+      - name: kube-flannel
+        image: docker.io/flannel/flannel:v0.25.6
+---
+# only comment
+---
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+spec:
+  image: docker.io/bitnami/prometheus:2.54.0
+  containers:
+  - name: sidecar
+    image: docker.io/flannel/flannel:v0.25.6
+`
+	images, err := Images([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docker.io/bitnami/prometheus:2.54.0", "docker.io/flannel/flannel-cni-plugin:v1.5.1", "docker.io/flannel/flannel:v0.25.6"}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("Images() = %v, want %v", images, want)
+	}
+	if got, want := imageFile("registry.k8s.io/coredns/coredns:v1.11.1"), "images/registry.k8s.io_coredns_coredns_v1.11.1.tar"; got != want {
+		t.Errorf("imageFile() = %s, want %s", got, want)
+	}
+}
+
+func TestBuilderRunStopsAtFailedLine(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't found")
+	}
+	var log bytes.Buffer
+	b := &Builder{Log: &log}
+	if err := b.run(context.Background(), "false\necho after"); err == nil {
+		t.Error("failed line before the last one doesn't fail the command")
+	}
+	if strings.HasSuffix(log.String(), "\nafter\n") {
+		t.Errorf("lines after failed one are run:\n%s", log.String())
+	}
+	if err := b.run(context.Background(), "false | cat"); err == nil {
+		t.Error("failed stage of pipeline doesn't fail the command")
+	}
+}
@@ -0,0 +1,58 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Images returns sorted references of images used by documents of manifest. Every "image" string field is
+// collected, so images of custom resources like Prometheus are found too
+func Images(manifest []byte) ([]string, error) {
+	found := make(map[string]struct{})
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	for {
+		var object interface{}
+		if err := decoder.Decode(&object); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode manifest: %w", err)
+		}
+		collectImages(object, found)
+	}
+
+	images := make([]string, 0, len(found))
+	for image := range found {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+func collectImages(value interface{}, found map[string]struct{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if image, ok := field.(string); ok && key == "image" && image != "" {
+				found[image] = struct{}{}
+				continue
+			}
+			collectImages(field, found)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectImages(item, found)
+		}
+	}
+}
+
+// imageFile returns path of image tarball in bundle, characters of reference which aren't allowed in file names
+// are replaced
+func imageFile(ref string) string {
+	return path.Join(ImagesDir, strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(ref)+".tar")
+}
@@ -26,6 +26,7 @@ import (
 	"helm.sh/helm/v3/pkg/downloader"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	"helm.sh/helm/v3/pkg/strvals"
 )
 
@@ -52,6 +53,10 @@ type HelmInstaller struct {
 
 	l        *zap.Logger
 	settings *cli.EnvSettings
+
+	// localCharts are chart archives by "<repo>/<chart>" installed instead of charts of repositories
+	chartsMu    sync.Mutex
+	localCharts map[string]string
 }
 
 func NewHelmInstaller(namespace, repoUrl, repoName string, logger *zap.Logger) (*HelmInstaller, error) {
@@ -199,6 +204,21 @@ func (hi *HelmInstaller) RepoUpdate() error {
 	return nil
 }
 
+// SetLocalCharts sets chart archives by "<repo>/<chart>" which are installed instead of charts of repositories,
+// e.g. charts of offline bundle. Nil map returns to repositories
+func (hi *HelmInstaller) SetLocalCharts(charts map[string]string) {
+	hi.chartsMu.Lock()
+	defer hi.chartsMu.Unlock()
+	hi.localCharts = charts
+}
+
+func (hi *HelmInstaller) localChart(ref string) (string, bool) {
+	hi.chartsMu.Lock()
+	defer hi.chartsMu.Unlock()
+	path, ok := hi.localCharts[ref]
+	return path, ok
+}
+
 // InstallChart installs chart
 func (hi *HelmInstaller) InstallChart(name, repo, chart string, args map[string]string) error {
 	actionConfig := new(action.Configuration)
@@ -213,9 +233,13 @@ func (hi *HelmInstaller) InstallChart(name, repo, chart string, args map[string]
 
 	//name, chart, err := client.NameAndChart(args)
 	client.ReleaseName = name
-	cp, err := client.ChartPathOptions.LocateChart(fmt.Sprintf("%s/%s", repo, chart), hi.settings)
-	if err != nil {
-		return err
+	ref := fmt.Sprintf("%s/%s", repo, chart)
+	cp, ok := hi.localChart(ref)
+	if !ok {
+		var err error
+		if cp, err = client.ChartPathOptions.LocateChart(ref, hi.settings); err != nil {
+			return err
+		}
 	}
 
 	hi.l.Debug("chart path", zap.String("chart_path", cp))
@@ -228,8 +252,8 @@ func (hi *HelmInstaller) InstallChart(name, repo, chart string, args map[string]
 	}
 
 	// Add args
-	if err := strvals.ParseInto(args["set"], vals); err != nil {
-		return errors.Wrap(err, "failed parsing --set data")
+	if err := parseArgsInto(args, vals); err != nil {
+		return err
 	}
 
 	// Check chart dependencies to make sure all are present in /charts
@@ -275,6 +299,22 @@ func (hi *HelmInstaller) InstallChart(name, repo, chart string, args map[string]
 	return nil
 }
 
+// ParseArgs returns chart values which are set by args
+func ParseArgs(args map[string]string) (map[string]interface{}, error) {
+	vals := map[string]interface{}{}
+	if err := parseArgsInto(args, vals); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+func parseArgsInto(args map[string]string, vals map[string]interface{}) error {
+	if err := strvals.ParseInto(args["set"], vals); err != nil {
+		return errors.Wrap(err, "failed parsing --set data")
+	}
+	return nil
+}
+
 func isChartInstallable(ch *chart.Chart) (bool, error) {
 	switch ch.Metadata.Type {
 	case "", "application":
@@ -299,6 +339,43 @@ func (hi *HelmInstaller) UninstallChart(name string) error {
 	return err
 }
 
+// ReleaseExists checks whether release is installed
+func (hi *HelmInstaller) ReleaseExists(name string) (bool, error) {
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(hi.config, hi.settings.Namespace(), os.Getenv("HELM_DRIVER"), hi.debug); err != nil {
+		return false, err
+	}
+	_, err := action.NewGet(actionConfig).Run(name)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// UninstallAllCharts uninstalls every release of the namespace, all releases are tried even if some fail
+func (hi *HelmInstaller) UninstallAllCharts() error {
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(hi.config, hi.settings.Namespace(), os.Getenv("HELM_DRIVER"), hi.debug); err != nil {
+		return err
+	}
+	releases, err := action.NewList(actionConfig).Run()
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, release := range releases {
+		if _, err = action.NewUninstall(actionConfig).Run(release.Name); err != nil {
+			hi.l.Error("failed uninstalling helm release", zap.String("release", release.Name), zap.Error(err))
+			errs = append(errs, fmt.Sprintf("%s: %s", release.Name, err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("failed uninstalling releases: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 type Resource struct {
 	Name          string
 	Status        string
@@ -0,0 +1,124 @@
+package k8s_installer
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+const (
+	flannelVersion = "v0.25.6"
+	calicoVersion  = "v3.28.1"
+	ciliumVersion  = "1.16.1"
+)
+
+var (
+	ErrUnsupportedCNI = errors.New("unsupported cni plugin")
+	ErrInvalidCIDR    = errors.New("invalid cidr")
+)
+
+// ValidateClusterSettings checks all cluster settings before they are saved or used for provisioning
+func ValidateClusterSettings(settings models.ClusterSettings) error {
+	if err := ValidateVersions(settings); err != nil {
+		return err
+	}
+	if err := validateNetwork(settings); err != nil {
+		return err
+	}
+	if err := validateKubeadmSettings(settings); err != nil {
+		return err
+	}
+	if err := validateDrainTimeout(settings); err != nil {
+		return err
+	}
+	if err := validateMonitoringSettings(settings); err != nil {
+		return err
+	}
+	if err := validateControlPlaneEndpoint(settings); err != nil {
+		return err
+	}
+	_, err := openBundle(settings)
+	return err
+}
+
+func validateNetwork(settings models.ClusterSettings) error {
+	settings = settings.WithDefaults()
+
+	switch settings.CNI {
+	case models.CNIFlannel, models.CNICalico, models.CNICilium:
+	default:
+		return fmt.Errorf("%w %q, supported are %s, %s and %s", ErrUnsupportedCNI, settings.CNI, models.CNIFlannel, models.CNICalico, models.CNICilium)
+	}
+
+	podCIDR, err := netip.ParsePrefix(settings.PodCIDR)
+	if err != nil || !podCIDR.Addr().Is4() {
+		return fmt.Errorf("%w: pod cidr %q must be IPv4 prefix", ErrInvalidCIDR, settings.PodCIDR)
+	}
+	serviceCIDR, err := netip.ParsePrefix(settings.ServiceCIDR)
+	if err != nil || !serviceCIDR.Addr().Is4() {
+		return fmt.Errorf("%w: service cidr %q must be IPv4 prefix", ErrInvalidCIDR, settings.ServiceCIDR)
+	}
+	if podCIDR.Overlaps(serviceCIDR) {
+		return fmt.Errorf("%w: pod cidr %s overlaps service cidr %s", ErrInvalidCIDR, podCIDR, serviceCIDR)
+	}
+	return nil
+}
+
+// CNIVersion returns version of network plugin which is installed on control plane
+func CNIVersion(cni models.CNI) string {
+	switch cni {
+	case models.CNICalico:
+		return calicoVersion
+	case models.CNICilium:
+		return ciliumVersion
+	default:
+		return flannelVersion
+	}
+}
+
+// cniCommands installs network plugin of the cluster on control plane
+func (installer *Installer) cniCommands(commandLib cl.CommandLib, settings models.ClusterSettings) []cl.CommandAndParser {
+	settings = settings.WithDefaults()
+	switch settings.CNI {
+	case models.CNICalico:
+		return []cl.CommandAndParser{commandLib.AddCalico(calicoVersion, settings.PodCIDR)}
+	case models.CNICilium:
+		return []cl.CommandAndParser{
+			commandLib.InstallCiliumCLI(),
+			commandLib.AddCilium(ciliumVersion, settings.PodCIDR),
+		}
+	default:
+		return []cl.CommandAndParser{commandLib.AddFlannel(flannelVersion, settings.PodCIDR)}
+	}
+}
+
+// cniResetCommands removes interfaces, iptables rules and state which network plugin of the cluster leaves after kubeadm reset
+func (installer *Installer) cniResetCommands(commandLib cl.CommandLib, settings models.ClusterSettings) []cl.CommandAndParser {
+	settings = settings.WithDefaults()
+	switch settings.CNI {
+	case models.CNICalico:
+		return []cl.CommandAndParser{
+			commandLib.DeleteCalicoLinks(),
+			commandLib.DeleteIptablesRules("cali"),
+			commandLib.RemoveCNIState("/var/lib/calico", "/var/run/calico"),
+		}
+	case models.CNICilium:
+		return []cl.CommandAndParser{
+			commandLib.DeleteCiliumLinks(),
+			commandLib.DeleteIptablesRules("cilium"),
+			commandLib.RemoveCNIState("/var/run/cilium"),
+		}
+	default:
+		return []cl.CommandAndParser{
+			commandLib.LinkDownCNI0(),
+			commandLib.IpconfigCNI0Down(),
+			commandLib.IpconfigFlannelDown(),
+			commandLib.BrctlDelbr(),
+			commandLib.DeleteFlannelLinks(),
+			commandLib.RemoveCNIState("/run/flannel"),
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package k8s_installer
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+func TestValidateClusterSettings(t *testing.T) {
+	tests := []struct {
+		settings models.ClusterSettings
+		err      error
+	}{
+		{settings: models.ClusterSettings{CNI: models.CNICilium, PodCIDR: "10.0.0.0/16", ServiceCIDR: "10.1.0.0/16"}},
+		{settings: models.ClusterSettings{CNI: "weave"}, err: ErrUnsupportedCNI},
+		{settings: models.ClusterSettings{PodCIDR: "10.96.0.0/16"}, err: ErrInvalidCIDR},
+		{settings: models.ClusterSettings{ServiceCIDR: "10.96.0.0"}, err: ErrInvalidCIDR},
+		{settings: models.ClusterSettings{ControlPlaneEndpoint: "k8s.example.com:6443"}},
+		{settings: models.ClusterSettings{ControlPlaneEndpoint: "10.0.0.10"}, err: ErrInvalidControlPlaneEndpoint},
+		{settings: models.ClusterSettings{ControlPlaneEndpoint: "10.0.0.10:0"}, err: ErrInvalidControlPlaneEndpoint},
+		{settings: models.ClusterSettings{DrainTimeout: "soon"}, err: ErrInvalidDrainTimeout},
+		{settings: models.ClusterSettings{DrainTimeout: "-1m"}, err: ErrInvalidDrainTimeout},
+		{settings: models.ClusterSettings{OfflineBundle: "/nonexistent/bundle.tar.gz"}, err: fs.ErrNotExist},
+	}
+	for _, tt := range tests {
+		if err := ValidateClusterSettings(tt.settings); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateClusterSettings(%+v) error = %v, want %v", tt.settings, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package k8s_installer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn/ssh"
+	"go.uber.org/zap"
+)
+
+// kubeadm expects hex encoded AES-256 key
+const certificateKeySize = 32
+
+var (
+	ErrInvalidControlPlaneEndpoint = errors.New("invalid control-plane endpoint")
+	ErrNoControlPlaneEndpoint      = errors.New("cluster was created without control-plane endpoint, masters can't be added")
+	ErrNoControlPlaneAvailable     = errors.New("no control-plane node is available")
+)
+
+func validateControlPlaneEndpoint(settings models.ClusterSettings) error {
+	if settings.ControlPlaneEndpoint == "" {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(settings.ControlPlaneEndpoint)
+	if err != nil || host == "" {
+		return fmt.Errorf("%w %q: expected <host>:<port>", ErrInvalidControlPlaneEndpoint, settings.ControlPlaneEndpoint)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return fmt.Errorf("%w %q: invalid port", ErrInvalidControlPlaneEndpoint, settings.ControlPlaneEndpoint)
+	}
+	return nil
+}
+
+// generateCertificateKey returns key which encrypts control-plane certificates uploaded to kubeadm-certs secret
+func generateCertificateKey() (string, error) {
+	key := make([]byte, certificateKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// ConnectToControlPlane connects to the first available master of the cluster. Masters are tried in order,
+// the one which created cluster goes first
+func (installer *Installer) ConnectToControlPlane(ctx context.Context, clusterID int) (client_conn.ClientConn, internal.FullNode, error) {
+	return installer.connectToControlPlane(ctx, clusterID, 0)
+}
+
+func (installer *Installer) connectToControlPlane(ctx context.Context, clusterID, excludeNodeID int) (client_conn.ClientConn, internal.FullNode, error) {
+	nodes, err := installer.r.GetControlPlaneNodes(ctx, clusterID)
+	if err != nil {
+		return nil, internal.FullNode{}, err
+	}
+
+	sshBuilder := ssh.NewSSHBuilder()
+	for _, node := range nodes {
+		if node.ID == excludeNodeID {
+			continue
+		}
+		cc, err := sshBuilder.CreateCC(node.IP, node.Login, node.Password)
+		if err != nil {
+			installer.l.Warn("control-plane node is unavailable", zap.Int("node", node.ID), zap.Error(err))
+			continue
+		}
+		return cc, node, nil
+	}
+	return nil, internal.FullNode{}, ErrNoControlPlaneAvailable
+}
+
+// uploadCerts uploads certificates of the cluster from running master, so the new master may download them while joining
+func (installer *Installer) uploadCerts(nodeID int, certificateKey string, log *taskLog, sendLog func(stream internal.LogStream, line string)) error {
+	conn, master, err := installer.connectToControlPlane(context.Background(), 1, nodeID)
+	if err != nil {
+		return err
+	}
+	defer func(conn client_conn.ClientConn) {
+		_ = conn.Close()
+	}(conn)
+
+	commandLib, err := nodeCommandLib(master)
+	if err != nil {
+		return err
+	}
+	command := commandLib.UploadCerts(certificateKey)
+	result, err := installer.exec(conn, command, sendLog)
+	log.pushPhase(fmt.Sprintf("upload certificates on master %s", master.IP.Addr()), nil)
+	log.pushResult(command, result)
+	return err
+}
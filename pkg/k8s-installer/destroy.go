@@ -0,0 +1,166 @@
+package k8s_installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn/ssh"
+)
+
+// progress of the whole cluster before and after reset of nodes
+const (
+	destroyPercentStart   = 10
+	destroyPercentReset   = 90
+	destroyPercentHelm    = 95
+	destroyPercentCleanup = 100
+)
+
+// destroyProgress aggregates progress of nodes reset in parallel into progress of the whole cluster
+type destroyProgress struct {
+	mu       sync.Mutex
+	percents map[int]int
+}
+
+func newDestroyProgress(nodes []internal.FullNode) *destroyProgress {
+	p := &destroyProgress{percents: make(map[int]int, len(nodes))}
+	for _, node := range nodes {
+		p.percents[node.ID] = 0
+	}
+	return p
+}
+
+// update saves percent of the node and returns percent of the whole cluster
+func (p *destroyProgress) update(nodeID, percent int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.percents[nodeID] = percent
+
+	sum := 0
+	for _, nodePercent := range p.percents {
+		sum += nodePercent
+	}
+	return destroyPercentStart + sum*(destroyPercentReset-destroyPercentStart)/(100*len(p.percents))
+}
+
+// DestroyCluster stops port-forwards, resets all nodes of the cluster in parallel, uninstalls what is left of helm
+// releases and cleans cluster state up. Progress of the whole cluster is sent with nodeID 0.
+// Releases are stored in the cluster, so usually they are gone with API server after reset
+func (installer *Installer) DestroyCluster(ctx context.Context, sendProgress func(nodeID int, percent int, status internal.TaskStatus, log string, err string), sendLog func(nodeID int, stream internal.LogStream, line string)) error {
+	allNodes, err := installer.r.GetNodes(ctx)
+	if err != nil {
+		return err
+	}
+	var nodes []internal.FullNode
+	for _, node := range allNodes {
+		if node.ClusterID == 1 {
+			nodes = append(nodes, node)
+		}
+	}
+	for _, node := range nodes {
+		sendProgress(node.ID, 0, internal.STATUS_IN_QUEUE, "", "")
+	}
+
+	log := newTaskLog(func(percent int, status internal.TaskStatus, log string, err string) {
+		sendProgress(0, percent, status, log, err)
+	})
+	log.send(1, internal.STATUS_START, "")
+
+	installer.StopPortForwards()
+	log.pushPhase("port-forwards stopped", nil)
+
+	log.send(destroyPercentStart, internal.STATUS_IN_PROCESS, "")
+
+	progress := newDestroyProgress(nodes)
+	var (
+		wg     sync.WaitGroup
+		errsMu sync.Mutex
+		errs   []error
+	)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node internal.FullNode) {
+			defer wg.Done()
+			nodeProgress := func(percent int, status internal.TaskStatus, log string, err string) {
+				sendProgress(node.ID, percent, status, log, err)
+				sendProgress(0, progress.update(node.ID, percent), internal.STATUS_IN_PROCESS, "", "")
+			}
+			nodeLog := func(stream internal.LogStream, line string) {
+				sendLog(node.ID, stream, line)
+			}
+			if err := installer.resetNode(node, nodeProgress, nodeLog); err != nil {
+				installer.l.Error("node reset failed while destroying cluster", zap.Int("node", node.ID), zap.Error(err))
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("node %s: %w", node.Name, err))
+				errsMu.Unlock()
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	// cluster is reset anyway, so failed uninstall doesn't stop destroying
+	if err = installer.hi.UninstallAllCharts(); apiServerGone(err) {
+		log.pushPhase("API server is gone with reset nodes, no helm releases are left", nil)
+	} else if err != nil {
+		installer.l.Warn("helm releases weren't uninstalled", zap.Error(err))
+		log.pushPhase("helm releases weren't uninstalled: "+err.Error(), nil)
+	} else {
+		log.pushPhase("helm releases uninstalled", nil)
+	}
+	log.send(destroyPercentHelm, internal.STATUS_IN_PROCESS, "")
+
+	// nodes which failed to reset are removed from the cluster too, their control plane doesn't exist anymore
+	for _, node := range nodes {
+		if err = installer.r.ResetNodeCluster(ctx, node.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err = installer.r.DeleteClusterTokenIPAndHash(ctx, 1); err != nil {
+		errs = append(errs, err)
+	}
+	if err = installer.r.DeleteResources(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err = os.Remove("./config"); err != nil && !os.IsNotExist(err) {
+		errs = append(errs, err)
+	}
+	log.pushPhase("cluster token, kubeconfig and resources removed", nil)
+
+	err = errors.Join(errs...)
+	if err != nil {
+		log.send(destroyPercentCleanup, internal.STATUS_ERROR, err.Error())
+		return err
+	}
+	log.send(destroyPercentCleanup, internal.STATUS_SUCCESS, "")
+	return nil
+}
+
+// apiServerGone reports whether err is failure to connect to API server, which doesn't run on reset nodes
+func apiServerGone(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.As(err, &netErr)
+}
+
+func (installer *Installer) resetNode(node internal.FullNode, sendProgress func(percent int, status internal.TaskStatus, log string, err string), sendLog func(stream internal.LogStream, line string)) error {
+	sendProgress(1, internal.STATUS_START, "", "")
+
+	sshBuilder := ssh.NewSSHBuilder()
+	conn, err := sshBuilder.CreateCC(node.IP, node.Login, node.Password)
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
+	defer func(conn client_conn.ClientConn) {
+		_ = conn.Close()
+	}(conn)
+
+	return installer.RemoveK8S(conn, node, true, sendProgress, sendLog)
+}
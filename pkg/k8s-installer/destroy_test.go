@@ -0,0 +1,55 @@
+package k8s_installer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+)
+
+func TestDestroyProgress(t *testing.T) {
+	progress := newDestroyProgress([]internal.FullNode{{ID: 1}, {ID: 2}})
+	tests := []struct {
+		nodeID  int
+		percent int
+		want    int
+	}{
+		{nodeID: 1, percent: 0, want: destroyPercentStart},
+		{nodeID: 1, percent: 100, want: 50},
+		{nodeID: 2, percent: 50, want: 70},
+		{nodeID: 2, percent: 100, want: destroyPercentReset},
+	}
+	for _, tt := range tests {
+		if got := progress.update(tt.nodeID, tt.percent); got != tt.want {
+			t.Errorf("update(%d, %d) = %d, want %d", tt.nodeID, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestAPIServerGone(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	_, refused := http.Get("https://" + addr + "/api/v1/namespaces/default/secrets")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil},
+		{name: "connection refused", err: fmt.Errorf("list releases: %w", refused), want: true},
+		{name: "forbidden", err: errors.New(`secrets is forbidden: User "admin" cannot list resource "secrets"`)},
+	}
+	for _, tt := range tests {
+		if got := apiServerGone(tt.err); got != tt.want {
+			t.Errorf("%s: apiServerGone(%v) = %t, want %t", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
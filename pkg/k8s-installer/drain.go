@@ -0,0 +1,113 @@
+package k8s_installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/kubectl/pkg/drain"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+)
+
+var ErrInvalidDrainTimeout = errors.New("invalid drain timeout")
+
+func validateDrainTimeout(settings models.ClusterSettings) error {
+	settings = settings.WithDefaults()
+	timeout, err := time.ParseDuration(settings.DrainTimeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("%w %q: expected positive duration like 5m", ErrInvalidDrainTimeout, settings.DrainTimeout)
+	}
+	return nil
+}
+
+// nodeName returns name of the node in the cluster, kubeadm registers nodes with lowercase hostname
+func (installer *Installer) nodeName(conn client_conn.ClientConn, commandLib cl.CommandLib) (string, error) {
+	hostname, err := installer.output(conn, commandLib.Hostname())
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(string(hostname))), nil
+}
+
+func (installer *Installer) kubeClient() (kubernetes.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", "./config")
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// drainNode cordons the node and evicts its pods. Evictions respect PodDisruptionBudgets and are retried
+// until timeout. Returns false when the node isn't registered in the cluster
+func (installer *Installer) drainNode(ctx context.Context, client kubernetes.Interface, name string, timeout time.Duration, out io.Writer) (bool, error) {
+	node, err := client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	helper := &drain.Helper{
+		Ctx:    ctx,
+		Client: client,
+		// pods of terminationGracePeriodSeconds are used
+		GracePeriodSeconds:  -1,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  true,
+		Timeout:             timeout,
+		Out:                 out,
+		ErrOut:              out,
+		OnPodDeletedOrEvicted: func(pod *corev1.Pod, usingEviction bool) {
+			verb := "deleted"
+			if usingEviction {
+				verb = "evicted"
+			}
+			_, _ = fmt.Fprintf(out, "pod %s/%s %s\n", pod.Namespace, pod.Name, verb)
+		},
+	}
+
+	if err = drain.RunCordonOrUncordon(helper, node, true); err != nil {
+		return true, err
+	}
+	_, _ = fmt.Fprintf(out, "node %s cordoned\n", name)
+	if err = drain.RunNodeDrain(helper, name); err != nil {
+		return true, err
+	}
+	_, _ = fmt.Fprintf(out, "node %s drained\n", name)
+	return true, nil
+}
+
+// uncordonNode marks the node schedulable again after upgrade
+func (installer *Installer) uncordonNode(ctx context.Context, client kubernetes.Interface, name string, out io.Writer) error {
+	node, err := client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	helper := &drain.Helper{Ctx: ctx, Client: client, Out: out, ErrOut: out}
+	if err = drain.RunCordonOrUncordon(helper, node, false); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "node %s uncordoned\n", name)
+	return nil
+}
+
+// deleteNode deletes Node object left in the cluster after kubeadm reset
+func (installer *Installer) deleteNode(ctx context.Context, client kubernetes.Interface, name string) error {
+	err := client.CoreV1().Nodes().Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
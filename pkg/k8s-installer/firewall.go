@@ -0,0 +1,31 @@
+package k8s_installer
+
+import (
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+// firewallPorts returns ports of Kubernetes components, network plugin of the cluster and MetalLB speaker
+// which have to be reachable from other nodes
+func firewallPorts(settings models.ClusterSettings, controlPlane bool) []string {
+	settings = settings.WithDefaults()
+
+	// kubelet, NodePort services and MetalLB memberlist
+	ports := []string{"10250/tcp", "30000-32767/tcp", "7946/tcp", "7946/udp"}
+	if controlPlane {
+		// kube-apiserver, etcd, kube-controller-manager and kube-scheduler
+		ports = append(ports, "6443/tcp", "2379-2380/tcp", "10257/tcp", "10259/tcp")
+	}
+
+	switch settings.CNI {
+	case models.CNICalico:
+		// BGP, VXLAN and Typha
+		ports = append(ports, "179/tcp", "4789/udp", "5473/tcp")
+	case models.CNICilium:
+		// VXLAN and health checks
+		ports = append(ports, "8472/udp", "4240/tcp")
+	default:
+		// VXLAN
+		ports = append(ports, "8472/udp")
+	}
+	return ports
+}
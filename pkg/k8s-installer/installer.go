@@ -6,11 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/netip"
 	"net/url"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,15 +20,21 @@ import (
 	"k8s.io/client-go/transport/spdy"
 
 	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
 	"github.com/Killer-Feature/PaaS_ClientSide/pkg/helm"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/manifests"
 	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
-	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/distro"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/offline"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/remote_exec"
 	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
 	"go.uber.org/zap"
 )
 
 const (
 	LOG_INITIAL_SIZE = 2048
+
+	grafanaAdminPassword = "admin"
 )
 
 var (
@@ -38,136 +44,218 @@ var (
 	}
 )
 
+// chartRelease describes helm chart of cluster add-on, chart name is used as resource type of the release
+type chartRelease struct {
+	name  string
+	repo  string
+	chart string
+	args  map[string]string
+}
+
+var (
+	metallbRelease        = chartRelease{name: "metallb", repo: "metallb", chart: "metallb"}
+	nginxIngressRelease   = chartRelease{name: "nginx-ingress-controller", repo: "bitnami", chart: "nginx-ingress-controller"}
+	grafanaRelease        = chartRelease{name: "grafana", repo: "bitnami", chart: "grafana", args: grafanaArgs}
+	prometheusRelease     = chartRelease{name: "prometheus", repo: "bitnami", chart: "kube-prometheus"}
+	grafanaPortForward    = portForward{namespace: "default", appName: "grafana", portLocal: "3000", portRemote: "3000"}
+	prometheusPortForward = portForward{namespace: "default", appName: "prometheus", portLocal: "9090", portRemote: "9090"}
+)
+
+type portForward struct {
+	namespace  string
+	appName    string
+	portLocal  string
+	portRemote string
+}
+
 type Installer struct {
 	r  internal.Repository
 	l  *zap.Logger
 	hi *helm.HelmInstaller
+
+	// tokenMu serializes checks and refreshes of cluster join token
+	tokenMu sync.Mutex
+	// initMu is held from check of cluster existence until kubeadm init finishes,
+	// so nodes installed concurrently can't initialize two clusters
+	initMu sync.Mutex
+
+	// portForwards holds stop channels of running port-forwards by app name
+	pfMu         sync.Mutex
+	portForwards map[string]chan struct{}
 }
 
 func NewInstaller(l *zap.Logger, r internal.Repository, hi *helm.HelmInstaller) *Installer {
 	return &Installer{
-		r:  r,
-		l:  l,
-		hi: hi,
+		r:            r,
+		l:            l,
+		hi:           hi,
+		portForwards: make(map[string]chan struct{}),
 	}
 }
 
-func (installer *Installer) installKubeadm() []cl.CommandAndParser {
-	commandLib := ubuntu.Ubuntu2004CommandLib{}
+// nodeCommandLib returns command library of the node's distro
+func nodeCommandLib(node internal.FullNode) (cl.CommandLib, error) {
+	return distro.CommandLib(distro.Distro(node.OS))
+}
 
-	commands := []cl.CommandAndParser{
+func (installer *Installer) installKubeadm(commandLib cl.CommandLib, settings models.ClusterSettings) ([]cl.CommandAndParser, error) {
+	settings = settings.WithDefaults()
+	k8sVersion, err := ParseVersion(settings.KubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := runtimeCommands(commandLib, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []cl.CommandAndParser
+	if offlineLib, ok := commandLib.(*offline.CommandLib); ok {
+		commands = append(commands, offlineLib.ExtractBundle())
+	}
+	commands = append(commands,
 		commandLib.SudoUpdate(),
 		commandLib.SudoFullUpgrade(),
-		commandLib.AddCRIORepos(),
-		commandLib.ImportGPGKey(),
-		commandLib.SudoUpdate(),
-		commandLib.InstallCRIO(),
-		commandLib.StartCRIO(),
-		commandLib.DisableSWAP(),
 		commandLib.InstallUtils(),
-		commandLib.DownloadGoogleCloudSigningKey(),
-		commandLib.AddK8SRepo(),
+	)
+	commands = append(commands, commandLib.PrepareNode()...)
+	commands = append(commands, runtime...)
+	commands = append(commands,
+		commandLib.DisableSWAP(),
+		commandLib.DownloadK8SSigningKey(k8sVersion.MinorString()),
+		commandLib.AddK8SRepo(k8sVersion.MinorString()),
 		commandLib.SudoUpdate(),
-		commandLib.InstallKubeadm(),
+		commandLib.InstallKubeadm(k8sVersion.PackagePin()),
 		commandLib.SetModprobe(),
 		commandLib.SetIpForward(),
-	}
-	return commands
+		commandLib.VerifyNodeSettings(),
+	)
+	return commands, nil
 }
 
-func (installer *Installer) kubeadmInit() []cl.CommandAndParser {
-	commandLib := ubuntu.Ubuntu2004CommandLib{}
-
+// kubeadmInit uploads rendered kubeadm config to the node and creates cluster from it. Certificate key of
+// the config is printed by kubeadm init uploading certificates, so it is secret of the command
+func (installer *Installer) kubeadmInit(commandLib cl.CommandLib, settings models.ClusterSettings, config, certificateKey string, nodeID int) []cl.CommandAndParser {
+	settings = settings.WithDefaults()
+	init := commandLib.InitKubeadm(kubeadmInitConfigPath, settings.ControlPlaneEndpoint != "", installer.parseKubeadmInit(nodeID))
+	if certificateKey != "" {
+		init.Secrets = append(init.Secrets, certificateKey)
+	}
 	commands := []cl.CommandAndParser{
-		commandLib.InitKubeadm(installer.parseKubeadmInit),
+		commandLib.OpenPorts(firewallPorts(settings, true)...),
+		commandLib.WriteFile(kubeadmInitConfigPath, config, kubeadmConfigFileMode),
+		init,
 		commandLib.AddKubeConfig(),
 		commandLib.UntaintControlPlane(),
-		commandLib.AddFlannel(),
-		commandLib.InstallHelm(),
-		commandLib.AddBitnamiRepo(),
-		commandLib.InstallPrometheus(),
 	}
-	return commands
+	return append(commands, installer.cniCommands(commandLib, settings)...)
 }
 
-func (installer *Installer) kubeadmCreateGrafana(hostname string) []cl.CommandAndParser {
-	commandLib := ubuntu.Ubuntu2004CommandLib{}
-
-	var userID int
-	switch hostname {
-	case "vk-edu-diploma-kf-1-2-2-15gb":
-		userID = 1
-	case "vk-edu-diploma-kf-2-2-2-20gb":
-		userID = 2
-	case "vk-edu-diploma-kf-3-2-2-20gb":
-		userID = 3
-	default:
-	}
-
+func (installer *Installer) kubeadmReset(commandLib cl.CommandLib, settings models.ClusterSettings) []cl.CommandAndParser {
 	commands := []cl.CommandAndParser{
-		commandLib.AddStorageClass(),
-		commandLib.AddGrafanaPV(hostname),
-		//commandLib.AddPostgresPV(hostname, 1),
-		//commandLib.AddPostgresPV(hostname, 2),
-		commandLib.AddGrafanaIngress(userID),
-		commandLib.AddGrafanaDatasourceSecret(),
-		commandLib.AddGrafanaDashboardConfigMap(),
-		commandLib.CreateFolderForPV(),
+		commandLib.KubeadmReset(),
+		commandLib.StopKubelet(),
+		stopRuntime(commandLib, settings),
+		commandLib.RemoveFiles(kubeadmConfigDir),
+		// ports of control plane include ports of workers, so they are closed whichever role the node had
+		commandLib.ClosePorts(firewallPorts(settings, true)...),
 	}
-	return commands
+	return append(commands, installer.cniResetCommands(commandLib, settings)...)
 }
 
-func (installer *Installer) kubeadmReset() []cl.CommandAndParser {
-	commandLib := ubuntu.Ubuntu2004CommandLib{}
-
+// kubeadmJoin uploads rendered kubeadm config to the node and joins it to cluster.
+// Kubectl is configured on masters like on the first one
+func (installer *Installer) kubeadmJoin(commandLib cl.CommandLib, settings models.ClusterSettings, config string, controlPlane bool) []cl.CommandAndParser {
 	commands := []cl.CommandAndParser{
-		commandLib.KubeadmReset(),
-		commandLib.StopKubelet(),
-		commandLib.StopCRIO(),
-		commandLib.LinkDownCNI0(),
-		commandLib.IpconfigCNI0Down(),
-		commandLib.IpconfigFlannelDown(),
-		commandLib.BrctlDelbr(),
+		commandLib.OpenPorts(firewallPorts(settings, controlPlane)...),
+		commandLib.WriteFile(kubeadmJoinConfigPath, config, kubeadmConfigFileMode),
+		commandLib.KubeadmJoin(kubeadmJoinConfigPath),
+	}
+	if controlPlane {
+		commands = append(commands, commandLib.AddKubeConfig())
 	}
 	return commands
 }
 
-func (installer *Installer) kubeadmJoin(token, ip, hash string) []cl.CommandAndParser {
-	commandLib := ubuntu.Ubuntu2004CommandLib{}
+var matchRe = regexp.MustCompile(`(?P<hostport>[a-z0-9-_:.]*) --token (?P<token>[a-z0-9-_.]*) \\\n\t--discovery-token-ca-cert-hash (?P<hash>[a-z0-9-:]*)`)
 
-	return []cl.CommandAndParser{
-		commandLib.KubeadmJoin(netip.MustParseAddrPort(ip), token, hash),
-	}
-}
+// parseKubeadmInit saves join data printed by kubeadm init and marks node which created cluster as master
+func (installer *Installer) parseKubeadmInit(nodeID int) cl.Parser {
+	return func(result cl.Result, extraData interface{}) error {
+		outputstr := string(result.Stdout)
+		outputstrs := strings.Split(outputstr, "kubeadm join ")
+		if len(outputstrs) < 2 {
+			return fmt.Errorf("no join command in kubeadm init output")
+		}
+		matchMap := make(map[string]string, len(matchRe.SubexpNames()))
+		match := matchRe.FindStringSubmatch(outputstrs[1])
+		if len(match) == 0 {
+			return fmt.Errorf("no match for regexp")
+		}
 
-var matchRe = regexp.MustCompile(`(?P<hostport>[a-z0-9-_:.]*) --token (?P<token>[a-z0-9-_.]*) \\\n\t--discovery-token-ca-cert-hash (?P<hash>[a-z0-9-:]*)`)
+		for i, group := range matchRe.SubexpNames() {
+			matchMap[group] = match[i]
+		}
 
-func (installer *Installer) parseKubeadmInit(output []byte, extraData interface{}) error {
-	outputstr := string(output)
-	outputstrs := strings.Split(outputstr, "kubeadm join ")
-	matchMap := make(map[string]string, len(matchRe.SubexpNames()))
-	match := matchRe.FindStringSubmatch(outputstrs[1])
-	if len(match) == 0 {
-		return fmt.Errorf("no match for regexp")
+		return installer.r.AddClusterTokenIPAndHash(context.Background(), 1, nodeID, matchMap["token"], matchMap["hostport"], matchMap["hash"])
 	}
+}
 
-	for i, group := range matchRe.SubexpNames() {
-		matchMap[group] = match[i]
+// InstallK8S creates cluster on the node or joins it to existing cluster as worker or, when controlPlane is set, as additional master.
+// Nodes installed while the cluster is being created wait for kubeadm init and join the created cluster
+func (installer *Installer) InstallK8S(conn client_conn.ClientConn, node internal.FullNode, controlPlane bool, sendProgress func(percent int, status internal.TaskStatus, log string, err string), sendLog func(stream internal.LogStream, line string)) error {
+	installer.initMu.Lock()
+	initLocked := true
+	unlockInit := func() {
+		if initLocked {
+			initLocked = false
+			installer.initMu.Unlock()
+		}
 	}
+	defer unlockInit()
 
-	return installer.r.AddClusterTokenIPAndHash(context.Background(), 1, matchMap["token"], matchMap["hostport"], matchMap["hash"])
-}
+	nodeid, nodeIP := node.ID, node.IP.Addr().String()
+	commandLib, err := nodeCommandLib(node)
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
 
-func (installer *Installer) InstallK8S(conn client_conn.ClientConn, nodeid int, nodeIP string, sendProgress func(percent int, status internal.TaskStatus, log string, err string)) error {
+	isClusterExists, err := installer.r.CheckClusterTokenIPAndHash(context.Background(), 1)
+	if err != nil {
+		return err
+	}
+	if isClusterExists {
+		unlockInit()
+	}
 
-	kubeadmInstallCommands := installer.installKubeadm()
+	settings, err := installer.r.GetClusterSettings(context.Background(), 1)
+	if err != nil {
+		return err
+	}
+	if isClusterExists {
+		err = installer.validateJoinVersions(settings)
+	} else {
+		err = ValidateClusterSettings(settings)
+	}
+	if err == nil {
+		err = validateNodeCgroupDriver(commandLib, settings)
+	}
+	if err == nil {
+		commandLib, err = offlineCommandLib(commandLib, node, settings)
+	}
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
 
-	isClusterExists, err := installer.r.CheckClusterTokenIPAndHash(context.Background(), 1)
+	kubeadmInstallCommands, err := installer.installKubeadm(commandLib, settings)
 	if err != nil {
 		return err
 	}
 
-	commandNumber := 20
+	// set when commands of the node are known, distros differ in number of commands
+	commandNumber := 1
 	percent, k := 1, 1
 	percentNext := func() int {
 		percent = (k*100 - 1) / commandNumber
@@ -175,228 +263,356 @@ func (installer *Installer) InstallK8S(conn client_conn.ClientConn, nodeid int,
 		return percent
 	}
 
+	log := newTaskLog(sendProgress)
+
 	if isClusterExists {
+		if err = installer.ensureJoinToken(context.Background(), nodeid); err != nil {
+			log.send(1, internal.STATUS_ERROR, err.Error())
+			return err
+		}
+	}
+
+	joinControlPlane := isClusterExists && controlPlane
+	if joinControlPlane {
 		token, ip, hash, err := installer.r.GetClusterTokenIPAndHash(context.Background(), 1)
 		if err != nil {
 			return err
 		}
+		certificateKey, err := installer.r.GetClusterCertificateKey(context.Background(), 1)
+		if err != nil {
+			return err
+		}
+		if settings.ControlPlaneEndpoint == "" || certificateKey == "" {
+			log.send(1, internal.STATUS_ERROR, ErrNoControlPlaneEndpoint.Error())
+			return ErrNoControlPlaneEndpoint
+		}
+
+		config, err := installer.prepareJoinConfig(nodeid, settings.ContainerRuntime, ip, token, hash, certificateKey)
+		if err != nil {
+			log.send(1, internal.STATUS_ERROR, err.Error())
+			return err
+		}
+
+		installer.l.Info("Adding new master to cluster")
+		if err = installer.uploadCerts(nodeid, certificateKey, log, sendLog); err != nil {
+			log.send(1, internal.STATUS_ERROR, err.Error())
+			return err
+		}
+		kubeadmInstallCommands = append(kubeadmInstallCommands, installer.kubeadmJoin(commandLib, settings, config, true)...)
+	} else if isClusterExists {
+		token, ip, hash, err := installer.r.GetClusterTokenIPAndHash(context.Background(), 1)
+		if err != nil {
+			return err
+		}
+		config, err := installer.prepareJoinConfig(nodeid, settings.ContainerRuntime, ip, token, hash, "")
+		if err != nil {
+			log.send(1, internal.STATUS_ERROR, err.Error())
+			return err
+		}
 		installer.l.Info("Adding new worker to cluster")
-		kubeadmInstallCommands = append(kubeadmInstallCommands, installer.kubeadmJoin(token, ip, hash)...)
+		kubeadmInstallCommands = append(kubeadmInstallCommands, installer.kubeadmJoin(commandLib, settings, config, false)...)
 	} else {
+		var certificateKey string
+		if settings.ControlPlaneEndpoint != "" {
+			if certificateKey, err = generateCertificateKey(); err != nil {
+				return err
+			}
+			if err = installer.r.SetClusterCertificateKey(context.Background(), 1, certificateKey); err != nil {
+				return err
+			}
+		}
+		config, err := installer.prepareInitConfig(nodeid, settings, certificateKey)
+		if err != nil {
+			log.send(1, internal.STATUS_ERROR, err.Error())
+			return err
+		}
 		installer.l.Info("Adding new control plane to cluster")
-		kubeadmInstallCommands = append(kubeadmInstallCommands, installer.kubeadmInit()...)
-		commandNumber = 38
+		kubeadmInstallCommands = append(kubeadmInstallCommands, installer.kubeadmInit(commandLib, settings, config, certificateKey, nodeid)...)
 	}
 
-	log := make([]byte, 0, LOG_INITIAL_SIZE)
+	// progress is counted by steps which report it: bundle upload, node commands and setup of created cluster
+	var setupSteps []clusterSetupStep
+	if !isClusterExists {
+		setupSteps = installer.clusterSetupSteps(conn, commandLib, settings, nodeIP, log, sendLog)
+	}
+	commandNumber = len(kubeadmInstallCommands) + len(setupSteps)
+	offlineLib, isOffline := commandLib.(*offline.CommandLib)
+	if isOffline {
+		commandNumber++
+		if err = installer.uploadBundle(conn, offlineLib, settings.OfflineBundle, log); err != nil {
+			log.send(percentNext(), internal.STATUS_ERROR, err.Error())
+			installer.l.Error("offline bundle upload failed", zap.Error(err))
+			return err
+		}
+		log.send(percentNext(), internal.STATUS_IN_PROCESS, "")
+	}
 
 	for _, command := range kubeadmInstallCommands {
-		exec, err := conn.Exec(string(command.Command))
-		log = pushToLog(log, []byte(command.Command), exec)
+		result, err := installer.exec(conn, command, sendLog)
+		log.pushResult(command, result)
 		if err != nil && command.Condition != cl.Anyway {
-			sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-			installer.l.Error("exec failed", zap.String("command", string(command.Command)))
+			log.send(percentNext(), internal.STATUS_ERROR, err.Error())
+			installer.l.Error("exec failed", zap.ByteString("command", commandText(command)), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", RedactSecrets(result.Stderr, command.Secrets)))
 			return err
 		}
 
 		if command.Parser != nil {
-			err = command.Parser(exec, nil)
+			err = command.Parser(result, nil)
 			if err != nil {
-				sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
+				log.send(percentNext(), internal.STATUS_ERROR, err.Error())
 				return err
 			}
 
 		}
-		sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-		installer.l.Info("installation percent", zap.Int("percent", percent), zap.String("command", string(command.Command)))
+		log.send(percentNext(), internal.STATUS_IN_PROCESS, "")
+		installer.l.Info("installation percent", zap.Int("percent", percent), zap.ByteString("command", commandText(command)))
 	}
 
 	err = installer.r.SetNodeClusterID(context.Background(), nodeid, 1)
 	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
+		log.send(percent, internal.STATUS_ERROR, err.Error())
 		return err
 	}
+	// join data is stored by kubeadm init parser, waiting nodes can join now
+	unlockInit()
 
-	if isClusterExists {
-		sendProgress(100, internal.STATUS_SUCCESS, string(log), "")
-		return nil
+	if joinControlPlane {
+		err = installer.r.SetNodeMaster(context.Background(), nodeid, true)
+		if err != nil {
+			log.send(percent, internal.STATUS_ERROR, err.Error())
+			return err
+		}
 	}
 
-	hostname, err := conn.Exec("hostname")
-	if err != nil {
-		return err
+	for _, step := range setupSteps {
+		// step reports its own progress within its share of installation progress
+		from, to := percent, (k*100-1)/commandNumber
+		err = step.run(func(stepPercent int, status internal.TaskStatus, logDelta string, errMsg string) {
+			if status == internal.STATUS_SUCCESS {
+				status = internal.STATUS_IN_PROCESS
+			}
+			sendProgress(from+(to-from)*stepPercent/100, status, logDelta, errMsg)
+		})
+		if err != nil {
+			log.send(percentNext(), internal.STATUS_ERROR, err.Error())
+			installer.l.Error("cluster setup failed", zap.String("step", step.name), zap.Error(err))
+			return err
+		}
+		log.send(percentNext(), internal.STATUS_IN_PROCESS, "")
 	}
 
-	config, err := installer.getAdminConf(context.Background(), conn)
+	log.send(100, internal.STATUS_SUCCESS, "")
+	return nil
+}
 
-	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		return err
-	}
+// clusterSetupStep is step of InstallK8S which sets up cluster created on the node
+type clusterSetupStep struct {
+	name string
+	// run may report progress of long step by sendProgress, percent is share of the step
+	run func(sendProgress func(percent int, status internal.TaskStatus, log string, err string)) error
+}
 
-	sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
+// clusterSetupSteps returns steps following kubeadm init: kubeconfig of the server, load balancer, ingress controller,
+// prometheus and, when it is enabled, monitoring add-on
+func (installer *Installer) clusterSetupSteps(conn client_conn.ClientConn, commandLib cl.CommandLib, settings models.ClusterSettings, nodeIP string, log *taskLog, sendLog func(stream internal.LogStream, line string)) []clusterSetupStep {
+	ctx := context.Background()
+	steps := []clusterSetupStep{
+		{name: "admin config", run: func(func(int, internal.TaskStatus, string, string)) error {
+			config, err := installer.getAdminConf(ctx, conn, commandLib)
+			if err != nil {
+				return err
+			}
+			if err = os.WriteFile("./config", config, 0664); err != nil {
+				return fmt.Errorf("writing admin.conf to ./config: %w", err)
+			}
+			time.Sleep(30 * time.Second)
+			return nil
+		}},
+		{name: "metallb", run: func(func(int, internal.TaskStatus, string, string)) error {
+			installer.hi.SetNewConfig()
+			if _, err := installer.ensureRelease(ctx, metallbRelease, log); err != nil {
+				return err
+			}
+			time.Sleep(30 * time.Second)
+			return nil
+		}},
+		{name: "metallb config", run: func(func(int, internal.TaskStatus, string, string)) error {
+			applier, err := installer.manifestApplier()
+			if err == nil {
+				err = installer.applyManifest(ctx, applier, manifests.MetallbConf, manifests.MetallbValues{IP: nodeIP}, log)
+			}
+			if err != nil {
+				return err
+			}
+			installer.l.Info("metallb installed")
+			time.Sleep(5 * time.Second)
+			return nil
+		}},
+		{name: "ingress controller", run: func(func(int, internal.TaskStatus, string, string)) error {
+			_, err := installer.ensureRelease(ctx, nginxIngressRelease, log)
+			return err
+		}},
+		// metrics of nodes are collected from prometheus whether monitoring add-on is installed or not
+		{name: "prometheus", run: func(func(int, internal.TaskStatus, string, string)) error {
+			installed, err := installer.ensureRelease(ctx, prometheusRelease, log)
+			if err != nil {
+				return err
+			}
+			if installed {
+				time.Sleep(1 * time.Minute)
+			}
+			return installer.portForwarding(prometheusPortForward)
+		}},
+	}
+	if settings.Monitoring.Enabled {
+		steps = append(steps, clusterSetupStep{name: "monitoring", run: func(sendProgress func(int, internal.TaskStatus, string, string)) error {
+			// ingress controller has to be ready before grafana ingress is created
+			time.Sleep(30 * time.Second)
+			return installer.InstallMonitoring(ctx, sendProgress, sendLog)
+		}})
+	}
+	return steps
+}
 
-	err = os.WriteFile("./config", config, 0664)
+// RemoveK8S cordons and drains the node, resets it and deletes its Node object. Drain and deletion are skipped
+// when the cluster is destroyed with the node, i.e. for the last master and for workers reset before it
+func (installer *Installer) RemoveK8S(conn client_conn.ClientConn, node internal.FullNode, clusterDestroyed bool, sendProgress func(percent int, status internal.TaskStatus, log string, err string), sendLog func(stream internal.LogStream, line string)) error {
+	ctx := context.Background()
+	settings, err := installer.r.GetClusterSettings(ctx, 1)
 	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		installer.l.Error("error writing admin.conf to ./config", zap.String("error", err.Error()))
 		return err
 	}
-
-	time.Sleep(30 * time.Second)
-	sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-
-	installer.hi.SetNewConfig()
-	err = installer.hi.InstallChart("metallb", "metallb", "metallb", nil)
+	settings = settings.WithDefaults()
+	commandLib, err := nodeCommandLib(node)
 	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
 		return err
 	}
+	kubeadmStopCommands := installer.kubeadmReset(commandLib, settings)
 
-	sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-	time.Sleep(30 * time.Second)
+	percent, k := 1, 1
+	percentNext := func() int {
+		percent = (k*100 - 1) / (len(kubeadmStopCommands) + 4)
+		k++
+		return percent
+	}
 
-	commandLib := ubuntu.Ubuntu2004CommandLib{}
-	command := commandLib.AddMetallbConf(nodeIP)
+	log := newTaskLog(sendProgress)
 
-	exec, err := conn.Exec(string(command.Command))
-	log = pushToLog(log, []byte(command.Command), exec)
-	installer.l.Info("metallb installed")
-	if err != nil && command.Condition != cl.Anyway {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		installer.l.Error("exec failed", zap.String("command", string(command.Command)), zap.String("res", string(exec)))
+	name, err := installer.nodeName(conn, commandLib)
+	if err != nil {
+		log.send(percentNext(), internal.STATUS_ERROR, err.Error())
 		return err
 	}
+	log.pushPhase("node name is "+name, nil)
+	log.send(percentNext(), internal.STATUS_IN_PROCESS, "")
 
-	sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-	if command.Parser != nil {
-		err = command.Parser(exec, nil)
+	var client kubernetes.Interface
+	if !clusterDestroyed {
+		client, err = installer.kubeClient()
 		if err != nil {
-			sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
+			log.send(percentNext(), internal.STATUS_ERROR, err.Error())
 			return err
 		}
-	}
 
-	sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-	time.Sleep(5 * time.Second)
-
-	err = installer.hi.InstallChart("nginx-ingress-controller", "bitnami", "nginx-ingress-controller", nil)
-	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		return err
+		// drain timeout is validated with cluster settings
+		timeout, _ := time.ParseDuration(settings.DrainTimeout)
+		out := newPhaseOutput(sendLog)
+		registered, err := installer.drainNode(ctx, client, name, timeout, out)
+		log.pushPhase("cordon and drain node "+name, out.bytes())
+		if err != nil {
+			log.send(percentNext(), internal.STATUS_ERROR, err.Error())
+			installer.l.Error("drain failed", zap.String("node", name), zap.Error(err))
+			return err
+		}
+		if !registered {
+			log.pushPhase("node "+name+" isn't registered in cluster, drain is skipped", nil)
+		}
+	} else {
+		log.pushPhase("cluster is destroyed, drain is skipped", nil)
 	}
+	log.send(percentNext(), internal.STATUS_IN_PROCESS, "")
 
-	sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-	time.Sleep(30 * time.Second)
-
-	grafanaCommands := installer.kubeadmCreateGrafana(string(hostname))
-	for _, command := range grafanaCommands {
-		exec, err := conn.Exec(string(command.Command))
-		log = pushToLog(log, []byte(command.Command), exec)
-		installer.l.Info("grafana installation percent", zap.Int("percent", percent/len(grafanaCommands)))
-
+	for _, command := range kubeadmStopCommands {
+		result, err := installer.exec(conn, command, sendLog)
+		log.pushResult(command, result)
 		if err != nil && command.Condition != cl.Anyway {
-			installer.l.Error("exec failed", zap.String("command", string(command.Command)))
-			sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
+			log.send(percentNext(), internal.STATUS_ERROR, err.Error())
+			installer.l.Error("exec failed", zap.ByteString("command", commandText(command)), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", RedactSecrets(result.Stderr, command.Secrets)))
 			return err
+		} else if err != nil {
+			installer.l.Warn("exec failed", zap.ByteString("command", commandText(command)), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", RedactSecrets(result.Stderr, command.Secrets)))
 		}
 
 		if command.Parser != nil {
-			err = command.Parser(exec, nil)
+			err = command.Parser(result, nil)
 			if err != nil {
-				sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
+				log.send(percentNext(), internal.STATUS_ERROR, err.Error())
 				return err
 			}
 		}
-		sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-	}
-
-	time.Sleep(1 * time.Minute)
-
-	sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
-	err = installer.hi.InstallChart("grafana", "bitnami", "grafana", grafanaArgs)
-
-	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		return err
+		log.send(percentNext(), internal.STATUS_IN_PROCESS, "")
 	}
 
-	time.Sleep(1 * time.Minute)
-	exec, err = conn.Exec("kubectl exec --namespace default -it $(kubectl get pods --namespace default -lapp.kubernetes.io/name=grafana -o jsonpath=\"{.items[0].metadata.name}\") grafana-cli admin reset-admin-password admin")
-
-	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		return err
-	}
-
-	err = installer.portForwarding("default", "grafana", "3000", "3000")
-	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		installer.l.Error("exec failed", zap.String("command", string(command.Command)))
-		return err
-	}
-
-	err = installer.portForwarding("default", "prometheus", "9090", "9090")
-	if err != nil {
-		sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-		installer.l.Error("exec failed", zap.String("command", string(command.Command)))
-		return err
+	if client != nil {
+		err = installer.deleteNode(ctx, client, name)
+		if err != nil {
+			err = fmt.Errorf("node was reset, but its Node object wasn't deleted: %w", err)
+			log.send(percentNext(), internal.STATUS_ERROR, err.Error())
+			return err
+		}
+		log.pushPhase("Node object "+name+" deleted", nil)
 	}
-
-	sendProgress(100, internal.STATUS_SUCCESS, string(log), "")
-
+	log.send(100, internal.STATUS_SUCCESS, "")
 	return nil
 }
 
-func (installer *Installer) RemoveK8S(conn client_conn.ClientConn, sendProgress func(percent int, status internal.TaskStatus, log string, err string)) error {
-	kubeadmStopCommands := installer.kubeadmReset()
-
-	percent, k := 1, 1
-	percentNext := func() int {
-		percent = (k*100 - 1) / 8
-		k++
-		return percent
-	}
+func (installer *Installer) getAdminConf(ctx context.Context, cc client_conn.ClientConn, commandLib cl.CommandLib) ([]byte, error) {
+	return installer.output(cc, commandLib.CatAdminConfFile())
+}
 
-	log := make([]byte, 0, LOG_INITIAL_SIZE)
+func (installer *Installer) installChart(release chartRelease) error {
+	return installer.hi.InstallChart(release.name, release.repo, release.chart, release.args)
+}
 
-	for i, command := range kubeadmStopCommands {
-		exec, err := conn.Exec(string(command.Command))
-		log = pushToLog(log, []byte(command.Command), exec)
-		installer.l.Info("installation percent", zap.Int("percent", (i+1)*100/len(kubeadmStopCommands)))
-		if err != nil && command.Condition != cl.Anyway {
-			sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-			installer.l.Error("exec failed", zap.String("command", string(command.Command)))
-			return err
-		} else if err != nil {
-			installer.l.Warn("exec failed", zap.String("command", string(command.Command)))
+// ensureRelease installs release unless it already exists, existing release e.g. installed earlier by helm CLI
+// is adopted as it is. Release is tracked in resources in both cases. Returns true when release was installed
+func (installer *Installer) ensureRelease(ctx context.Context, release chartRelease, log *taskLog) (bool, error) {
+	exists, err := installer.hi.ReleaseExists(release.name)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		log.pushPhase("release "+release.name+" already exists, adopted", nil)
+	} else {
+		if err = installer.UseBundleCharts(ctx); err != nil {
+			return false, err
 		}
-
-		if command.Parser != nil {
-			err = command.Parser(exec, nil)
-			if err != nil {
-				sendProgress(percentNext(), internal.STATUS_ERROR, string(log), err.Error())
-				return err
-			}
+		if err = installer.installChart(release); err != nil {
+			return false, err
 		}
-		sendProgress(percentNext(), internal.STATUS_IN_PROCESS, string(log), "")
+		log.pushPhase("release "+release.name+" installed", nil)
 	}
-	sendProgress(100, internal.STATUS_SUCCESS, string(log), "")
-	return nil
+	return !exists, installer.trackRelease(ctx, release)
 }
 
-func (installer *Installer) getAdminConf(ctx context.Context, cc client_conn.ClientConn) ([]byte, error) {
-	cl := ubuntu.Ubuntu2004CommandLib{}
-	getAdminConfCommand := cl.CatAdminConfFile()
-	output, err := cc.Exec(string(getAdminConfCommand.Command))
+// trackRelease saves release to resources unless it is saved already
+func (installer *Installer) trackRelease(ctx context.Context, release chartRelease) error {
+	resources, err := installer.r.GetResources(ctx)
 	if err != nil {
-		installer.l.Error("error getting admin.conf", zap.String("error", err.Error()))
-		return nil, err
+		return err
+	}
+	for _, resource := range resources {
+		if resource.Name == release.name {
+			return nil
+		}
 	}
-	return output, nil
+	return installer.r.AddResource(ctx, release.chart, release.name)
 }
 
-func (installer *Installer) portForwarding(namespace, appName, portLocal, portRemote string) error {
+func (installer *Installer) portForwarding(pf portForward) error {
+	namespace, appName, portLocal, portRemote := pf.namespace, pf.appName, pf.portLocal, pf.portRemote
+
 	config, err := clientcmd.BuildConfigFromFlags("", "./config")
 	if err != nil {
 		return err
@@ -425,8 +641,20 @@ func (installer *Installer) portForwarding(namespace, appName, portLocal, portRe
 
 	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
 
+	stopChan := make(chan struct{})
+	installer.pfMu.Lock()
+	if prev, ok := installer.portForwards[appName]; ok {
+		close(prev)
+	}
+	installer.portForwards[appName] = stopChan
+	installer.pfMu.Unlock()
+
 	go func() {
-		forwarder, err := portforward.NewOnAddresses(dialer, []string{"0.0.0.0"}, []string{fmt.Sprintf("%s:%s", portLocal, portRemote)}, nil, nil, out, errOut)
+		forwarder, err := portforward.NewOnAddresses(dialer, []string{"0.0.0.0"}, []string{fmt.Sprintf("%s:%s", portLocal, portRemote)}, stopChan, nil, out, errOut)
+		if err != nil {
+			installer.l.Error("error creating port-forward", zap.Error(err))
+			return
+		}
 		if err = forwarder.ForwardPorts(); err != nil { // Locks until stopChan is closed.
 			installer.l.Error("error forwarding", zap.Error(err))
 		}
@@ -435,7 +663,182 @@ func (installer *Installer) portForwarding(namespace, appName, portLocal, portRe
 	return nil
 }
 
-func pushToLog(log []byte, command []byte, output []byte) []byte {
+// StopPortForwards stops all port-forwards started by the installer
+func (installer *Installer) StopPortForwards() {
+	installer.pfMu.Lock()
+	defer installer.pfMu.Unlock()
+	for appName, stopChan := range installer.portForwards {
+		close(stopChan)
+		delete(installer.portForwards, appName)
+	}
+}
+
+// exec runs command streaming its stdout and stderr line by line to sendLog with secrets redacted. Returned result
+// isn't redacted for parser of the command, taskLog.pushResult redacts it. Exit code which is success of the command
+// doesn't return error
+func (installer *Installer) exec(conn client_conn.ClientConn, command cl.CommandAndParser, sendLog func(stream internal.LogStream, line string)) (cl.Result, error) {
+	stdout := remote_exec.NewLineWriter(func(line string) {
+		sendLog(internal.STREAM_STDOUT, string(RedactSecrets([]byte(line), command.Secrets)))
+	})
+	stderr := remote_exec.NewLineWriter(func(line string) {
+		sendLog(internal.STREAM_STDERR, string(RedactSecrets([]byte(line), command.Secrets)))
+	})
+	var uploaded cl.Result
+	if file := command.File; file != nil {
+		start := time.Now()
+		checksum, err := remote_exec.Upload(conn, remote_exec.File{Path: file.Path, Content: bytes.NewReader(file.Content), Mode: file.Mode, Owner: file.Owner})
+		uploaded = cl.Result{Stdout: []byte(uploadOutput(checksum, err)), Duration: time.Since(start)}
+		sendLog(internal.STREAM_STDOUT, string(uploaded.Stdout))
+		if err != nil {
+			uploaded.ExitCode = cl.NoExitCode
+		}
+		if err != nil || command.Command == "" {
+			return uploaded, err
+		}
+		uploaded.Stdout = append(uploaded.Stdout, '\n')
+	}
+	result, err := remote_exec.Run(conn, string(command.Command), stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+	result.Stdout = append(uploaded.Stdout, result.Stdout...)
+	result.Duration += uploaded.Duration
+	if err != nil && result.ExitCode != cl.NoExitCode && command.Succeeded(result.ExitCode) {
+		err = nil
+	}
+	return result, err
+}
+
+// output runs command whose stdout is read by the installer and returns the stdout. Error of failed command
+// has its stderr
+func (installer *Installer) output(conn client_conn.ClientConn, command cl.CommandAndParser) ([]byte, error) {
+	result, err := remote_exec.Run(conn, string(command.Command), nil, nil)
+	if err == nil || (result.ExitCode != cl.NoExitCode && command.Succeeded(result.ExitCode)) {
+		return result.Stdout, nil
+	}
+	// failure of command with Anyway condition is expected, caller decides what it means
+	logFailure := installer.l.Error
+	if command.Condition == cl.Anyway {
+		logFailure = installer.l.Debug
+	}
+	logFailure("exec failed", zap.ByteString("command", commandText(command)), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", RedactSecrets(result.Stderr, command.Secrets)))
+	if stderr := strings.TrimSpace(string(RedactSecrets(result.Stderr, command.Secrets))); stderr != "" {
+		err = fmt.Errorf("%w: %s", err, stderr)
+	}
+	return result.Stdout, err
+}
+
+// commandText is how command is shown in task log, secrets are redacted and uploaded file is shown by its path
+// and mode without content
+func commandText(command cl.CommandAndParser) []byte {
+	if command.File == nil {
+		return []byte(command.Redacted(redactedValue))
+	}
+	text := uploadText(command.File.Path, command.File.Mode, command.File.Owner)
+	if command.Command != "" {
+		text += "\n" + string(command.Redacted(redactedValue))
+	}
+	return []byte(text)
+}
+
+// bootstrapTokenRe matches bootstrap token, kubeadm prints it in join commands of its output
+var bootstrapTokenRe = regexp.MustCompile(`\b[a-z0-9]{6}\.[a-z0-9]{16}\b`)
+
+// RedactSecrets returns output of command with its secrets and bootstrap tokens replaced by redacted placeholder
+func RedactSecrets(output []byte, secrets []string) []byte {
+	for _, secret := range secrets {
+		if secret != "" {
+			output = bytes.ReplaceAll(output, []byte(secret), []byte(redactedValue))
+		}
+	}
+	return bootstrapTokenRe.ReplaceAll(output, []byte(redactedValue))
+}
+
+func uploadText(path string, mode os.FileMode, owner string) string {
+	text := fmt.Sprintf("upload %s mode %s", path, fileMode(mode))
+	if owner != "" {
+		text += " owner " + owner
+	}
+	return text
+}
+
+func uploadOutput(checksum string, err error) string {
+	if err != nil {
+		return "upload failed: " + err.Error()
+	}
+	return "sha256 " + checksum
+}
+
+// taskLog accumulates commands with their output and sends only the part of log
+// which was not sent with previous progress message
+type taskLog struct {
+	log          []byte
+	sent         int
+	sendProgress func(percent int, status internal.TaskStatus, log string, err string)
+}
+
+func newTaskLog(sendProgress func(percent int, status internal.TaskStatus, log string, err string)) *taskLog {
+	return &taskLog{
+		log:          make([]byte, 0, LOG_INITIAL_SIZE),
+		sendProgress: sendProgress,
+	}
+}
+
+func (t *taskLog) push(command []byte, output []byte) {
 	command = bytes.ReplaceAll(command, []byte("\n"), []byte("\n$ "))
-	return bytes.Join([][]byte{log, append([]byte("$ "), command...), output}, []byte("\n"))
+	t.log = bytes.Join([][]byte{t.log, append([]byte("$ "), command...), output}, []byte("\n"))
+}
+
+// pushResult adds command shown by commandText with its stdout, stderr lines follow it prefixed by "2> ".
+// Secrets are redacted in the output. Command which exited with non-zero code ends with the code and its duration
+func (t *taskLog) pushResult(command cl.CommandAndParser, result cl.Result) {
+	t.push(commandText(command), RedactSecrets(result.Stdout, command.Secrets))
+	if stderr := bytes.TrimSuffix(RedactSecrets(result.Stderr, command.Secrets), []byte("\n")); len(stderr) > 0 {
+		stderr = bytes.ReplaceAll(stderr, []byte("\n"), []byte("\n2> "))
+		t.log = bytes.Join([][]byte{t.log, append([]byte("2> "), stderr...)}, []byte("\n"))
+	}
+	switch result.ExitCode {
+	case cl.Success:
+	case cl.NoExitCode:
+		t.pushPhase(fmt.Sprintf("no exit code after %s", result.Duration.Round(time.Millisecond)), nil)
+	default:
+		t.pushPhase(fmt.Sprintf("exit code %d after %s", result.ExitCode, result.Duration.Round(time.Millisecond)), nil)
+	}
+}
+
+// pushPhase adds to log step which isn't a remote command
+func (t *taskLog) pushPhase(title string, output []byte) {
+	t.log = bytes.Join([][]byte{t.log, []byte("# " + title), output}, []byte("\n"))
+}
+
+func (t *taskLog) send(percent int, status internal.TaskStatus, err string) {
+	delta := string(t.log[t.sent:])
+	t.sent = len(t.log)
+	t.sendProgress(percent, status, delta, err)
+}
+
+// phaseOutput collects output of steps done in-process and streams it line by line like remote command output
+type phaseOutput struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	lines *remote_exec.LineWriter
+}
+
+func newPhaseOutput(sendLog func(stream internal.LogStream, line string)) *phaseOutput {
+	return &phaseOutput{lines: remote_exec.NewLineWriter(func(line string) {
+		sendLog(internal.STREAM_STDOUT, line)
+	})}
+}
+
+func (o *phaseOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Write(p)
+	return o.lines.Write(p)
+}
+
+func (o *phaseOutput) bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lines.Flush()
+	return o.buf.Bytes()
 }
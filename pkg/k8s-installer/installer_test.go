@@ -0,0 +1,253 @@
+package k8s_installer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/bundle"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/cltest"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/distro"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/offline"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+func TestNodeCommands(t *testing.T) {
+	settings := models.ClusterSettings{ContainerRuntime: models.ContainerRuntimeContainerd}
+	installer := &Installer{}
+
+	tests := []struct {
+		distro distro.Distro
+		role   string
+		want   []string
+	}{
+		{distro: distro.Ubuntu2004, role: ROLE_MASTER, want: []string{"apt-transport-https", "kubeadm init", "kube-flannel"}},
+		{distro: distro.Ubuntu2204, role: ROLE_WORKER, want: []string{"modprobe overlay", "kubeadm join", "ufw delete allow 6443/tcp"}},
+		{distro: distro.Debian12, role: ROLE_MASTER, want: []string{"iptables", "/etc/sysctl.d/99-kubernetes.conf", "kubeadm init", "kube-flannel"}},
+		{distro: distro.Debian12, role: ROLE_WORKER, want: []string{"iptables", "/etc/sysctl.d/99-kubernetes.conf", "kubeadm join"}},
+		{distro: distro.Rocky9, role: ROLE_MASTER, want: []string{"dnf install", "setenforce 0", "--add-port=6443/tcp", "kubeadm init", "--remove-port=6443/tcp"}},
+		{distro: distro.Alma9, role: ROLE_WORKER, want: []string{"dnf install", "setenforce 0", "--add-port=10250/tcp", "kubeadm join", "--remove-port=6443/tcp"}},
+	}
+	for _, tt := range tests {
+		commandLib, err := distro.CommandLib(tt.distro)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = validateNodeCgroupDriver(commandLib, settings); err != nil {
+			t.Errorf("%s %s: %v", tt.distro, tt.role, err)
+		}
+
+		commands, err := installer.installKubeadm(commandLib, settings)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.distro, tt.role, err)
+		}
+		if tt.role == ROLE_MASTER {
+			commands = append(commands, installer.kubeadmInit(commandLib, settings, "config", "", 1)...)
+		} else {
+			commands = append(commands, installer.kubeadmJoin(commandLib, settings, "config", false)...)
+		}
+		commands = append(commands, installer.kubeadmReset(commandLib, settings)...)
+
+		cltest.AssertContains(t, fmt.Sprintf("%s %s", tt.distro, tt.role), joinCommands(commands), append(tt.want, "containerd", "kubeadm reset", "systemctl stop containerd.service"), nil)
+	}
+}
+
+func TestOfflineNodeCommands(t *testing.T) {
+	settings := models.ClusterSettings{ContainerRuntime: models.ContainerRuntimeContainerd}
+	installer := &Installer{}
+	commandLib := offline.NewCommandLib(&ubuntu.Ubuntu2204CommandLib{}, &bundle.Manifest{CNIManifest: "cni/kube-flannel.yml"})
+
+	commands, err := installer.installKubeadm(commandLib, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commands[0].Command != commandLib.ExtractBundle().Command {
+		t.Errorf("first command is %q, want bundle extraction", commands[0].Command)
+	}
+	commands = append(commands, installer.kubeadmInit(commandLib, settings, "config", "", 1)...)
+
+	cltest.AssertContains(t, "offline", joinCommands(commands),
+		[]string{"/var/lib/paas/bundle/packages/*.deb", "kubeadm init", "/var/lib/paas/bundle/cni/kube-flannel.yml"},
+		[]string{"pkgs.k8s.io", "curl", "apt update", "github.com"})
+}
+
+func TestFirewallPorts(t *testing.T) {
+	tests := []struct {
+		cni          models.CNI
+		controlPlane bool
+		want         []string
+		notWant      []string
+	}{
+		{cni: models.CNIFlannel, controlPlane: true, want: []string{"6443/tcp", "2379-2380/tcp", "10250/tcp", "8472/udp"}},
+		{cni: models.CNIFlannel, want: []string{"10250/tcp", "30000-32767/tcp", "8472/udp"}, notWant: []string{"6443/tcp", "2379-2380/tcp"}},
+		{cni: models.CNICalico, want: []string{"179/tcp", "4789/udp"}, notWant: []string{"8472/udp"}},
+		{cni: models.CNICilium, want: []string{"8472/udp", "4240/tcp"}},
+	}
+	for _, tt := range tests {
+		ports := strings.Join(firewallPorts(models.ClusterSettings{CNI: tt.cni}, tt.controlPlane), " ")
+		cltest.AssertContains(t, fmt.Sprintf("%s control plane %t", tt.cni, tt.controlPlane), ports, tt.want, tt.notWant)
+	}
+}
+
+func TestKubeadmConfigUpload(t *testing.T) {
+	installer := &Installer{}
+	commands := installer.kubeadmJoin(&ubuntu.Ubuntu2204CommandLib{}, models.ClusterSettings{}, "token: abcdef.0123456789abcdef\n", false)
+
+	var upload cl.CommandAndParser
+	for _, command := range commands {
+		if command.File != nil {
+			upload = command
+		}
+	}
+	if upload.File == nil || upload.File.Path != kubeadmJoinConfigPath || upload.File.Mode != 0600 {
+		t.Fatalf("commands don't upload %s with mode 0600", kubeadmJoinConfigPath)
+	}
+	if text := string(commandText(upload)); text != "upload "+kubeadmJoinConfigPath+" mode 0600" {
+		t.Errorf("log of upload is %q, want path and mode without content", text)
+	}
+
+	steps := commandSteps(upload)
+	if len(steps) != 1 || steps[0].Type != internal.PLAN_STEP_UPLOAD || steps[0].Mode != "0600" || steps[0].Content != string(upload.File.Content) {
+		t.Errorf("plan steps of upload are %+v", steps)
+	}
+}
+
+func TestTaskLogResult(t *testing.T) {
+	log := newTaskLog(nil)
+	log.pushResult(cl.CommandAndParser{Command: "sudo systemctl stop kubelet"}, cl.Result{
+		ExitCode: cl.UnitNotLoaded,
+		Stderr:   []byte("Failed to stop kubelet.service: Unit kubelet.service not loaded.\n"),
+		Duration: 12 * time.Millisecond,
+	})
+	want := "\n$ sudo systemctl stop kubelet\n\n2> Failed to stop kubelet.service: Unit kubelet.service not loaded.\n# exit code 5 after 12ms\n"
+	if got := string(log.log); got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+}
+
+func TestCommandTextRedactsSecrets(t *testing.T) {
+	commandLib := &ubuntu.Ubuntu2204CommandLib{}
+	tests := []struct {
+		command cl.CommandAndParser
+		secret  string
+	}{
+		{command: commandLib.UploadCerts("0123456789abcdef"), secret: "0123456789abcdef"},
+		{command: commandLib.DeleteKubeadmToken("abcdef.0123456789abcdef"), secret: "abcdef.0123456789abcdef"},
+		{command: commandLib.ResetGrafanaAdminPassword("p@ss'; reboot"), secret: "p@ss"},
+	}
+	for _, tt := range tests {
+		text := string(commandText(tt.command))
+		if strings.Contains(text, tt.secret) || !strings.Contains(text, redactedValue) {
+			t.Errorf("log of %q is %q, want secret redacted", tt.command.Command, text)
+		}
+	}
+}
+
+func TestClusterSetupSteps(t *testing.T) {
+	installer := &Installer{}
+	tests := []struct {
+		name       string
+		monitoring bool
+		want       []string
+	}{
+		{name: "without monitoring", want: []string{"admin config", "metallb", "metallb config", "ingress controller", "prometheus"}},
+		{name: "with monitoring", monitoring: true, want: []string{"admin config", "metallb", "metallb config", "ingress controller", "prometheus", "monitoring"}},
+	}
+	for _, tt := range tests {
+		settings := models.ClusterSettings{Monitoring: models.MonitoringSettings{Enabled: tt.monitoring}}
+		steps := installer.clusterSetupSteps(nil, &ubuntu.Ubuntu2204CommandLib{}, settings, "10.0.0.10", newTaskLog(nil), nil)
+		names := make([]string, 0, len(steps))
+		for _, step := range steps {
+			names = append(names, step.name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: steps = %v, want %v", tt.name, names, tt.want)
+		}
+	}
+}
+
+// outputConn is connection returning the same output and error for every command
+type outputConn struct {
+	output []byte
+	err    error
+}
+
+func (c *outputConn) Exec(string) ([]byte, error) {
+	return c.output, c.err
+}
+
+func (c *outputConn) Close() error {
+	return nil
+}
+
+func TestOutput(t *testing.T) {
+	installer := &Installer{l: zap.NewNop()}
+	errConn := errors.New("connection lost")
+	tests := []struct {
+		name    string
+		conn    *outputConn
+		want    string
+		wantErr error
+	}{
+		{name: "success", conn: &outputConn{output: []byte("node-1\n")}, want: "node-1\n"},
+		{name: "failure", conn: &outputConn{output: []byte("partial"), err: errConn}, want: "partial", wantErr: errConn},
+	}
+	for _, tt := range tests {
+		output, err := installer.output(tt.conn, (&ubuntu.Ubuntu2204CommandLib{}).Hostname())
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if string(output) != tt.want {
+			t.Errorf("%s: output = %q, want %q", tt.name, output, tt.want)
+		}
+	}
+}
+
+const (
+	testToken          = "abcdef.0123456789abcdef"
+	testCertificateKey = "f2a4ba2e5e12b0a73a1e38ad6a6c8a5c04fa3e4ad3f3ed5ac1ea3e5d8a9c7b16"
+	testHash           = "sha256:8c2d0b2c6a8d3f0e41f5cb6fe0e3b3f0d0fb5c0e4a8a1c4f6b1b9f7e6b5f4a3c"
+)
+
+// kubeadmInitOutput is part of kubeadm init output uploading certificates
+var kubeadmInitOutput = "[upload-certs] Using certificate key:\n" + testCertificateKey + "\n" +
+	"[bootstrap-token] Using token: " + testToken + "\n" +
+	"  kubeadm join 10.0.0.10:6443 --token " + testToken + " \\\n" +
+	"\t--discovery-token-ca-cert-hash " + testHash + " \\\n" +
+	"\t--control-plane --certificate-key " + testCertificateKey + "\n"
+
+func TestExecRedactsOutput(t *testing.T) {
+	installer := &Installer{l: zap.NewNop()}
+	settings := models.ClusterSettings{ControlPlaneEndpoint: "10.0.0.100:6443"}
+	var init cl.CommandAndParser
+	for _, command := range installer.kubeadmInit(&ubuntu.Ubuntu2204CommandLib{}, settings, "config", testCertificateKey, 1) {
+		if strings.Contains(string(command.Command), "kubeadm init") {
+			init = command
+		}
+	}
+
+	var streamed []string
+	result, err := installer.exec(&outputConn{output: []byte(kubeadmInitOutput)}, init, func(stream internal.LogStream, line string) {
+		streamed = append(streamed, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(result.Stdout), testToken) {
+		t.Error("result for parser of kubeadm init is redacted")
+	}
+	log := newTaskLog(nil)
+	log.pushResult(init, result)
+
+	for name, text := range map[string]string{"streamed lines": strings.Join(streamed, "\n"), "task log": string(log.log)} {
+		cltest.AssertContains(t, name, text, []string{redactedValue, testHash}, []string{testToken, testCertificateKey})
+	}
+}
@@ -0,0 +1,149 @@
+package k8s_installer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+)
+
+// token is refreshed when it expires sooner than node installation may finish
+const joinTokenExpiryMargin = time.Hour
+
+var joinCommandRe = regexp.MustCompile(`--token (?P<token>[a-z0-9.]+) --discovery-token-ca-cert-hash (?P<hash>[a-z0-9:]+)`)
+
+// bootstrapToken is part of kubeadm token list JSON output, Expires is nil for tokens without TTL
+type bootstrapToken struct {
+	Token   string     `json:"token"`
+	Expires *time.Time `json:"expires"`
+}
+
+// parseTokenList parses concatenated JSON objects printed by kubeadm token list -o json
+func parseTokenList(output []byte) ([]bootstrapToken, error) {
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	var tokens []bootstrapToken
+	for {
+		var token bootstrapToken
+		err := decoder.Decode(&token)
+		if errors.Is(err, io.EOF) {
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't parse kubeadm token list: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// isJoinTokenValid checks that token exists and doesn't expire within joinTokenExpiryMargin
+func isJoinTokenValid(tokens []bootstrapToken, token string, now time.Time) bool {
+	for _, t := range tokens {
+		if t.Token != token {
+			continue
+		}
+		return t.Expires == nil || t.Expires.After(now.Add(joinTokenExpiryMargin))
+	}
+	return false
+}
+
+// parseJoinCommand returns token and CA cert hash from kubeadm token create --print-join-command output
+func parseJoinCommand(output []byte) (token, hash string, err error) {
+	match := joinCommandRe.FindSubmatch(output)
+	if len(match) == 0 {
+		return "", "", fmt.Errorf("no join command in kubeadm token create output")
+	}
+	return string(match[joinCommandRe.SubexpIndex("token")]), string(match[joinCommandRe.SubexpIndex("hash")]), nil
+}
+
+// ensureJoinToken creates new join token on master when the stored one is expired or expires soon
+func (installer *Installer) ensureJoinToken(ctx context.Context, excludeNodeID int) error {
+	installer.tokenMu.Lock()
+	defer installer.tokenMu.Unlock()
+
+	token, _, _, err := installer.r.GetClusterTokenIPAndHash(ctx, 1)
+	if err != nil {
+		return err
+	}
+
+	conn, master, err := installer.connectToControlPlane(ctx, 1, excludeNodeID)
+	if err != nil {
+		return err
+	}
+	defer func(conn client_conn.ClientConn) {
+		_ = conn.Close()
+	}(conn)
+
+	commandLib, err := nodeCommandLib(master)
+	if err != nil {
+		return err
+	}
+	output, err := installer.output(conn, commandLib.ListKubeadmTokens())
+	if err != nil {
+		return err
+	}
+	tokens, err := parseTokenList(output)
+	if err != nil {
+		return err
+	}
+	if isJoinTokenValid(tokens, token, time.Now()) {
+		return nil
+	}
+
+	installer.l.Info("join token is expired, creating new one")
+	return installer.createJoinToken(ctx, conn, commandLib, "")
+}
+
+// RotateJoinToken creates new join token on master and deletes the previous one
+func (installer *Installer) RotateJoinToken(ctx context.Context) error {
+	installer.tokenMu.Lock()
+	defer installer.tokenMu.Unlock()
+
+	token, _, _, err := installer.r.GetClusterTokenIPAndHash(ctx, 1)
+	if err != nil {
+		return err
+	}
+
+	conn, master, err := installer.ConnectToControlPlane(ctx, 1)
+	if err != nil {
+		return err
+	}
+	defer func(conn client_conn.ClientConn) {
+		_ = conn.Close()
+	}(conn)
+
+	commandLib, err := nodeCommandLib(master)
+	if err != nil {
+		return err
+	}
+	return installer.createJoinToken(ctx, conn, commandLib, token)
+}
+
+func (installer *Installer) createJoinToken(ctx context.Context, conn client_conn.ClientConn, commandLib cl.CommandLib, oldToken string) error {
+	output, err := installer.output(conn, commandLib.CreateKubeadmToken())
+	if err != nil {
+		return err
+	}
+	token, hash, err := parseJoinCommand(output)
+	if err != nil {
+		return err
+	}
+	if err = installer.r.UpdateClusterTokenAndHash(ctx, 1, token, hash); err != nil {
+		return err
+	}
+
+	if oldToken != "" && oldToken != token {
+		if _, err = installer.output(conn, commandLib.DeleteKubeadmToken(oldToken)); err != nil {
+			installer.l.Warn("can't delete previous join token", zap.Error(err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package k8s_installer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsJoinTokenValid(t *testing.T) {
+	output := []byte(`{
+    "kind": "BootstrapToken",
+    "apiVersion": "output.kubeadm.k8s.io/v1alpha3",
+    "token": "abcdef.0123456789abcdef",
+    "ttl": "23h",
+    "expires": "2024-05-02T10:00:00Z",
+    "usages": ["authentication", "signing"]
+}
+{
+    "kind": "BootstrapToken",
+    "apiVersion": "output.kubeadm.k8s.io/v1alpha3",
+    "token": "ghijkl.0123456789abcdef"
+}
+`)
+	tokens, err := parseTokenList(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("expected 2 tokens, got %d", len(tokens))
+	}
+
+	now := time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		token string
+		now   time.Time
+		valid bool
+	}{
+		{token: "abcdef.0123456789abcdef", now: now, valid: true},
+		{token: "abcdef.0123456789abcdef", now: now.Add(90 * time.Minute), valid: false},
+		{token: "ghijkl.0123456789abcdef", now: now, valid: true},
+		{token: "mnopqr.0123456789abcdef", now: now, valid: false},
+	}
+	for _, tt := range tests {
+		if valid := isJoinTokenValid(tokens, tt.token, tt.now); valid != tt.valid {
+			t.Errorf("isJoinTokenValid(%s, %s) = %t, want %t", tt.token, tt.now, valid, tt.valid)
+		}
+	}
+}
+
+func TestParseJoinCommand(t *testing.T) {
+	output := []byte("kubeadm join 10.0.0.10:6443 --token abcdef.0123456789abcdef --discovery-token-ca-cert-hash sha256:0a1b2c \n")
+	token, hash, err := parseJoinCommand(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "abcdef.0123456789abcdef" || hash != "sha256:0a1b2c" {
+		t.Errorf("unexpected token %q and hash %q", token, hash)
+	}
+
+	if _, _, err = parseJoinCommand([]byte("error")); err == nil {
+		t.Error("expected error for output without join command")
+	}
+}
@@ -0,0 +1,270 @@
+package k8s_installer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+const (
+	// v1beta3 is accepted by kubeadm of all supported versions
+	kubeadmAPIVersion = "kubeadm.k8s.io/v1beta3"
+	kubeletAPIVersion = "kubelet.config.k8s.io/v1beta1"
+
+	kubeadmConfigDir         = "/etc/kubeadm"
+	kubeadmInitConfigPath    = kubeadmConfigDir + "/init.yaml"
+	kubeadmJoinConfigPath    = kubeadmConfigDir + "/join.yaml"
+	kubeadmConfigFileMode    = 0600
+	kubeletMaxPodsUpperLimit = 10000
+)
+
+var (
+	ErrInvalidKubeadmSettings = errors.New("invalid kubeadm settings")
+
+	featureGateRe = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	dnsNameRe     = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+)
+
+type nodeRegistration struct {
+	CRISocket string `yaml:"criSocket"`
+}
+
+type initConfiguration struct {
+	APIVersion       string           `yaml:"apiVersion"`
+	Kind             string           `yaml:"kind"`
+	NodeRegistration nodeRegistration `yaml:"nodeRegistration"`
+	CertificateKey   string           `yaml:"certificateKey,omitempty"`
+}
+
+type controlPlaneComponent struct {
+	ExtraArgs map[string]string `yaml:"extraArgs,omitempty"`
+}
+
+type apiServer struct {
+	CertSANs  []string          `yaml:"certSANs,omitempty"`
+	ExtraArgs map[string]string `yaml:"extraArgs,omitempty"`
+}
+
+type networking struct {
+	PodSubnet     string `yaml:"podSubnet"`
+	ServiceSubnet string `yaml:"serviceSubnet"`
+}
+
+type clusterConfiguration struct {
+	APIVersion           string                `yaml:"apiVersion"`
+	Kind                 string                `yaml:"kind"`
+	KubernetesVersion    string                `yaml:"kubernetesVersion,omitempty"`
+	ControlPlaneEndpoint string                `yaml:"controlPlaneEndpoint,omitempty"`
+	Networking           networking            `yaml:"networking"`
+	APIServer            apiServer             `yaml:"apiServer,omitempty"`
+	ControllerManager    controlPlaneComponent `yaml:"controllerManager,omitempty"`
+	Scheduler            controlPlaneComponent `yaml:"scheduler,omitempty"`
+}
+
+type kubeletConfiguration struct {
+	APIVersion   string          `yaml:"apiVersion"`
+	Kind         string          `yaml:"kind"`
+	CgroupDriver string          `yaml:"cgroupDriver"`
+	FeatureGates map[string]bool `yaml:"featureGates,omitempty"`
+	MaxPods      int             `yaml:"maxPods,omitempty"`
+}
+
+type bootstrapTokenDiscovery struct {
+	APIServerEndpoint string   `yaml:"apiServerEndpoint"`
+	Token             string   `yaml:"token"`
+	CACertHashes      []string `yaml:"caCertHashes"`
+}
+
+type discovery struct {
+	BootstrapToken bootstrapTokenDiscovery `yaml:"bootstrapToken"`
+}
+
+type joinControlPlane struct {
+	CertificateKey string `yaml:"certificateKey"`
+}
+
+type joinConfiguration struct {
+	APIVersion       string            `yaml:"apiVersion"`
+	Kind             string            `yaml:"kind"`
+	NodeRegistration nodeRegistration  `yaml:"nodeRegistration"`
+	Discovery        discovery         `yaml:"discovery"`
+	ControlPlane     *joinControlPlane `yaml:"controlPlane,omitempty"`
+}
+
+func validateKubeadmSettings(settings models.ClusterSettings) error {
+	settings = settings.WithDefaults()
+
+	if err := validateContainerRuntime(settings); err != nil {
+		return err
+	}
+
+	switch settings.CgroupDriver {
+	case models.CgroupDriverSystemd, models.CgroupDriverCgroupfs:
+	default:
+		return fmt.Errorf("%w: unsupported cgroup driver %q, supported are %s and %s", ErrInvalidKubeadmSettings, settings.CgroupDriver, models.CgroupDriverSystemd, models.CgroupDriverCgroupfs)
+	}
+
+	for _, san := range settings.APIServerCertSANs {
+		if net.ParseIP(san) == nil && !dnsNameRe.MatchString(san) {
+			return fmt.Errorf("%w: api server cert SAN %q is neither IP nor DNS name", ErrInvalidKubeadmSettings, san)
+		}
+	}
+
+	for gate := range settings.FeatureGates {
+		if !featureGateRe.MatchString(gate) {
+			return fmt.Errorf("%w: invalid feature gate name %q", ErrInvalidKubeadmSettings, gate)
+		}
+	}
+
+	if settings.KubeletMaxPods < 0 || settings.KubeletMaxPods > kubeletMaxPodsUpperLimit {
+		return fmt.Errorf("%w: kubelet max pods must be between 0 and %d", ErrInvalidKubeadmSettings, kubeletMaxPodsUpperLimit)
+	}
+	return nil
+}
+
+// renderInitConfig renders InitConfiguration, ClusterConfiguration and KubeletConfiguration for kubeadm init.
+// Kubelet configuration is uploaded by kubeadm to the cluster and used by nodes joining it later
+func renderInitConfig(settings models.ClusterSettings, certificateKey string) (string, error) {
+	settings = settings.WithDefaults()
+
+	// kubeadm of pinned minor version is used when patch version isn't set
+	kubernetesVersion := ""
+	if version, err := ParseVersion(settings.KubernetesVersion); err == nil && version.Patch >= 0 {
+		kubernetesVersion = "v" + version.String()
+	}
+
+	componentArgs := featureGatesArgs(settings.FeatureGates)
+	return marshalDocuments(
+		initConfiguration{
+			APIVersion:       kubeadmAPIVersion,
+			Kind:             "InitConfiguration",
+			NodeRegistration: nodeRegistration{CRISocket: criSocket(settings.ContainerRuntime)},
+			CertificateKey:   certificateKey,
+		},
+		clusterConfiguration{
+			APIVersion:           kubeadmAPIVersion,
+			Kind:                 "ClusterConfiguration",
+			KubernetesVersion:    kubernetesVersion,
+			ControlPlaneEndpoint: settings.ControlPlaneEndpoint,
+			Networking:           networking{PodSubnet: settings.PodCIDR, ServiceSubnet: settings.ServiceCIDR},
+			APIServer:            apiServer{CertSANs: settings.APIServerCertSANs, ExtraArgs: componentArgs},
+			ControllerManager:    controlPlaneComponent{ExtraArgs: componentArgs},
+			Scheduler:            controlPlaneComponent{ExtraArgs: componentArgs},
+		},
+		kubeletConfiguration{
+			APIVersion:   kubeletAPIVersion,
+			Kind:         "KubeletConfiguration",
+			CgroupDriver: string(settings.CgroupDriver),
+			FeatureGates: settings.FeatureGates,
+			MaxPods:      settings.KubeletMaxPods,
+		},
+	)
+}
+
+// renderJoinConfig renders JoinConfiguration, certificateKey is set only for nodes joining as master
+func renderJoinConfig(runtime models.ContainerRuntime, endpoint, token, hash, certificateKey string) (string, error) {
+	config := joinConfiguration{
+		APIVersion:       kubeadmAPIVersion,
+		Kind:             "JoinConfiguration",
+		NodeRegistration: nodeRegistration{CRISocket: criSocket(runtime)},
+		Discovery: discovery{BootstrapToken: bootstrapTokenDiscovery{
+			APIServerEndpoint: endpoint,
+			Token:             token,
+			CACertHashes:      []string{hash},
+		}},
+	}
+	if certificateKey != "" {
+		config.ControlPlane = &joinControlPlane{CertificateKey: certificateKey}
+	}
+	return marshalDocuments(config)
+}
+
+// featureGatesArgs returns "feature-gates" flag of control-plane components
+func featureGatesArgs(gates map[string]bool) map[string]string {
+	if len(gates) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(gates))
+	for name := range gates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		values = append(values, fmt.Sprintf("%s=%t", name, gates[name]))
+	}
+	return map[string]string{"feature-gates": strings.Join(values, ",")}
+}
+
+func marshalDocuments(docs ...interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return "", err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// prepareInitConfig renders config for kubeadm init and stores it for auditing with certificate key redacted
+func (installer *Installer) prepareInitConfig(nodeID int, settings models.ClusterSettings, certificateKey string) (string, error) {
+	config, err := renderInitConfig(settings, certificateKey)
+	if err != nil {
+		return "", err
+	}
+
+	auditKey := ""
+	if certificateKey != "" {
+		auditKey = redactedValue
+	}
+	auditConfig, err := renderInitConfig(settings, auditKey)
+	if err != nil {
+		return "", err
+	}
+	return config, installer.saveKubeadmConfig(nodeID, internal.KUBEADM_CONFIG_INIT, auditConfig)
+}
+
+// prepareJoinConfig renders config for kubeadm join and stores it for auditing with token and certificate key redacted
+func (installer *Installer) prepareJoinConfig(nodeID int, runtime models.ContainerRuntime, endpoint, token, hash, certificateKey string) (string, error) {
+	config, err := renderJoinConfig(runtime, endpoint, token, hash, certificateKey)
+	if err != nil {
+		return "", err
+	}
+
+	auditKey := ""
+	if certificateKey != "" {
+		auditKey = redactedValue
+	}
+	auditConfig, err := renderJoinConfig(runtime, endpoint, redactedValue, hash, auditKey)
+	if err != nil {
+		return "", err
+	}
+	return config, installer.saveKubeadmConfig(nodeID, internal.KUBEADM_CONFIG_JOIN, auditConfig)
+}
+
+func (installer *Installer) saveKubeadmConfig(nodeID int, kind internal.KubeadmConfigKind, config string) error {
+	_, err := installer.r.AddKubeadmConfig(context.Background(), internal.KubeadmConfig{
+		NodeID:    nodeID,
+		ClusterID: 1,
+		Kind:      kind,
+		Config:    config,
+	})
+	return err
+}
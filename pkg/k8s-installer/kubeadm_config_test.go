@@ -0,0 +1,114 @@
+package k8s_installer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+func decodeDocuments(t *testing.T, config string) []map[string]interface{} {
+	t.Helper()
+	decoder := yaml.NewDecoder(strings.NewReader(config))
+	var docs []map[string]interface{}
+	for {
+		doc := map[string]interface{}{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func TestRenderInitConfig(t *testing.T) {
+	settings := models.ClusterSettings{
+		KubernetesVersion:    "1.30.2",
+		ControlPlaneEndpoint: "k8s.example.com:6443",
+		APIServerCertSANs:    []string{"k8s.example.com", "10.0.0.10"},
+		FeatureGates:         map[string]bool{"SidecarContainers": true, "InPlacePodVerticalScaling": false},
+		KubeletMaxPods:       200,
+	}
+	config, err := renderInitConfig(settings, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := decodeDocuments(t, config)
+	if len(docs) != 3 {
+		t.Fatalf("expected 3 documents, got %d:\n%s", len(docs), config)
+	}
+	for i, kind := range []string{"InitConfiguration", "ClusterConfiguration", "KubeletConfiguration"} {
+		if docs[i]["kind"] != kind {
+			t.Errorf("document %d kind = %v, want %s", i, docs[i]["kind"], kind)
+		}
+	}
+	if docs[0]["certificateKey"] != "key" {
+		t.Errorf("certificate key is not set:\n%s", config)
+	}
+
+	cluster := docs[1]
+	if cluster["kubernetesVersion"] != "v1.30.2" || cluster["controlPlaneEndpoint"] != "k8s.example.com:6443" {
+		t.Errorf("unexpected cluster configuration:\n%s", config)
+	}
+	networking := cluster["networking"].(map[string]interface{})
+	if networking["podSubnet"] != models.DefaultPodCIDR || networking["serviceSubnet"] != models.DefaultServiceCIDR {
+		t.Errorf("unexpected networking %v", networking)
+	}
+	extraArgs := cluster["apiServer"].(map[string]interface{})["extraArgs"].(map[string]interface{})
+	if extraArgs["feature-gates"] != "InPlacePodVerticalScaling=false,SidecarContainers=true" {
+		t.Errorf("unexpected feature gates %v", extraArgs["feature-gates"])
+	}
+
+	kubelet := docs[2]
+	if kubelet["cgroupDriver"] != string(models.CgroupDriverSystemd) || kubelet["maxPods"] != 200 {
+		t.Errorf("unexpected kubelet configuration:\n%s", config)
+	}
+}
+
+func TestRenderJoinConfig(t *testing.T) {
+	for _, certificateKey := range []string{"", "key"} {
+		config, err := renderJoinConfig(models.ContainerRuntimeCRIO, "10.0.0.10:6443", "abcdef.0123456789abcdef", "sha256:hash", certificateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := decodeDocuments(t, config)
+		if len(docs) != 1 || docs[0]["kind"] != "JoinConfiguration" {
+			t.Fatalf("unexpected join config:\n%s", config)
+		}
+		token := docs[0]["discovery"].(map[string]interface{})["bootstrapToken"].(map[string]interface{})
+		if token["apiServerEndpoint"] != "10.0.0.10:6443" || token["token"] != "abcdef.0123456789abcdef" {
+			t.Errorf("unexpected bootstrap token discovery %v", token)
+		}
+		if _, isControlPlane := docs[0]["controlPlane"]; isControlPlane != (certificateKey != "") {
+			t.Errorf("controlPlane section presence is wrong for certificate key %q:\n%s", certificateKey, config)
+		}
+	}
+}
+
+func TestValidateKubeadmSettings(t *testing.T) {
+	tests := []struct {
+		settings models.ClusterSettings
+		err      error
+	}{
+		{settings: models.ClusterSettings{CgroupDriver: models.CgroupDriverCgroupfs, APIServerCertSANs: []string{"*.example.com", "::1"}}},
+		{settings: models.ClusterSettings{CgroupDriver: "openrc"}, err: ErrInvalidKubeadmSettings},
+		{settings: models.ClusterSettings{APIServerCertSANs: []string{"bad name"}}, err: ErrInvalidKubeadmSettings},
+		{settings: models.ClusterSettings{FeatureGates: map[string]bool{"feature-gate": true}}, err: ErrInvalidKubeadmSettings},
+		{settings: models.ClusterSettings{KubeletMaxPods: -1}, err: ErrInvalidKubeadmSettings},
+		{settings: models.ClusterSettings{ContainerRuntime: models.ContainerRuntimeContainerd}},
+		{settings: models.ClusterSettings{ContainerRuntime: "docker"}, err: ErrInvalidKubeadmSettings},
+	}
+	for _, tt := range tests {
+		if err := validateKubeadmSettings(tt.settings); !errors.Is(err, tt.err) {
+			t.Errorf("validateKubeadmSettings(%+v) error = %v, want %v", tt.settings, err, tt.err)
+		}
+	}
+}
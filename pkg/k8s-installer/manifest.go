@@ -0,0 +1,61 @@
+package k8s_installer
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/manifests"
+)
+
+// manifestStep is embedded manifest applied from the server instead of a command run on the node
+type manifestStep struct {
+	name   manifests.Name
+	values interface{}
+}
+
+func (installer *Installer) manifestApplier() (*manifests.Applier, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", "./config")
+	if err != nil {
+		return nil, err
+	}
+	return manifests.NewApplier(config)
+}
+
+// applyManifest renders and validates manifest, logs diff of every object against the live one and applies them
+func (installer *Installer) applyManifest(ctx context.Context, applier *manifests.Applier, name manifests.Name, values interface{}, log *taskLog) error {
+	objects, err := manifests.Render(name, values)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		diff, err := applier.Diff(ctx, object)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			log.pushPhase(fmt.Sprintf("%s %s is up to date", object.GetKind(), object.GetName()), nil)
+			continue
+		}
+		log.pushPhase(fmt.Sprintf("apply %s %s", object.GetKind(), object.GetName()), []byte(diff))
+	}
+	return applier.Apply(ctx, objects...)
+}
+
+// manifestPlanStep returns rendered manifest, it is validated like during installation
+func manifestPlanStep(step manifestStep) (internal.PlanStep, error) {
+	if _, err := manifests.Render(step.name, step.values); err != nil {
+		return internal.PlanStep{}, err
+	}
+	data, err := manifests.RenderYAML(step.name, step.values)
+	if err != nil {
+		return internal.PlanStep{}, err
+	}
+	return internal.PlanStep{
+		Type:        internal.PLAN_STEP_MANIFEST,
+		Description: fmt.Sprintf("server-side apply as %s, diff against live objects is logged", manifests.FieldManager),
+		Manifest:    string(data),
+	}, nil
+}
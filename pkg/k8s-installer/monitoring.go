@@ -0,0 +1,211 @@
+package k8s_installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/manifests"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn/ssh"
+)
+
+var (
+	ErrInvalidMonitoringSettings = errors.New("invalid monitoring settings")
+
+	// storage path is put into shell command and PersistentVolume manifest unquoted
+	storagePathRe = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+)
+
+// monitoringStep is command or manifest of monitoring installation, storage steps run on the node keeping persistent volume
+type monitoringStep struct {
+	command  cl.CommandAndParser
+	manifest *manifestStep
+	storage  bool
+}
+
+func validateMonitoringSettings(settings models.ClusterSettings) error {
+	monitoring := settings.WithDefaults().Monitoring
+
+	if monitoring.IngressHost != "" && (!dnsNameRe.MatchString(monitoring.IngressHost) || monitoring.IngressHost[0] == '*') {
+		return fmt.Errorf("%w: ingress host %q isn't DNS name", ErrInvalidMonitoringSettings, monitoring.IngressHost)
+	}
+	if monitoring.BaseDomain != "" && (!dnsNameRe.MatchString(monitoring.BaseDomain) || monitoring.BaseDomain[0] == '*') {
+		return fmt.Errorf("%w: base domain %q isn't DNS name", ErrInvalidMonitoringSettings, monitoring.BaseDomain)
+	}
+	if !storagePathRe.MatchString(monitoring.StoragePath) || path.Clean(monitoring.StoragePath) != monitoring.StoragePath || monitoring.StoragePath == "/" {
+		return fmt.Errorf("%w: storage path %q must be clean absolute path of letters, digits, '.', '_' and '-'", ErrInvalidMonitoringSettings, monitoring.StoragePath)
+	}
+	if monitoring.StorageNodeID < 0 {
+		return fmt.Errorf("%w: invalid storage node id %d", ErrInvalidMonitoringSettings, monitoring.StorageNodeID)
+	}
+	return nil
+}
+
+// monitoringSteps returns steps preparing Grafana storage, ingress, datasource and dashboard.
+// Persistent volume is bound to storageHostname, all steps can be run again
+func (installer *Installer) monitoringSteps(storageLib cl.CommandLib, monitoring models.MonitoringSettings, storageHostname string) []monitoringStep {
+	steps := []monitoringStep{
+		{command: storageLib.CreateFolderForPV(monitoring.StoragePath), storage: true},
+		{manifest: &manifestStep{name: manifests.StorageClass}},
+		{manifest: &manifestStep{name: manifests.GrafanaPV, values: manifests.GrafanaPVValues{Hostname: storageHostname, Path: monitoring.StoragePath}}},
+	}
+	if host := monitoring.GrafanaHost(); host != "" {
+		steps = append(steps, monitoringStep{manifest: &manifestStep{name: manifests.GrafanaIngress, values: manifests.GrafanaIngressValues{Host: host}}})
+	}
+	return append(steps,
+		monitoringStep{manifest: &manifestStep{name: manifests.GrafanaDatasourceSecret}},
+		monitoringStep{manifest: &manifestStep{name: manifests.GrafanaDashboard}},
+	)
+}
+
+// monitoringStorageNode returns node keeping Grafana persistent volume, it is the first master by default
+func (installer *Installer) monitoringStorageNode(ctx context.Context, monitoring models.MonitoringSettings, master internal.FullNode) (internal.FullNode, error) {
+	if monitoring.StorageNodeID == 0 || monitoring.StorageNodeID == master.ID {
+		return master, nil
+	}
+	node, err := installer.r.GetFullNode(ctx, monitoring.StorageNodeID)
+	if err != nil {
+		return internal.FullNode{}, err
+	}
+	if node.ClusterID != 1 {
+		return internal.FullNode{}, fmt.Errorf("%w: storage node %s isn't in cluster", ErrInvalidMonitoringSettings, node.Name)
+	}
+	return node, nil
+}
+
+// InstallMonitoring installs Grafana with its storage, ingress, datasource and dashboard and forwards
+// Grafana and Prometheus ports. It can be run again after settings are changed or installation failed
+func (installer *Installer) InstallMonitoring(ctx context.Context, sendProgress func(percent int, status internal.TaskStatus, log string, err string), sendLog func(stream internal.LogStream, line string)) error {
+	log := newTaskLog(sendProgress)
+	fail := func(percent int, err error) error {
+		log.send(percent, internal.STATUS_ERROR, err.Error())
+		return err
+	}
+
+	settings, err := installer.r.GetClusterSettings(ctx, 1)
+	if err != nil {
+		return fail(1, err)
+	}
+	settings = settings.WithDefaults()
+	if err = validateMonitoringSettings(settings); err != nil {
+		return fail(1, err)
+	}
+
+	conn, master, err := installer.ConnectToControlPlane(ctx, 1)
+	if err != nil {
+		return fail(1, err)
+	}
+	defer func(conn client_conn.ClientConn) {
+		_ = conn.Close()
+	}(conn)
+
+	storageNode, err := installer.monitoringStorageNode(ctx, settings.Monitoring, master)
+	if err != nil {
+		return fail(1, err)
+	}
+	storageConn := conn
+	if storageNode.ID != master.ID {
+		storageConn, err = ssh.NewSSHBuilder().CreateCC(storageNode.IP, storageNode.Login, storageNode.Password)
+		if err != nil {
+			return fail(1, err)
+		}
+		defer func(conn client_conn.ClientConn) {
+			_ = conn.Close()
+		}(storageConn)
+	}
+
+	commandLib, err := nodeCommandLib(master)
+	if err != nil {
+		return fail(1, err)
+	}
+	storageLib, err := nodeCommandLib(storageNode)
+	if err != nil {
+		return fail(1, err)
+	}
+	storageHostname, err := installer.nodeName(storageConn, storageLib)
+	if err != nil {
+		return fail(1, err)
+	}
+	log.pushPhase(fmt.Sprintf("grafana storage is %s on node %s", settings.Monitoring.StoragePath, storageHostname), nil)
+
+	applier, err := installer.manifestApplier()
+	if err != nil {
+		return fail(1, err)
+	}
+
+	steps := installer.monitoringSteps(storageLib, settings.Monitoring, storageHostname)
+	// chart installation, password reset and port-forwards follow the steps
+	total := len(steps) + 4
+	for i, step := range steps {
+		percent := (i + 1) * 100 / total
+		if step.manifest != nil {
+			if err = installer.applyManifest(ctx, applier, step.manifest.name, step.manifest.values, log); err != nil {
+				return fail(percent, err)
+			}
+			log.send(percent, internal.STATUS_IN_PROCESS, "")
+			continue
+		}
+
+		stepConn := conn
+		if step.storage {
+			stepConn = storageConn
+		}
+
+		result, err := installer.exec(stepConn, step.command, sendLog)
+		log.pushResult(step.command, result)
+		if err != nil && step.command.Condition != cl.Anyway {
+			installer.l.Error("exec failed", zap.ByteString("command", commandText(step.command)), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", RedactSecrets(result.Stderr, step.command.Secrets)))
+			return fail(percent, err)
+		}
+		if step.command.Parser != nil {
+			if err = step.command.Parser(result, nil); err != nil {
+				return fail(percent, err)
+			}
+		}
+		log.send(percent, internal.STATUS_IN_PROCESS, "")
+	}
+
+	// grafana datasource points to prometheus, it is installed with cluster but may have been removed since
+	if _, err = installer.ensureRelease(ctx, prometheusRelease, log); err != nil {
+		return fail((len(steps)+1)*100/total, err)
+	}
+	// persistent volume and secrets have to be created before grafana pod starts
+	time.Sleep(1 * time.Minute)
+	installed, err := installer.ensureRelease(ctx, grafanaRelease, log)
+	if err != nil {
+		return fail((len(steps)+1)*100/total, err)
+	}
+	if installed {
+		time.Sleep(1 * time.Minute)
+	}
+	log.send((len(steps)+1)*100/total, internal.STATUS_IN_PROCESS, "")
+
+	command := commandLib.ResetGrafanaAdminPassword(grafanaAdminPassword)
+	result, err := installer.exec(conn, command, sendLog)
+	log.pushResult(command, result)
+	if err != nil {
+		installer.l.Error("exec failed", zap.ByteString("command", commandText(command)), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", RedactSecrets(result.Stderr, command.Secrets)))
+		return fail((len(steps)+2)*100/total, err)
+	}
+	log.send((len(steps)+2)*100/total, internal.STATUS_IN_PROCESS, "")
+
+	for _, pf := range []portForward{grafanaPortForward, prometheusPortForward} {
+		if err = installer.portForwarding(pf); err != nil {
+			installer.l.Error("port-forward failed", zap.String("app", pf.appName), zap.Error(err))
+			return fail((len(steps)+3)*100/total, err)
+		}
+		log.pushPhase(fmt.Sprintf("port %s of %s forwarded", pf.portLocal, pf.appName), nil)
+	}
+
+	log.send(100, internal.STATUS_SUCCESS, "")
+	return nil
+}
@@ -0,0 +1,49 @@
+package k8s_installer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+func TestValidateMonitoringSettings(t *testing.T) {
+	tests := []struct {
+		name       string
+		monitoring models.MonitoringSettings
+		err        error
+	}{
+		{name: "defaults"},
+		{name: "ingress host", monitoring: models.MonitoringSettings{IngressHost: "grafana.example.com", StoragePath: "/data/grafana"}},
+		{name: "base domain", monitoring: models.MonitoringSettings{BaseDomain: "user1.huginn.pro"}},
+		{name: "wildcard host", monitoring: models.MonitoringSettings{IngressHost: "*.example.com"}, err: ErrInvalidMonitoringSettings},
+		{name: "invalid base domain", monitoring: models.MonitoringSettings{BaseDomain: "Example_com"}, err: ErrInvalidMonitoringSettings},
+		{name: "relative path", monitoring: models.MonitoringSettings{StoragePath: "data/grafana"}, err: ErrInvalidMonitoringSettings},
+		{name: "root path", monitoring: models.MonitoringSettings{StoragePath: "/"}, err: ErrInvalidMonitoringSettings},
+		{name: "unclean path", monitoring: models.MonitoringSettings{StoragePath: "/data/../etc"}, err: ErrInvalidMonitoringSettings},
+		{name: "shell in path", monitoring: models.MonitoringSettings{StoragePath: "/data; rm -rf /"}, err: ErrInvalidMonitoringSettings},
+		{name: "negative node", monitoring: models.MonitoringSettings{StorageNodeID: -1}, err: ErrInvalidMonitoringSettings},
+	}
+	for _, tt := range tests {
+		err := validateMonitoringSettings(models.ClusterSettings{Monitoring: tt.monitoring})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: validateMonitoringSettings() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestGrafanaHost(t *testing.T) {
+	tests := []struct {
+		monitoring models.MonitoringSettings
+		want       string
+	}{
+		{monitoring: models.MonitoringSettings{}, want: ""},
+		{monitoring: models.MonitoringSettings{BaseDomain: "example.com"}, want: "grafana.example.com"},
+		{monitoring: models.MonitoringSettings{IngressHost: "metrics.example.org", BaseDomain: "example.com"}, want: "metrics.example.org"},
+	}
+	for _, tt := range tests {
+		if got := tt.monitoring.GrafanaHost(); got != tt.want {
+			t.Errorf("GrafanaHost() of %+v = %q, want %q", tt.monitoring, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package k8s_installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/bundle"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/distro"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/offline"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/remote_exec"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+)
+
+// bundleChartsDir is where charts of offline bundle are extracted on the server
+var bundleChartsDir = filepath.Join(os.TempDir(), "paas-bundle-charts")
+
+// BundleCharts are charts of add-ons and resources, offline bundle has all of them
+var BundleCharts = []bundle.ChartSource{
+	{Repo: "metallb", URL: "https://metallb.github.io/metallb", Name: "metallb"},
+	{Repo: "bitnami", URL: "https://charts.bitnami.com/bitnami", Name: "nginx-ingress-controller"},
+	{Repo: "bitnami", URL: "https://charts.bitnami.com/bitnami", Name: "kube-prometheus"},
+	{Repo: "bitnami", URL: "https://charts.bitnami.com/bitnami", Name: "grafana"},
+	{Repo: "bitnami", URL: "https://charts.bitnami.com/bitnami", Name: "postgresql"},
+	{Repo: "bitnami", URL: "https://charts.bitnami.com/bitnami", Name: "redis"},
+}
+
+// CNIManifestURL returns URL of manifest of network plugin which is put into offline bundle,
+// cilium is installed by its CLI and has no manifest
+func CNIManifestURL(cni models.CNI) string {
+	switch cni {
+	case models.CNICalico:
+		return cl.CalicoManifestURL(calicoVersion)
+	case models.CNICilium:
+		return ""
+	default:
+		return cl.FlannelManifestURL(flannelVersion)
+	}
+}
+
+// openBundle reads offline bundle of cluster settings and checks that it fits them. It returns nil manifest
+// when cluster is installed from the internet
+func openBundle(settings models.ClusterSettings) (*bundle.Manifest, error) {
+	if settings.OfflineBundle == "" {
+		return nil, nil
+	}
+	manifest, err := bundle.Open(settings.OfflineBundle)
+	if err != nil {
+		return nil, err
+	}
+	if err = manifest.Validate(settings); err != nil {
+		return nil, err
+	}
+	if version := CNIVersion(settings.WithDefaults().CNI); manifest.CNIVersion != version {
+		return nil, fmt.Errorf("%w: bundle has %s %s, installer applies %s", bundle.ErrInvalidBundle, manifest.CNI, manifest.CNIVersion, version)
+	}
+	return manifest, nil
+}
+
+// offlineCommandLib wraps command library of the node when cluster is installed from offline bundle,
+// commandLib is returned as it is otherwise
+func offlineCommandLib(commandLib cl.CommandLib, node internal.FullNode, settings models.ClusterSettings) (cl.CommandLib, error) {
+	manifest, err := openBundle(settings)
+	if err != nil || manifest == nil {
+		return commandLib, err
+	}
+	d := distro.Distro(node.OS)
+	if d == "" {
+		d = distro.Ubuntu2004
+	}
+	if err = manifest.ValidateDistro(string(d)); err != nil {
+		return nil, err
+	}
+	return offline.NewCommandLib(commandLib, manifest), nil
+}
+
+// uploadBundle uploads bundle archive to the node unless the node has the same archive from previous installation
+func (installer *Installer) uploadBundle(conn client_conn.ClientConn, commandLib *offline.CommandLib, archive string, log *taskLog) error {
+	checksum, err := bundle.Checksum(archive)
+	if err != nil {
+		return err
+	}
+	output, err := installer.output(conn, commandLib.ArchiveChecksum())
+	if err == nil && strings.HasPrefix(string(output), checksum+" ") {
+		log.pushPhase("offline bundle "+checksum+" is already uploaded", nil)
+		return nil
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	uploaded, err := remote_exec.Upload(conn, remote_exec.File{Path: offline.ArchivePath, Content: f, Mode: 0644})
+	log.push([]byte(uploadText(offline.ArchivePath, 0644, "")), []byte(uploadOutput(uploaded, err)))
+	return err
+}
+
+// UseBundleCharts makes helm install charts of offline bundle of cluster settings instead of charts of repositories,
+// repositories are used again when bundle is removed from settings
+func (installer *Installer) UseBundleCharts(ctx context.Context) error {
+	settings, err := installer.r.GetClusterSettings(ctx, 1)
+	if err != nil {
+		return err
+	}
+	if settings.OfflineBundle == "" {
+		installer.hi.SetLocalCharts(nil)
+		return nil
+	}
+	charts, err := bundle.ExtractCharts(settings.OfflineBundle, bundleChartsDir)
+	if err != nil {
+		return err
+	}
+	installer.hi.SetLocalCharts(charts)
+	return nil
+}
@@ -0,0 +1,335 @@
+package k8s_installer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/helm"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/manifests"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/offline"
+)
+
+const (
+	ROLE_MASTER = "master"
+	ROLE_WORKER = "worker"
+
+	redactedValue = "<redacted>"
+	// hostname is read from the node during installation, plans are built without connecting to it
+	planHostname = "<hostname>"
+)
+
+// PlanInstallK8S returns steps which InstallK8S would do for the node without running them
+func (installer *Installer) PlanInstallK8S(node internal.FullNode, controlPlane bool) (internal.Plan, error) {
+	nodeID, nodeIP := node.ID, node.IP.Addr().String()
+	commandLib, err := nodeCommandLib(node)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+
+	isClusterExists, err := installer.r.CheckClusterTokenIPAndHash(context.Background(), 1)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+
+	settings, err := installer.r.GetClusterSettings(context.Background(), 1)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	if err = ValidateClusterSettings(settings); err != nil {
+		return internal.Plan{}, err
+	}
+	if err = validateNodeCgroupDriver(commandLib, settings); err != nil {
+		return internal.Plan{}, err
+	}
+	if commandLib, err = offlineCommandLib(commandLib, node, settings); err != nil {
+		return internal.Plan{}, err
+	}
+
+	commands, err := installer.installKubeadm(commandLib, settings)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	var uploadSteps []internal.PlanStep
+	if _, ok := commandLib.(*offline.CommandLib); ok {
+		uploadSteps = append(uploadSteps, internal.PlanStep{
+			Type:        internal.PLAN_STEP_UPLOAD,
+			Description: "offline bundle " + settings.OfflineBundle + ", skipped when the node has the same one",
+			Path:        offline.ArchivePath,
+			Mode:        fileMode(0644),
+		})
+	}
+	if isClusterExists && controlPlane {
+		_, ip, hash, err := installer.r.GetClusterTokenIPAndHash(context.Background(), 1)
+		if err != nil {
+			return internal.Plan{}, err
+		}
+		certificateKey, err := installer.r.GetClusterCertificateKey(context.Background(), 1)
+		if err != nil {
+			return internal.Plan{}, err
+		}
+		if settings.WithDefaults().ControlPlaneEndpoint == "" || certificateKey == "" {
+			return internal.Plan{}, ErrNoControlPlaneEndpoint
+		}
+
+		// kubeadm commands are the same on all distros, so the plan uses command library of the node for master commands too
+		steps := []internal.PlanStep{{
+			Type:        internal.PLAN_STEP_COMMAND,
+			Description: "run on available master",
+			Command:     string(commandLib.UploadCerts(redactedValue).Command),
+			Condition:   cl.Required.String(),
+		}}
+		config, err := renderJoinConfig(settings.WithDefaults().ContainerRuntime, ip, redactedValue, hash, redactedValue)
+		if err != nil {
+			return internal.Plan{}, err
+		}
+		commands = append(commands, installer.kubeadmJoin(commandLib, settings, config, true)...)
+
+		steps = append(steps, uploadSteps...)
+		steps = append(steps, commandSteps(commandLib.ListKubeadmTokens())...)
+		steps = append(steps, repositoryStep("create join token on available master when the stored one expires within an hour"))
+		steps = append(steps, repositoryStep("save rendered kubeadm join config"))
+		steps = append(steps, commandSteps(commands...)...)
+		steps = append(steps, repositoryStep("set cluster of the node"), repositoryStep("mark node as master"))
+		return internal.Plan{NodeID: nodeID, Role: ROLE_MASTER, Steps: steps}, nil
+	}
+	if isClusterExists {
+		_, ip, hash, err := installer.r.GetClusterTokenIPAndHash(context.Background(), 1)
+		if err != nil {
+			return internal.Plan{}, err
+		}
+		config, err := renderJoinConfig(settings.WithDefaults().ContainerRuntime, ip, redactedValue, hash, "")
+		if err != nil {
+			return internal.Plan{}, err
+		}
+		commands = append(commands, installer.kubeadmJoin(commandLib, settings, config, false)...)
+
+		steps := append(uploadSteps, commandSteps(commandLib.ListKubeadmTokens())...)
+		steps = append(steps,
+			repositoryStep("create join token on available master when the stored one expires within an hour"),
+			repositoryStep("save rendered kubeadm join config"),
+		)
+		steps = append(steps, commandSteps(commands...)...)
+		steps = append(steps, repositoryStep("set cluster of the node"))
+		return internal.Plan{NodeID: nodeID, Role: ROLE_WORKER, Steps: steps}, nil
+	}
+
+	steps := uploadSteps
+	certificateKey := ""
+	if settings.WithDefaults().ControlPlaneEndpoint != "" {
+		certificateKey = redactedValue
+		steps = append(steps, repositoryStep("generate and save certificate key for uploaded control-plane certificates"))
+	}
+	config, err := renderInitConfig(settings, certificateKey)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	steps = append(steps, repositoryStep("save rendered kubeadm init config"))
+	commands = append(commands, installer.kubeadmInit(commandLib, settings, config, "", nodeID)...)
+
+	steps = append(steps, commandSteps(commands...)...)
+	steps = append(steps,
+		repositoryStep("save join token, control-plane address and CA cert hash printed by kubeadm init"),
+		repositoryStep("set cluster of the node"),
+	)
+	steps = append(steps, commandSteps(commandLib.CatAdminConfFile())...)
+
+	charts := make(map[string]internal.PlanStep, 3)
+	for _, release := range []chartRelease{metallbRelease, nginxIngressRelease, prometheusRelease} {
+		if charts[release.name], err = chartStep(release); err != nil {
+			return internal.Plan{}, err
+		}
+	}
+
+	steps = append(steps, charts[metallbRelease.name])
+	metallbConf, err := manifestPlanStep(manifestStep{name: manifests.MetallbConf, values: manifests.MetallbValues{IP: nodeIP}})
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	steps = append(steps, metallbConf)
+	steps = append(steps, charts[nginxIngressRelease.name])
+	steps = append(steps, charts[prometheusRelease.name], portForwardStep(prometheusPortForward))
+	if settings.Monitoring.Enabled {
+		monitoringSteps, err := installer.planMonitoring(commandLib, settings)
+		if err != nil {
+			return internal.Plan{}, err
+		}
+		steps = append(steps, monitoringSteps...)
+	}
+
+	return internal.Plan{NodeID: nodeID, Role: ROLE_MASTER, Steps: steps}, nil
+}
+
+// planMonitoring returns steps which InstallMonitoring would do without running them, storage node is
+// resolved during installation, so its steps are planned with command library of the master
+func (installer *Installer) planMonitoring(commandLib cl.CommandLib, settings models.ClusterSettings) ([]internal.PlanStep, error) {
+	settings = settings.WithDefaults()
+
+	steps := commandSteps(commandLib.Hostname())
+	for _, step := range installer.monitoringSteps(commandLib, settings.Monitoring, planHostname) {
+		if step.manifest != nil {
+			planStep, err := manifestPlanStep(*step.manifest)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, planStep)
+			continue
+		}
+		planStep := commandSteps(step.command)[0]
+		if step.storage {
+			planStep.Description = "on storage node"
+		}
+		steps = append(steps, planStep)
+	}
+
+	for _, release := range []chartRelease{prometheusRelease, grafanaRelease} {
+		chart, err := chartStep(release)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, chart)
+	}
+	steps = append(steps, commandSteps(commandLib.ResetGrafanaAdminPassword(redactedValue))...)
+	return append(steps, portForwardStep(grafanaPortForward), portForwardStep(prometheusPortForward)), nil
+}
+
+// PlanRemoveK8S returns steps which RemoveK8S and the following cleanup would do for the node without running them.
+// Workers are passed when the cluster is destroyed with its last master, they are reset before it
+func (installer *Installer) PlanRemoveK8S(node internal.FullNode, lastMaster bool, workers []internal.FullNode) (internal.Plan, error) {
+	nodeID, isMaster := node.ID, node.IsMaster
+	commandLib, err := nodeCommandLib(node)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+
+	settings, err := installer.r.GetClusterSettings(context.Background(), 1)
+	if err != nil {
+		return internal.Plan{}, err
+	}
+	settings = settings.WithDefaults()
+
+	plan := internal.Plan{NodeID: nodeID, Role: ROLE_WORKER}
+	if isMaster {
+		plan.Role = ROLE_MASTER
+	}
+
+	for _, worker := range workers {
+		workerLib, err := nodeCommandLib(worker)
+		if err != nil {
+			return internal.Plan{}, err
+		}
+		steps := append(commandSteps(workerLib.Hostname()), commandSteps(installer.kubeadmReset(workerLib, settings)...)...)
+		for i := range steps {
+			steps[i].Description = "on worker " + worker.Name
+		}
+		plan.Steps = append(plan.Steps, steps...)
+		plan.Steps = append(plan.Steps, repositoryStep("reset cluster of worker "+worker.Name))
+	}
+
+	plan.Steps = append(plan.Steps, commandSteps(commandLib.Hostname())...)
+	if !lastMaster {
+		plan.Steps = append(plan.Steps, kubernetesAPIStep(fmt.Sprintf("cordon and drain node respecting PodDisruptionBudgets with timeout %s", settings.DrainTimeout)))
+	}
+	plan.Steps = append(plan.Steps, commandSteps(installer.kubeadmReset(commandLib, settings)...)...)
+	if !lastMaster {
+		plan.Steps = append(plan.Steps, kubernetesAPIStep("delete Node object"))
+	}
+	plan.Steps = append(plan.Steps, repositoryStep("reset cluster of the node"))
+	if lastMaster {
+		plan.Steps = append(plan.Steps, repositoryStep("delete join token, control-plane address and CA cert hash of the cluster"))
+	}
+	return plan, nil
+}
+
+// commandSteps returns steps of commands, command uploading file has upload step before its command step
+func commandSteps(commands ...cl.CommandAndParser) []internal.PlanStep {
+	steps := make([]internal.PlanStep, 0, len(commands))
+	for _, command := range commands {
+		if command.File != nil {
+			steps = append(steps, internal.PlanStep{
+				Type:      internal.PLAN_STEP_UPLOAD,
+				Condition: command.Condition.String(),
+				Path:      command.File.Path,
+				Mode:      fileMode(command.File.Mode),
+				Owner:     command.File.Owner,
+				Content:   string(command.File.Content),
+			})
+			if command.Command == "" {
+				continue
+			}
+		}
+		steps = append(steps, internal.PlanStep{
+			Type:      internal.PLAN_STEP_COMMAND,
+			Command:   string(command.Command),
+			Condition: command.Condition.String(),
+		})
+	}
+	return steps
+}
+
+// fileMode formats mode like chmod argument
+func fileMode(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+func chartStep(release chartRelease) (internal.PlanStep, error) {
+	vals, err := helm.ParseArgs(release.args)
+	if err != nil {
+		return internal.PlanStep{}, err
+	}
+	redactSecretValues(vals)
+
+	return internal.PlanStep{
+		Type:        internal.PLAN_STEP_HELM_CHART,
+		Description: "installed unless release already exists, existing release is adopted",
+		Release:     release.name,
+		Chart:       fmt.Sprintf("%s/%s", release.repo, release.chart),
+		Values:      vals,
+	}, nil
+}
+
+func portForwardStep(pf portForward) internal.PlanStep {
+	return internal.PlanStep{
+		Type:        internal.PLAN_STEP_PORT_FORWARD,
+		Description: fmt.Sprintf("forward 0.0.0.0:%s to port %s of %s pod in %s namespace", pf.portLocal, pf.portRemote, pf.appName, pf.namespace),
+	}
+}
+
+func kubernetesAPIStep(description string) internal.PlanStep {
+	return internal.PlanStep{
+		Type:        internal.PLAN_STEP_KUBERNETES_API,
+		Description: description,
+	}
+}
+
+func repositoryStep(description string) internal.PlanStep {
+	return internal.PlanStep{
+		Type:        internal.PLAN_STEP_REPOSITORY,
+		Description: description,
+	}
+}
+
+// redactSecretValues replaces values of keys which look like passwords or tokens
+func redactSecretValues(vals map[string]interface{}) {
+	for key, val := range vals {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			redactSecretValues(v)
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					redactSecretValues(m)
+				}
+			}
+		default:
+			lowerKey := strings.ToLower(key)
+			if strings.Contains(lowerKey, "password") || strings.Contains(lowerKey, "token") {
+				vals[key] = redactedValue
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package k8s_installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+const (
+	crioSocket       = "unix:///var/run/crio/crio.sock"
+	containerdSocket = "unix:///run/containerd/containerd.sock"
+)
+
+func validateContainerRuntime(settings models.ClusterSettings) error {
+	settings = settings.WithDefaults()
+
+	switch settings.ContainerRuntime {
+	case models.ContainerRuntimeCRIO, models.ContainerRuntimeContainerd:
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported container runtime %q, supported are %s and %s", ErrInvalidKubeadmSettings, settings.ContainerRuntime, models.ContainerRuntimeCRIO, models.ContainerRuntimeContainerd)
+}
+
+// validateNodeCgroupDriver checks that distro of the node supports cgroup driver of the cluster
+func validateNodeCgroupDriver(commandLib cl.CommandLib, settings models.ClusterSettings) error {
+	settings = settings.WithDefaults()
+
+	drivers := commandLib.CgroupDrivers()
+	for _, driver := range drivers {
+		if driver == string(settings.CgroupDriver) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: cgroup driver %q isn't supported by distro of the node, supported are %s", ErrInvalidKubeadmSettings, settings.CgroupDriver, strings.Join(drivers, ", "))
+}
+
+// criSocket returns endpoint of container runtime which kubelet connects to
+func criSocket(runtime models.ContainerRuntime) string {
+	if runtime == models.ContainerRuntimeContainerd {
+		return containerdSocket
+	}
+	return crioSocket
+}
+
+// runtimeCommands installs and starts container runtime of the cluster with cgroup driver of kubelet
+func runtimeCommands(commandLib cl.CommandLib, settings models.ClusterSettings) ([]cl.CommandAndParser, error) {
+	settings = settings.WithDefaults()
+
+	if settings.ContainerRuntime == models.ContainerRuntimeContainerd {
+		return []cl.CommandAndParser{
+			commandLib.InstallContainerd(),
+			commandLib.SetContainerdCgroupDriver(string(settings.CgroupDriver)),
+			commandLib.StartContainerd(),
+		}, nil
+	}
+
+	crioVersion, err := ParseVersion(settings.CRIOVersion)
+	if err != nil {
+		return nil, err
+	}
+	return []cl.CommandAndParser{
+		commandLib.AddCRIORepos(crioVersion.MinorString()),
+		commandLib.ImportGPGKey(crioVersion.MinorString()),
+		commandLib.SudoUpdate(),
+		commandLib.InstallCRIO(crioVersion.PackagePin()),
+		commandLib.SetCRIOCgroupManager(string(settings.CgroupDriver)),
+		commandLib.StartCRIO(),
+	}, nil
+}
+
+// stopRuntime stops container runtime of the cluster before its state is removed
+func stopRuntime(commandLib cl.CommandLib, settings models.ClusterSettings) cl.CommandAndParser {
+	if settings.WithDefaults().ContainerRuntime == models.ContainerRuntimeContainerd {
+		return commandLib.StopContainerd()
+	}
+	return commandLib.StopCRIO()
+}
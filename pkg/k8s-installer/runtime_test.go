@@ -0,0 +1,85 @@
+package k8s_installer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/cltest"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+func TestRuntimeCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings models.ClusterSettings
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "cri-o",
+			settings: models.ClusterSettings{CRIOVersion: "1.30"},
+			want:     []string{"pkgs.k8s.io/addons:/cri-o:/stable:/v1.30/deb/", "'cri-o=1.30.*'", `cgroup_manager = "systemd"`, "systemctl start crio.service"},
+			notWant:  []string{"containerd"},
+		},
+		{
+			name:     "containerd",
+			settings: models.ClusterSettings{ContainerRuntime: models.ContainerRuntimeContainerd},
+			want:     []string{"apt-get install -y containerd", "SystemdCgroup = true", "systemctl restart containerd.service"},
+			notWant:  []string{"cri-o", "crio"},
+		},
+	}
+	for _, tt := range tests {
+		commands, err := runtimeCommands(&ubuntu.Ubuntu2204CommandLib{}, tt.settings)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		cltest.AssertContains(t, tt.name, joinCommands(commands), tt.want, tt.notWant)
+	}
+}
+
+func TestValidateNodeCgroupDriver(t *testing.T) {
+	tests := []struct {
+		name       string
+		commandLib cl.CommandLib
+		driver     models.CgroupDriver
+		err        error
+	}{
+		{name: "systemd on 20.04", commandLib: &ubuntu.Ubuntu2004CommandLib{}, driver: models.CgroupDriverSystemd},
+		{name: "cgroupfs on 20.04", commandLib: &ubuntu.Ubuntu2004CommandLib{}, driver: models.CgroupDriverCgroupfs},
+		{name: "systemd on 22.04", commandLib: &ubuntu.Ubuntu2204CommandLib{}, driver: models.CgroupDriverSystemd},
+		{name: "cgroupfs on 22.04", commandLib: &ubuntu.Ubuntu2204CommandLib{}, driver: models.CgroupDriverCgroupfs, err: ErrInvalidKubeadmSettings},
+	}
+	for _, tt := range tests {
+		err := validateNodeCgroupDriver(tt.commandLib, models.ClusterSettings{CgroupDriver: tt.driver})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestJoinConfigCRISocket(t *testing.T) {
+	for runtime, socket := range map[models.ContainerRuntime]string{
+		models.ContainerRuntimeCRIO:       crioSocket,
+		models.ContainerRuntimeContainerd: containerdSocket,
+	} {
+		config, err := renderJoinConfig(runtime, "10.0.0.10:6443", "abcdef.0123456789abcdef", "sha256:hash", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(config, "criSocket: "+socket) {
+			t.Errorf("join config of %s doesn't use socket %s:\n%s", runtime, socket, config)
+		}
+	}
+}
+
+func joinCommands(commands []cl.CommandAndParser) string {
+	lines := make([]string, 0, len(commands))
+	for _, command := range commands {
+		lines = append(lines, command.String())
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,243 @@
+package k8s_installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal"
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+	"github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn/ssh"
+)
+
+var ErrUpgradeVersion = errors.New("invalid upgrade version")
+
+// upgradeStep is command of node upgrade or drain and uncordon of the node, which are done by API server of the cluster
+type upgradeStep struct {
+	command  cl.CommandAndParser
+	drain    bool
+	uncordon bool
+}
+
+// ValidateUpgrade returns cluster settings after upgrade to version. Kubeadm upgrades cluster
+// only to the same or the next minor version, CRI-O is upgraded to the same minor version as Kubernetes
+func ValidateUpgrade(current models.ClusterSettings, version string) (models.ClusterSettings, error) {
+	current = current.WithDefaults()
+	if current.OfflineBundle != "" {
+		return models.ClusterSettings{}, fmt.Errorf("%w: packages of other versions aren't in offline bundle, cluster installed from it can't be upgraded", ErrUpgradeVersion)
+	}
+	currentVersion, err := ParseVersion(current.KubernetesVersion)
+	if err != nil {
+		return models.ClusterSettings{}, err
+	}
+	targetVersion, err := ParseVersion(version)
+	if err != nil {
+		return models.ClusterSettings{}, err
+	}
+
+	if targetVersion.Major != currentVersion.Major || targetVersion.Minor < currentVersion.Minor || targetVersion.Minor > currentVersion.Minor+1 {
+		return models.ClusterSettings{}, fmt.Errorf("%w: cluster of version %s can be upgraded only to 1.%d or 1.%d", ErrUpgradeVersion, currentVersion, currentVersion.Minor, currentVersion.Minor+1)
+	}
+	// version without patch is the latest patch of the minor version, which may be the current one, so only the next minor is accepted
+	if targetVersion.Minor == currentVersion.Minor && (targetVersion.Patch < 0 || targetVersion.Patch <= currentVersion.Patch) {
+		return models.ClusterSettings{}, fmt.Errorf("%w: %s isn't newer than current version %s", ErrUpgradeVersion, targetVersion, currentVersion)
+	}
+
+	upgraded := current
+	upgraded.KubernetesVersion = targetVersion.String()
+	upgraded.CRIOVersion = fmt.Sprintf("%d.%d", targetVersion.Major, targetVersion.Minor)
+	return upgraded, ValidateVersions(upgraded)
+}
+
+// upgradeSteps returns commands upgrading the node named nodeName. Control plane is upgraded by kubeadm upgrade apply
+// on the first master, other nodes are upgraded by kubeadm upgrade node
+func (installer *Installer) upgradeSteps(commandLib cl.CommandLib, settings models.ClusterSettings, nodeName string, isMaster, isFirstMaster bool) ([]upgradeStep, error) {
+	k8sVersion, err := ParseVersion(settings.KubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
+	crioVersion, err := ParseVersion(settings.CRIOVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeSteps := func(commands ...cl.CommandAndParser) []upgradeStep {
+		steps := make([]upgradeStep, 0, len(commands))
+		for _, command := range commands {
+			steps = append(steps, upgradeStep{command: command})
+		}
+		return steps
+	}
+
+	drain := upgradeStep{drain: true}
+	uncordon := upgradeStep{uncordon: true}
+
+	var steps []upgradeStep
+	if !isMaster {
+		steps = append(steps, drain)
+	}
+	// containerd of the distro repository isn't tied to Kubernetes version, only CRI-O is upgraded with it
+	crio := settings.WithDefaults().ContainerRuntime == models.ContainerRuntimeCRIO
+	steps = append(steps, nodeSteps(
+		commandLib.DownloadK8SSigningKey(k8sVersion.MinorString()),
+		commandLib.AddK8SRepo(k8sVersion.MinorString()),
+	)...)
+	if crio {
+		steps = append(steps, nodeSteps(
+			commandLib.AddCRIORepos(crioVersion.MinorString()),
+			commandLib.ImportGPGKey(crioVersion.MinorString()),
+		)...)
+	}
+	steps = append(steps, nodeSteps(
+		commandLib.SudoUpdate(),
+		commandLib.UpgradeKubeadm(k8sVersion.PackagePin()),
+	)...)
+	if isFirstMaster {
+		steps = append(steps, nodeSteps(commandLib.KubeadmUpgradePlan(k8sVersion.String()), commandLib.KubeadmUpgradeApply(k8sVersion.String()))...)
+	} else {
+		steps = append(steps, nodeSteps(commandLib.KubeadmUpgradeNode())...)
+	}
+	if isMaster {
+		steps = append(steps, drain)
+	}
+	if crio {
+		steps = append(steps, nodeSteps(
+			commandLib.InstallCRIO(crioVersion.PackagePin()),
+			commandLib.RestartCRIO(),
+		)...)
+	}
+	steps = append(steps, nodeSteps(commandLib.UpgradeKubelet(k8sVersion.PackagePin()))...)
+	return append(steps, uncordon), nil
+}
+
+// UpgradeCluster upgrades masters one by one and then workers one by one to the version of upgraded settings.
+// Upgrade stops at the first failed node, nodes which weren't upgraded are reported with error status
+func (installer *Installer) UpgradeCluster(ctx context.Context, upgraded models.ClusterSettings, sendProgress func(nodeID int, percent int, status internal.TaskStatus, log string, err string), sendLog func(nodeID int, stream internal.LogStream, line string)) error {
+	masters, err := installer.r.GetControlPlaneNodes(ctx, 1)
+	if err != nil {
+		return err
+	}
+	if len(masters) == 0 {
+		return ErrNoControlPlaneAvailable
+	}
+	nodes, err := installer.r.GetNodes(ctx)
+	if err != nil {
+		return err
+	}
+
+	order := masters
+	for _, node := range nodes {
+		if node.ClusterID == 1 && !node.IsMaster {
+			order = append(order, node)
+		}
+	}
+	for _, node := range order {
+		sendProgress(node.ID, 0, internal.STATUS_IN_QUEUE, "", "")
+	}
+
+	for i, node := range order {
+		nodeID := node.ID
+		err = installer.upgradeNode(ctx, node, i == 0, upgraded,
+			func(percent int, status internal.TaskStatus, log string, err string) {
+				sendProgress(nodeID, percent, status, log, err)
+			},
+			func(stream internal.LogStream, line string) {
+				sendLog(nodeID, stream, line)
+			},
+		)
+		if err != nil {
+			for _, skipped := range order[i+1:] {
+				sendProgress(skipped.ID, 0, internal.STATUS_ERROR, "", fmt.Sprintf("upgrade stopped after failure of node %s", node.Name))
+			}
+			return err
+		}
+	}
+
+	return installer.r.SetClusterSettings(ctx, 1, upgraded)
+}
+
+func (installer *Installer) upgradeNode(ctx context.Context, node internal.FullNode, isFirstMaster bool, settings models.ClusterSettings, sendProgress func(percent int, status internal.TaskStatus, log string, err string), sendLog func(stream internal.LogStream, line string)) error {
+	sendProgress(1, internal.STATUS_START, "", "")
+
+	sshBuilder := ssh.NewSSHBuilder()
+	conn, err := sshBuilder.CreateCC(node.IP, node.Login, node.Password)
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
+	defer func(conn client_conn.ClientConn) {
+		_ = conn.Close()
+	}(conn)
+
+	client, err := installer.kubeClient()
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
+	// drain timeout is validated with cluster settings
+	timeout, _ := time.ParseDuration(settings.WithDefaults().DrainTimeout)
+
+	commandLib, err := nodeCommandLib(node)
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
+	nodeName, err := installer.nodeName(conn, commandLib)
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
+
+	steps, err := installer.upgradeSteps(commandLib, settings, nodeName, node.IsMaster, isFirstMaster)
+	if err != nil {
+		sendProgress(1, internal.STATUS_ERROR, "", err.Error())
+		return err
+	}
+
+	log := newTaskLog(sendProgress)
+	for i, step := range steps {
+		percent := (i + 1) * 100 / (len(steps) + 1)
+		if step.drain || step.uncordon {
+			out := newPhaseOutput(sendLog)
+			var phase string
+			if step.drain {
+				phase = "cordon and drain node " + nodeName
+				_, err = installer.drainNode(ctx, client, nodeName, timeout, out)
+			} else {
+				phase = "uncordon node " + nodeName
+				err = installer.uncordonNode(ctx, client, nodeName, out)
+			}
+			log.pushPhase(phase, out.bytes())
+			if err != nil {
+				log.send(percent, internal.STATUS_ERROR, err.Error())
+				installer.l.Error(phase+" failed", zap.Int("node", node.ID), zap.Error(err))
+				return err
+			}
+			log.send(percent, internal.STATUS_IN_PROCESS, "")
+			continue
+		}
+
+		result, err := installer.exec(conn, step.command, sendLog)
+		log.pushResult(step.command, result)
+		if err != nil && step.command.Condition != cl.Anyway {
+			log.send(percent, internal.STATUS_ERROR, err.Error())
+			installer.l.Error("exec failed", zap.ByteString("command", commandText(step.command)), zap.Int("node", node.ID), zap.Int("exitCode", int(result.ExitCode)), zap.ByteString("stderr", RedactSecrets(result.Stderr, step.command.Secrets)))
+			return err
+		}
+		if step.command.Parser != nil {
+			if err = step.command.Parser(result, nil); err != nil {
+				log.send(percent, internal.STATUS_ERROR, err.Error())
+				return err
+			}
+		}
+		log.send(percent, internal.STATUS_IN_PROCESS, "")
+	}
+
+	log.send(100, internal.STATUS_SUCCESS, "")
+	return nil
+}
@@ -0,0 +1,83 @@
+package k8s_installer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+func TestValidateUpgrade(t *testing.T) {
+	tests := []struct {
+		current     string
+		version     string
+		wantVersion string
+		wantCRIO    string
+		err         error
+	}{
+		{current: "1.29", version: "1.30", wantVersion: "1.30", wantCRIO: "1.30"},
+		{current: "1.29.3", version: "1.29.5", wantVersion: "1.29.5", wantCRIO: "1.29"},
+		{current: "1.29.3", version: "1.29.3", err: ErrUpgradeVersion},
+		{current: "1.29", version: "1.29", err: ErrUpgradeVersion},
+		{current: "1.29.3", version: "1.29", err: ErrUpgradeVersion},
+		{current: "1.29", version: "1.29.5", wantVersion: "1.29.5", wantCRIO: "1.29"},
+		{current: "1.29", version: "1.31", err: ErrUpgradeVersion},
+		{current: "1.30", version: "1.29", err: ErrUpgradeVersion},
+		{current: "1.31", version: "1.32", err: ErrUnsupportedVersion},
+		{current: "1.30", version: "latest", err: ErrInvalidVersion},
+	}
+	for _, tt := range tests {
+		current := models.ClusterSettings{KubernetesVersion: tt.current, CRIOVersion: tt.current}
+		upgraded, err := ValidateUpgrade(current, tt.version)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ValidateUpgrade(%s, %s) error = %v, want %v", tt.current, tt.version, err, tt.err)
+			continue
+		}
+		if err == nil && (upgraded.KubernetesVersion != tt.wantVersion || upgraded.CRIOVersion != tt.wantCRIO) {
+			t.Errorf("ValidateUpgrade(%s, %s) = %s/%s, want %s/%s", tt.current, tt.version, upgraded.KubernetesVersion, upgraded.CRIOVersion, tt.wantVersion, tt.wantCRIO)
+		}
+	}
+
+	offline := models.ClusterSettings{KubernetesVersion: "1.29", OfflineBundle: "/srv/bundle.tar.gz"}
+	if _, err := ValidateUpgrade(offline, "1.30"); !errors.Is(err, ErrUpgradeVersion) {
+		t.Errorf("ValidateUpgrade() of offline cluster error = %v, want %v", err, ErrUpgradeVersion)
+	}
+}
+
+func TestUpgradeStepsDrain(t *testing.T) {
+	installer := &Installer{}
+	settings := models.ClusterSettings{KubernetesVersion: "1.30", CRIOVersion: "1.30"}
+	tests := []struct {
+		name      string
+		isMaster  bool
+		drainStep int
+	}{
+		{name: "worker", drainStep: 0},
+		{name: "master", isMaster: true, drainStep: -1},
+	}
+	for _, tt := range tests {
+		steps, err := installer.upgradeSteps(&ubuntu.Ubuntu2204CommandLib{}, settings, "node-1", tt.isMaster, tt.isMaster)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drains, uncordons := 0, 0
+		for i, step := range steps {
+			if step.drain {
+				drains++
+				if tt.drainStep >= 0 && i != tt.drainStep {
+					t.Errorf("%s: drain is step %d, want %d", tt.name, i, tt.drainStep)
+				}
+			}
+			if step.uncordon {
+				uncordons++
+			}
+			if (step.drain || step.uncordon) && step.command.Command != "" {
+				t.Errorf("%s: drain step runs command %q on node", tt.name, step.command.Command)
+			}
+		}
+		if drains != 1 || uncordons != 1 || !steps[len(steps)-1].uncordon {
+			t.Errorf("%s: %d drains and %d uncordons, want one of each with uncordon last", tt.name, drains, uncordons)
+		}
+	}
+}
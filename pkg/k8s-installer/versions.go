@@ -0,0 +1,155 @@
+package k8s_installer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+const (
+	// Kubernetes and CRI-O minor versions which are published in pkgs.k8s.io repositories
+	minSupportedMinor = 28
+	maxSupportedMinor = 31
+
+	// kubelet may be up to 3 minor versions older than kube-apiserver
+	maxKubeletMinorSkew = 3
+
+	apiServerVersionTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidVersion     = errors.New("invalid version")
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	ErrVersionSkew        = errors.New("version skew policy violated")
+	ErrSkewUnchecked      = errors.New("version skew can't be checked")
+)
+
+// Version is a Kubernetes or CRI-O version. Patch is -1 when it is not specified
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses versions like "1.28", "v1.28.2" and "v1.28.2-1.1"
+func ParseVersion(version string) (Version, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(trimmed, "-+"); i >= 0 {
+		trimmed = trimmed[:i]
+	}
+
+	parts := strings.Split(trimmed, ".")
+	if len(parts) != 2 && len(parts) != 3 {
+		return Version{}, fmt.Errorf("%w %q: expected <major>.<minor>[.<patch>]", ErrInvalidVersion, version)
+	}
+
+	v := Version{Patch: -1}
+	for i, target := range []*int{&v.Major, &v.Minor, &v.Patch}[:len(parts)] {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("%w %q", ErrInvalidVersion, version)
+		}
+		*target = n
+	}
+	return v, nil
+}
+
+// MinorString returns "v<major>.<minor>" as used in pkgs.k8s.io repository paths
+func (v Version) MinorString() string {
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+// PackagePin returns apt version pattern which matches packages of this version
+func (v Version) PackagePin() string {
+	if v.Patch < 0 {
+		return fmt.Sprintf("%d.%d.*", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d.%d-*", v.Major, v.Minor, v.Patch)
+}
+
+func (v Version) String() string {
+	if v.Patch < 0 {
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// ValidateVersions checks that Kubernetes and CRI-O versions of cluster settings can be installed together, CRI-O version
+// isn't checked against Kubernetes when containerd is used
+func ValidateVersions(settings models.ClusterSettings) error {
+	settings = settings.WithDefaults()
+
+	k8sVersion, err := ParseVersion(settings.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+	crioVersion, err := ParseVersion(settings.CRIOVersion)
+	if err != nil {
+		return err
+	}
+
+	if k8sVersion.Major != 1 || k8sVersion.Minor < minSupportedMinor || k8sVersion.Minor > maxSupportedMinor {
+		return fmt.Errorf("%w: kubernetes %s, supported versions are 1.%d - 1.%d", ErrUnsupportedVersion, k8sVersion, minSupportedMinor, maxSupportedMinor)
+	}
+
+	// CRI-O minor versions follow Kubernetes minor versions and support only the same one
+	if settings.ContainerRuntime == models.ContainerRuntimeCRIO && (crioVersion.Major != k8sVersion.Major || crioVersion.Minor != k8sVersion.Minor) {
+		return fmt.Errorf("%w: cri-o %s can't be used with kubernetes %s, minor versions must match", ErrVersionSkew, crioVersion, k8sVersion)
+	}
+	return nil
+}
+
+// ValidateKubeletSkew checks that kubelet of version kubeletVersion may join control plane of version apiServerVersion
+func ValidateKubeletSkew(kubeletVersion, apiServerVersion Version) error {
+	if kubeletVersion.Major != apiServerVersion.Major {
+		return fmt.Errorf("%w: kubelet %s and kube-apiserver %s have different major versions", ErrVersionSkew, kubeletVersion, apiServerVersion)
+	}
+	if kubeletVersion.Minor > apiServerVersion.Minor {
+		return fmt.Errorf("%w: kubelet %s is newer than kube-apiserver %s", ErrVersionSkew, kubeletVersion, apiServerVersion)
+	}
+	if apiServerVersion.Minor-kubeletVersion.Minor > maxKubeletMinorSkew {
+		return fmt.Errorf("%w: kubelet %s is more than %d minor versions older than kube-apiserver %s", ErrVersionSkew, kubeletVersion, maxKubeletMinorSkew, apiServerVersion)
+	}
+	return nil
+}
+
+// validateJoinVersions checks cluster settings and version skew of the new node against running control plane.
+// Node doesn't join when control plane can't be reached with ./config, its kubelet version would be unchecked
+func (installer *Installer) validateJoinVersions(settings models.ClusterSettings) error {
+	if err := ValidateVersions(settings); err != nil {
+		return err
+	}
+
+	kubeletVersion, _ := ParseVersion(settings.WithDefaults().KubernetesVersion)
+	apiServerVersion, err := installer.apiServerVersion()
+	if err != nil {
+		return fmt.Errorf("%w: kube-apiserver version isn't available: %w", ErrSkewUnchecked, err)
+	}
+	return ValidateKubeletSkew(kubeletVersion, apiServerVersion)
+}
+
+func (installer *Installer) apiServerVersion() (Version, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", "./config")
+	if err != nil {
+		return Version{}, err
+	}
+	config.Timeout = apiServerVersionTimeout
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return Version{}, err
+	}
+
+	info, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return Version{}, err
+	}
+	return ParseVersion(info.GitVersion)
+}
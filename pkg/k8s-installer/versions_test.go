@@ -0,0 +1,82 @@
+package k8s_installer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/internal/models"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		pin     string
+		minor   string
+		invalid bool
+	}{
+		{in: "1.30", pin: "1.30.*", minor: "v1.30"},
+		{in: "v1.29.4", pin: "1.29.4-*", minor: "v1.29"},
+		{in: "v1.28.2-1.1", pin: "1.28.2-*", minor: "v1.28"},
+		{in: "1", invalid: true},
+		{in: "1.x", invalid: true},
+	}
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.in)
+		if tt.invalid {
+			if !errors.Is(err, ErrInvalidVersion) {
+				t.Errorf("ParseVersion(%q) error = %v, want ErrInvalidVersion", tt.in, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) error = %v", tt.in, err)
+		}
+		if v.PackagePin() != tt.pin || v.MinorString() != tt.minor {
+			t.Errorf("ParseVersion(%q) = %s, %s, want %s, %s", tt.in, v.PackagePin(), v.MinorString(), tt.pin, tt.minor)
+		}
+	}
+}
+
+func TestValidateVersions(t *testing.T) {
+	tests := []struct {
+		settings models.ClusterSettings
+		err      error
+	}{
+		{settings: models.ClusterSettings{}},
+		{settings: models.ClusterSettings{KubernetesVersion: "1.29.3", CRIOVersion: "1.29"}},
+		{settings: models.ClusterSettings{KubernetesVersion: "1.26", CRIOVersion: "1.26"}, err: ErrUnsupportedVersion},
+		{settings: models.ClusterSettings{KubernetesVersion: "1.30", CRIOVersion: "1.29"}, err: ErrVersionSkew},
+	}
+	for _, tt := range tests {
+		if err := ValidateVersions(tt.settings); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateVersions(%+v) error = %v, want %v", tt.settings, err, tt.err)
+		}
+	}
+}
+
+func TestValidateKubeletSkew(t *testing.T) {
+	tests := []struct {
+		kubelet, apiServer string
+		err                error
+	}{
+		{kubelet: "1.30", apiServer: "v1.30.2"},
+		{kubelet: "1.27", apiServer: "v1.30.0"},
+		{kubelet: "1.26", apiServer: "v1.30.0", err: ErrVersionSkew},
+		{kubelet: "1.31", apiServer: "v1.30.0", err: ErrVersionSkew},
+	}
+	for _, tt := range tests {
+		kubelet, _ := ParseVersion(tt.kubelet)
+		apiServer, _ := ParseVersion(tt.apiServer)
+		if err := ValidateKubeletSkew(kubelet, apiServer); !errors.Is(err, tt.err) {
+			t.Errorf("ValidateKubeletSkew(%s, %s) error = %v, want %v", tt.kubelet, tt.apiServer, err, tt.err)
+		}
+	}
+}
+
+func TestValidateJoinVersionsUnreachable(t *testing.T) {
+	// tests run without ./config, so control plane can't be reached
+	installer := &Installer{}
+	if err := installer.validateJoinVersions(models.ClusterSettings{}); !errors.Is(err, ErrSkewUnchecked) {
+		t.Errorf("validateJoinVersions() error = %v, want %v", err, ErrSkewUnchecked)
+	}
+}
@@ -0,0 +1,288 @@
+package manifests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
+)
+
+// FieldManager owns fields of applied objects, fields set by other managers are left to them
+const FieldManager = "paas-clientside"
+
+const defaultNamespace = "default"
+
+// Applier applies objects by server-side apply
+type Applier struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+}
+
+func NewApplier(config *rest.Config) (*Applier, error) {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewApplierWithClient(client, restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))), nil
+}
+
+// NewApplierWithClient creates Applier of prepared clients, the mapper resolves resources of object kinds
+func NewApplierWithClient(client dynamic.Interface, mapper meta.RESTMapper) *Applier {
+	return &Applier{client: client, mapper: mapper}
+}
+
+// Apply applies objects in their order. Conflicts are forced, so fields set by kubectl apply before
+// are taken over by FieldManager
+func (a *Applier) Apply(ctx context.Context, objects ...*unstructured.Unstructured) error {
+	for _, object := range objects {
+		if _, err := a.apply(ctx, object, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Diff returns line diff of the live object and the object as it would be after Apply, it is empty when
+// nothing would change. Server applies the object in dry-run mode, so defaults and other managers' fields are kept
+func (a *Applier) Diff(ctx context.Context, object *unstructured.Unstructured) (string, error) {
+	resource, err := a.resource(object)
+	if err != nil {
+		return "", err
+	}
+	live, err := resource.Get(ctx, object.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		return "", err
+	}
+	applied, err := a.apply(ctx, object, true)
+	if err != nil {
+		return "", err
+	}
+
+	live, applied = redactSecrets(live, applied)
+	from, err := diffYAML(live)
+	if err != nil {
+		return "", err
+	}
+	to, err := diffYAML(applied)
+	if err != nil {
+		return "", err
+	}
+	return diffLines(from, to), nil
+}
+
+func (a *Applier) apply(ctx context.Context, object *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	resource, err := a.resource(object)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	force := true
+	options := metav1.PatchOptions{FieldManager: FieldManager, Force: &force}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	applied, err := resource.Patch(ctx, object.GetName(), types.ApplyPatchType, data, options)
+	if err != nil {
+		return nil, fmt.Errorf("apply %s %s: %w", object.GetKind(), object.GetName(), err)
+	}
+	return applied, nil
+}
+
+// resource returns client of the object resource, namespaced objects without namespace go to default one
+func (a *Applier) resource(object *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return a.client.Resource(mapping.Resource), nil
+	}
+	namespace := object.GetNamespace()
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	return a.client.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// diffYAML returns YAML of the object without fields which change on every write
+func diffYAML(object *unstructured.Unstructured) (string, error) {
+	if object == nil {
+		return "", nil
+	}
+	object = object.DeepCopy()
+	object.SetManagedFields(nil)
+	object.SetResourceVersion("")
+	object.SetGeneration(0)
+	data, err := yaml.Marshal(object.Object)
+	return string(data), err
+}
+
+// Placeholders of Secret values in diffs, values which differ get different ones
+const (
+	redactedSecretValue  = "<redacted>"
+	redactedSecretBefore = "<redacted before>"
+	redactedSecretAfter  = "<redacted after>"
+)
+
+// redactSecrets returns copies of Secret objects with values of data and stringData replaced by placeholders,
+// so diff shows which keys are changed without their content. Other objects are returned as they are
+func redactSecrets(from, to *unstructured.Unstructured) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	if !isSecret(from) && !isSecret(to) {
+		return from, to
+	}
+	if from != nil {
+		from = from.DeepCopy()
+	}
+	if to != nil {
+		to = to.DeepCopy()
+	}
+	for _, field := range []string{"data", "stringData"} {
+		fromValues := secretValues(from, field)
+		toValues := secretValues(to, field)
+		for key, value := range fromValues {
+			placeholder := redactedSecretBefore
+			if toValue, ok := toValues[key]; ok && toValue == value {
+				placeholder = redactedSecretValue
+			}
+			fromValues[key] = placeholder
+		}
+		// values of from are redacted already, unchanged ones have common placeholder
+		for key := range toValues {
+			placeholder := redactedSecretAfter
+			if fromValues[key] == redactedSecretValue {
+				placeholder = redactedSecretValue
+			}
+			toValues[key] = placeholder
+		}
+	}
+	return from, to
+}
+
+func isSecret(object *unstructured.Unstructured) bool {
+	return object != nil && object.GetKind() == "Secret" && object.GroupVersionKind().Group == ""
+}
+
+// secretValues returns field of the Secret as map which is changed in place, it is nil for other objects
+func secretValues(object *unstructured.Unstructured, field string) map[string]interface{} {
+	if !isSecret(object) {
+		return nil
+	}
+	values, _ := object.Object[field].(map[string]interface{})
+	return values
+}
+
+// diffContext is number of kept lines printed around changed ones
+const diffContext = 3
+
+// diffLines returns changed lines of from and to prefixed with "-" and "+", kept lines around them are prefixed
+// with " " and hunks are separated by "@@". It is empty when texts are equal
+func diffLines(from, to string) string {
+	if from == to {
+		return ""
+	}
+	a, b := splitLines(from), splitLines(to)
+
+	// common prefix and suffix are kept, longest common subsequence is searched only between them
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ca, cb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] is length of longest common subsequence of ca[i:] and cb[j:]
+	lcs := make([][]int, len(ca)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(cb)+1)
+	}
+	for i := len(ca) - 1; i >= 0; i-- {
+		for j := len(cb) - 1; j >= 0; j-- {
+			if ca[i] == cb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		lines = append(lines, " "+line)
+	}
+	i, j := 0, 0
+	for i < len(ca) || j < len(cb) {
+		switch {
+		case i < len(ca) && j < len(cb) && ca[i] == cb[j]:
+			lines = append(lines, " "+ca[i])
+			i++
+			j++
+		case j == len(cb) || (i < len(ca) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+ca[i])
+			i++
+		default:
+			lines = append(lines, "+"+cb[j])
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, " "+line)
+	}
+
+	var sb strings.Builder
+	last := -1
+	for k, line := range lines {
+		if !nearChange(lines, k) {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			sb.WriteString("@@\n")
+		}
+		sb.WriteString(line + "\n")
+		last = k
+	}
+	return sb.String()
+}
+
+// nearChange reports whether line k is changed or within diffContext lines of changed one
+func nearChange(lines []string, k int) bool {
+	for l := k - diffContext; l <= k+diffContext; l++ {
+		if l >= 0 && l < len(lines) && lines[l][0] != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
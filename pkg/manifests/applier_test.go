@@ -0,0 +1,71 @@
+package manifests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestApply(t *testing.T) {
+	storageClass := schema.GroupVersionKind{Group: "storage.k8s.io", Version: "v1", Kind: "StorageClass"}
+	pool := schema.GroupVersionKind{Group: "metallb.io", Version: "v1beta1", Kind: "IPAddressPool"}
+	advertisement := schema.GroupVersionKind{Group: "metallb.io", Version: "v1beta1", Kind: "L2Advertisement"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(storageClass, meta.RESTScopeRoot)
+	mapper.Add(pool, meta.RESTScopeNamespace)
+	mapper.Add(advertisement, meta.RESTScopeNamespace)
+
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	var patches []k8stesting.PatchActionImpl
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchActionImpl)
+		patches = append(patches, patch)
+		object := &unstructured.Unstructured{}
+		return true, object, json.Unmarshal(patch.Patch, &object.Object)
+	})
+
+	objects, err := Render(StorageClass, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metallb, err := Render(MetallbConf, MetallbValues{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metallb[1].SetNamespace("")
+
+	applier := NewApplierWithClient(client, mapper)
+	if err = applier.Apply(context.Background(), append(objects, metallb...)...); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := []struct {
+		resource  string
+		namespace string
+		name      string
+	}{
+		{resource: "storageclasses", namespace: "", name: "local-storage"},
+		{resource: "ipaddresspools", namespace: "default", name: "default"},
+		{resource: "l2advertisements", namespace: "default", name: "default"},
+	}
+	if len(patches) != len(want) {
+		t.Fatalf("Apply() sent %d patches, want %d", len(patches), len(want))
+	}
+	for i, w := range want {
+		patch := patches[i]
+		if patch.GetResource().Resource != w.resource || patch.GetNamespace() != w.namespace || patch.GetName() != w.name {
+			t.Errorf("patch %d is of %s %s/%s, want %s %s/%s", i, patch.GetResource().Resource, patch.GetNamespace(), patch.GetName(), w.resource, w.namespace, w.name)
+		}
+		if patch.GetPatchType() != types.ApplyPatchType {
+			t.Errorf("patch %d type = %s, want %s", i, patch.GetPatchType(), types.ApplyPatchType)
+		}
+	}
+}
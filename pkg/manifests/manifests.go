@@ -0,0 +1,133 @@
+package manifests
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Name is file name of embedded manifest template
+type Name string
+
+const (
+	StorageClass            Name = "storage-class.yaml"
+	MetallbConf             Name = "metallb-conf.yaml"
+	GrafanaPV               Name = "grafana-pv.yaml"
+	GrafanaIngress          Name = "grafana-ingress.yaml"
+	GrafanaDatasourceSecret Name = "grafana-datasource-secret.yaml"
+	GrafanaDashboard        Name = "grafana-dashboard-configmap.yaml"
+)
+
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+//go:embed templates/*
+var templates embed.FS
+
+// MetallbValues are values of MetallbConf, the pool consists of the single address of first master
+type MetallbValues struct {
+	IP string
+}
+
+// GrafanaPVValues are values of GrafanaPV, the volume is bound to node with Hostname
+type GrafanaPVValues struct {
+	Hostname string
+	Path     string
+}
+
+// GrafanaIngressValues are values of GrafanaIngress
+type GrafanaIngressValues struct {
+	Host string
+}
+
+// Render executes template of manifest with values and decodes its documents. Values are put into manifests
+// by quote function only, so they can't change structure of the document. Every object is validated
+func Render(name Name, values interface{}) ([]*unstructured.Unstructured, error) {
+	data, err := RenderYAML(name, values)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		object := map[string]interface{}{}
+		if err = decoder.Decode(&object); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidManifest, name, err)
+		}
+		if len(object) == 0 {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: object}
+		if err = validate(u); err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidManifest, name, err)
+		}
+		objects = append(objects, u)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("%w %s: no objects", ErrInvalidManifest, name)
+	}
+	return objects, nil
+}
+
+// RenderYAML executes template of manifest with values, missing values are errors
+func RenderYAML(name Name, values interface{}) ([]byte, error) {
+	tmpl, err := template.New(string(name)).Funcs(funcs).Option("missingkey=error").ParseFS(templates, path.Join("templates", string(name)))
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidManifest, name, err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, values); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidManifest, name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+var funcs = template.FuncMap{
+	// quote returns value as double-quoted YAML scalar, JSON strings are valid YAML
+	"quote": func(value string) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	// file returns embedded file which isn't template, like dashboard JSON
+	"file": func(name string) (string, error) {
+		data, err := templates.ReadFile(path.Join("templates", name))
+		return string(data), err
+	},
+	"b64enc": func(value string) string {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	},
+	"indent": func(spaces int, value string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(strings.TrimSuffix(value, "\n"), "\n", "\n"+pad)
+	},
+}
+
+// validate checks fields which are needed to apply the object
+func validate(u *unstructured.Unstructured) error {
+	if u.GetAPIVersion() == "" || u.GetKind() == "" {
+		return fmt.Errorf("object %q has no apiVersion or kind", u.GetName())
+	}
+	if errs := validation.IsDNS1123Subdomain(u.GetName()); len(errs) > 0 {
+		return fmt.Errorf("%s name %q: %s", u.GetKind(), u.GetName(), strings.Join(errs, ", "))
+	}
+	if namespace := u.GetNamespace(); namespace != "" {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("%s %s namespace %q: %s", u.GetKind(), u.GetName(), namespace, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
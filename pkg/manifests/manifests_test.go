@@ -0,0 +1,186 @@
+package manifests
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   Name
+		values interface{}
+		kinds  []string
+	}{
+		{name: StorageClass, kinds: []string{"StorageClass"}},
+		{name: MetallbConf, values: MetallbValues{IP: "10.0.0.1"}, kinds: []string{"IPAddressPool", "L2Advertisement"}},
+		{name: GrafanaPV, values: GrafanaPVValues{Hostname: "node-1", Path: "/data/grafana"}, kinds: []string{"PersistentVolume"}},
+		{name: GrafanaIngress, values: GrafanaIngressValues{Host: "grafana.example.com"}, kinds: []string{"Ingress"}},
+		{name: GrafanaDatasourceSecret, kinds: []string{"Secret"}},
+		{name: GrafanaDashboard, kinds: []string{"ConfigMap"}},
+	}
+	for _, tt := range tests {
+		objects, err := Render(tt.name, tt.values)
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", tt.name, err)
+		}
+		if len(objects) != len(tt.kinds) {
+			t.Fatalf("Render(%s) returned %d objects, want %d", tt.name, len(objects), len(tt.kinds))
+		}
+		for i, kind := range tt.kinds {
+			if objects[i].GetKind() != kind {
+				t.Errorf("Render(%s) object %d kind = %s, want %s", tt.name, i, objects[i].GetKind(), kind)
+			}
+		}
+	}
+}
+
+func TestRenderValues(t *testing.T) {
+	objects, err := Render(MetallbConf, MetallbValues{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, _, _ := unstructured.NestedStringSlice(objects[0].Object, "spec", "addresses")
+	if len(addresses) != 1 || addresses[0] != "10.0.0.1/32" {
+		t.Errorf("metallb addresses = %v, want [10.0.0.1/32]", addresses)
+	}
+
+	// values can't add keys or documents to the manifest
+	hostname := "node\n---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: injected"
+	objects, err = Render(GrafanaPV, GrafanaPVValues{Hostname: hostname, Path: "/data/grafana"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("grafana pv rendered %d objects, want 1", len(objects))
+	}
+	data, _ := json.Marshal(objects[0].Object)
+	if !strings.Contains(string(data), `"values":[`+mustJSON(hostname)+`]`) {
+		t.Errorf("hostname isn't kept as single value:\n%s", data)
+	}
+
+	objects, err = Render(GrafanaDatasourceSecret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _, _ := unstructured.NestedString(objects[0].Object, "data", "datasource-secret.yaml")
+	datasource, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !strings.Contains(string(datasource), "url: http://prometheus-kube-prometheus-prometheus:9090") {
+		t.Errorf("datasource secret = %q, %v", datasource, err)
+	}
+
+	objects, err = Render(GrafanaDashboard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboard, _, _ := unstructured.NestedString(objects[0].Object, "data", "def_dashboard.json")
+	var parsed map[string]interface{}
+	if err = json.Unmarshal([]byte(dashboard), &parsed); err != nil {
+		t.Errorf("dashboard isn't JSON: %v", err)
+	}
+	// dashboard variables were expanded by the shell when the manifest was applied by kubectl over SSH
+	if !strings.Contains(dashboard, "$Node") {
+		t.Errorf("dashboard variables are lost")
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   Name
+		values interface{}
+	}{
+		{name: "missing values", file: MetallbConf, values: map[string]string{}},
+		{name: "missing file", file: "absent.yaml"},
+	}
+	for _, tt := range tests {
+		if _, err := Render(tt.file, tt.values); !errors.Is(err, ErrInvalidManifest) {
+			t.Errorf("%s: Render() error = %v, want ErrInvalidManifest", tt.name, err)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{name: "equal", from: "a: 1\n", to: "a: 1\n", want: ""},
+		{name: "created", from: "", to: "a: 1\nb: 2\n", want: "+a: 1\n+b: 2\n"},
+		{name: "changed", from: "a: 1\nb: 2\nc: 3\n", to: "a: 1\nb: 3\nc: 3\n", want: " a: 1\n-b: 2\n+b: 3\n c: 3\n"},
+		{
+			name: "hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			to:   "0\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n13\n",
+			want: "-1\n+0\n 2\n 3\n 4\n@@\n 9\n 10\n 11\n-12\n+13\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := diffLines(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: diffLines() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func mustJSON(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func TestRedactSecrets(t *testing.T) {
+	secret := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "grafana-admin"},
+			"data":       data,
+		}}
+	}
+	tests := []struct {
+		name string
+		from *unstructured.Unstructured
+		to   *unstructured.Unstructured
+		want string
+	}{
+		{
+			name: "created",
+			to:   secret(map[string]interface{}{"password": "czNjcjN0"}),
+			want: "+apiVersion: v1\n+data:\n+  password: <redacted after>\n+kind: Secret\n+metadata:\n+  name: grafana-admin\n",
+		},
+		{
+			name: "changed",
+			from: secret(map[string]interface{}{"password": "czNjcjN0", "user": "YWRtaW4="}),
+			to:   secret(map[string]interface{}{"password": "bjN3", "user": "YWRtaW4="}),
+			want: " apiVersion: v1\n data:\n-  password: <redacted before>\n+  password: <redacted after>\n   user: <redacted>\n kind: Secret\n metadata:\n",
+		},
+		{
+			name: "unchanged",
+			from: secret(map[string]interface{}{"password": "czNjcjN0"}),
+			to:   secret(map[string]interface{}{"password": "czNjcjN0"}),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		from, to := redactSecrets(tt.from, tt.to)
+		fromYAML, err := diffYAML(from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toYAML, err := diffYAML(to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := diffLines(fromYAML, toYAML); diff != tt.want {
+			t.Errorf("%s: diff =\n%s\nwant\n%s", tt.name, diff, tt.want)
+		}
+		if tt.from != nil && tt.from.Object["data"].(map[string]interface{})["password"] != "czNjcjN0" {
+			t.Errorf("%s: original object is changed", tt.name)
+		}
+	}
+}
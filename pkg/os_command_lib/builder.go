@@ -0,0 +1,170 @@
+package os_command_lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Quote returns s as single shell word. Words of safe characters are left as they are, others are put
+// in single quotes, so the shell doesn't expand variables, globs and command substitutions in them
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if strings.IndexFunc(s, func(r rune) bool { return !isSafeShellRune(r) }) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Strict returns command running script by bash which stops at the first failed line or failed stage of pipeline,
+// so exit status of the command is the one of its failed step rather than of the last line
+func Strict(script string) string {
+	return "bash -c " + Quote("set -eo pipefail\n"+script)
+}
+
+func isSafeShellRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("_@%+=:,./-", r)
+}
+
+// Cmd builds simple shell command. Arguments and environment values are quoted, trusted shell fragments like
+// redirections and command substitutions are added by Raw only
+type Cmd struct {
+	sudo    bool
+	env     []string
+	words   []string
+	heredoc *string
+}
+
+// NewCmd creates command running program with args
+func NewCmd(program string, args ...string) *Cmd {
+	c := &Cmd{words: []string{Quote(program)}}
+	return c.Arg(args...)
+}
+
+// Arg adds quoted arguments
+func (c *Cmd) Arg(args ...string) *Cmd {
+	for _, arg := range args {
+		c.words = append(c.words, Quote(arg))
+	}
+	return c
+}
+
+// Flag adds "--name=value" argument, value is quoted
+func (c *Cmd) Flag(name, value string) *Cmd {
+	c.words = append(c.words, Quote("--"+name+"="+value))
+	return c
+}
+
+// Raw adds fragment as it is, it must not contain values received from users or nodes
+func (c *Cmd) Raw(fragment string) *Cmd {
+	c.words = append(c.words, fragment)
+	return c
+}
+
+// Env sets environment variable for the command only. Name is written in code, so invalid name panics
+func (c *Cmd) Env(name, value string) *Cmd {
+	if !envNameRe.MatchString(name) {
+		panic(fmt.Sprintf("invalid environment variable name %q", name))
+	}
+	c.env = append(c.env, name+"="+Quote(value))
+	return c
+}
+
+// Sudo runs the command as root, environment variables are passed by env because sudo resets them
+func (c *Cmd) Sudo() *Cmd {
+	c.sudo = true
+	return c
+}
+
+// Heredoc passes content to stdin of the command. Delimiter is quoted, so content isn't expanded, and it is chosen
+// not to be equal to any line of content
+func (c *Cmd) Heredoc(content string) *Cmd {
+	c.heredoc = &content
+	return c
+}
+
+// Pipe passes stdout of the command to next ones
+func (c *Cmd) Pipe(next ...*Cmd) Pipeline {
+	return append(Pipeline{c}, next...)
+}
+
+func (c *Cmd) String() string {
+	return Pipeline{c}.String()
+}
+
+// Command returns built command for CommandAndParser
+func (c *Cmd) Command() Command {
+	return Command(c.String())
+}
+
+func (c *Cmd) line() (string, string) {
+	words := make([]string, 0, len(c.env)+len(c.words)+3)
+	if c.sudo {
+		words = append(words, "sudo")
+		if len(c.env) > 0 {
+			words = append(words, "env")
+		}
+	}
+	words = append(words, c.env...)
+	words = append(words, c.words...)
+	if c.heredoc == nil {
+		return strings.Join(words, " "), ""
+	}
+
+	content := *c.heredoc
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	delimiter := heredocDelimiter(content)
+	words = append(words, "<<'"+delimiter+"'")
+	return strings.Join(words, " "), content + delimiter
+}
+
+// heredocDelimiter returns EOF, or EOF with number when content has such line
+func heredocDelimiter(content string) string {
+	lines := make(map[string]struct{})
+	for _, line := range strings.Split(content, "\n") {
+		lines[line] = struct{}{}
+	}
+	delimiter := "EOF"
+	for i := 1; ; i++ {
+		if _, ok := lines[delimiter]; !ok {
+			return delimiter
+		}
+		delimiter = fmt.Sprintf("EOF_%d", i)
+	}
+}
+
+// Pipeline is commands connected by pipes, heredocs of the commands follow the pipeline line in their order
+type Pipeline []*Cmd
+
+// Pipe passes stdout of the last command to next ones
+func (p Pipeline) Pipe(next ...*Cmd) Pipeline {
+	return append(p[:len(p):len(p)], next...)
+}
+
+func (p Pipeline) String() string {
+	lines := make([]string, 0, len(p))
+	var bodies []string
+	for _, c := range p {
+		line, body := c.line()
+		lines = append(lines, line)
+		if body != "" {
+			bodies = append(bodies, body)
+		}
+	}
+	return strings.Join(append([]string{strings.Join(lines, " | ")}, bodies...), "\n")
+}
+
+// Command returns built pipeline for CommandAndParser
+func (p Pipeline) Command() Command {
+	return Command(p.String())
+}
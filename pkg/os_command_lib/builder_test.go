@@ -0,0 +1,173 @@
+package os_command_lib
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+var injectionValues = []string{
+	"plain",
+	"",
+	"with space",
+	"$(touch /tmp/pwned)",
+	"`id`",
+	"${HOME}",
+	"it's",
+	"'; rm -rf / #",
+	"a\nb",
+	"*",
+	"--flag=\"quoted\"",
+	"back\\slash",
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "kubeadm", want: "kubeadm"},
+		{value: "--config=/etc/kubeadm/config.yaml", want: "--config=/etc/kubeadm/config.yaml"},
+		{value: "", want: "''"},
+		{value: "1.30.*", want: "'1.30.*'"},
+		{value: "$(id)", want: "'$(id)'"},
+		{value: "it's", want: `'it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.value); got != tt.want {
+			t.Errorf("Quote(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestQuoteShell checks by the shell that every quoted value reaches the program as single unchanged argument
+func TestQuoteShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh isn't found")
+	}
+	for _, value := range injectionValues {
+		command := NewCmd("printf", "%s", value).String()
+		out, err := exec.Command(sh, "-c", command).Output()
+		if err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		if string(out) != value {
+			t.Errorf("%s printed %q, want %q", command, out, value)
+		}
+	}
+}
+
+func TestCmd(t *testing.T) {
+	tests := []struct {
+		name    string
+		command interface{ String() string }
+		want    string
+	}{
+		{
+			name:    "args",
+			command: NewCmd("kubectl", "drain", "node 1; reboot").Flag("timeout", "5m"),
+			want:    "kubectl drain 'node 1; reboot' --timeout=5m",
+		},
+		{
+			name:    "sudo",
+			command: NewCmd("rm", "-rf", "/etc/cni/net.d", "$HOME").Sudo(),
+			want:    "sudo rm -rf /etc/cni/net.d '$HOME'",
+		},
+		{
+			name:    "env",
+			command: NewCmd("helm", "version").Env("KUBECONFIG", "/tmp/admin conf"),
+			want:    "KUBECONFIG='/tmp/admin conf' helm version",
+		},
+		{
+			name:    "sudo env",
+			command: NewCmd("kubeadm", "init").Env("KUBECONFIG", "/etc/kubernetes/admin.conf").Sudo(),
+			want:    "sudo env KUBECONFIG=/etc/kubernetes/admin.conf kubeadm init",
+		},
+		{
+			name:    "raw",
+			command: NewCmd("kubectl", "exec").Raw("$(kubectl get pods -o name)").Arg("grafana-cli", "p@ss word"),
+			want:    "kubectl exec $(kubectl get pods -o name) grafana-cli 'p@ss word'",
+		},
+		{
+			name:    "pipe",
+			command: NewCmd("iptables-save").Sudo().Pipe(NewCmd("grep", "-iv", "cali|x"), NewCmd("iptables-restore").Sudo()),
+			want:    "sudo iptables-save | grep -iv 'cali|x' | sudo iptables-restore",
+		},
+		{
+			name:    "heredoc",
+			command: NewCmd("install", "-m", "0644", "/dev/stdin", "/etc/a b").Sudo().Heredoc("key: $VALUE"),
+			want:    "sudo install -m 0644 /dev/stdin '/etc/a b' <<'EOF'\nkey: $VALUE\nEOF",
+		},
+		{
+			name:    "heredoc with delimiter in content",
+			command: NewCmd("cat").Heredoc("EOF\nEOF_1\nrm -rf /\n"),
+			want:    "cat <<'EOF_2'\nEOF\nEOF_1\nrm -rf /\nEOF_2",
+		},
+		{
+			name:    "heredoc in pipe",
+			command: NewCmd("cat").Heredoc("a").Pipe(NewCmd("kubectl", "apply", "-f", "-")),
+			want:    "cat <<'EOF' | kubectl apply -f -\na\nEOF",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.command.String(); got != tt.want {
+			t.Errorf("%s:\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHeredocShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh isn't found")
+	}
+	content := strings.Join(append(injectionValues, "EOF", "EOF_1"), "\n") + "\n"
+	command := NewCmd("cat").Heredoc(content).Pipe(NewCmd("cat")).String()
+	out, err := exec.Command(sh, "-c", command).Output()
+	if err != nil {
+		t.Fatalf("%s: %v", command, err)
+	}
+	if string(out) != content {
+		t.Errorf("heredoc printed %q, want %q", out, content)
+	}
+}
+
+func TestCommandAndParserWith(t *testing.T) {
+	c := CommandAndParser{Command: "kubeadm init", Condition: Required}
+	if got, want := c.WithArgs("--upload-certs", "a b").Command, Command("kubeadm init --upload-certs 'a b'"); got != want {
+		t.Errorf("WithArgs() = %s, want %s", got, want)
+	}
+	if got, want := c.WithEnv("KUBECONFIG", "$(id)").Command, Command("export KUBECONFIG='$(id)'\nkubeadm init"); got != want {
+		t.Errorf("WithEnv() = %s, want %s", got, want)
+	}
+}
+
+func TestSucceeded(t *testing.T) {
+	stop := CommandAndParser{Command: "sudo systemctl stop kubelet", SuccessCodes: []Code{UnitNotLoaded}}
+	tests := []struct {
+		command CommandAndParser
+		code    Code
+		want    bool
+	}{
+		{command: stop, code: Success, want: true},
+		{command: stop, code: UnitNotLoaded, want: true},
+		{command: stop, code: 1, want: false},
+		{command: CommandAndParser{Command: "true"}.Pipe(stop), code: UnitNotLoaded, want: true},
+		{command: stop.Pipe(CommandAndParser{Command: "cat"}), code: UnitNotLoaded, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.command.Succeeded(tt.code); got != tt.want {
+			t.Errorf("%q Succeeded(%d) = %t, want %t", tt.command.Command, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestEnvNamePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Env() with invalid name doesn't panic")
+		}
+	}()
+	NewCmd("true").Env("A=B; id", "x")
+}
@@ -0,0 +1,59 @@
+// Package cltest has helpers for tests of command libraries and of commands run on nodes
+package cltest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// AssertContains reports error of the test named name when text misses any of want or contains any of notWant
+func AssertContains(t testing.TB, name, text string, want, notWant []string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(text, w) {
+			t.Errorf("%s: %q isn't found in:\n%s", name, w, text)
+		}
+	}
+	for _, w := range notWant {
+		if strings.Contains(text, w) {
+			t.Errorf("%s: %q is found in:\n%s", name, w, text)
+		}
+	}
+}
+
+// ExitCode runs command by sh with PATH of stub programs only and returns its exit status. Every stub exits with
+// its code of stubs, sudo runs its arguments. Test is skipped when sh isn't found
+func ExitCode(t testing.TB, command string, stubs map[string]int) int {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh isn't found")
+	}
+	dir := t.TempDir()
+	scripts := map[string]string{"sudo": "#!/bin/sh\nexec \"$@\"\n"}
+	for program, code := range stubs {
+		scripts[program] = fmt.Sprintf("#!/bin/sh\nexit %d\n", code)
+	}
+	for program, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, program), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(sh, "-c", command)
+	cmd.Env = []string{"PATH=" + dir}
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("%s: %v", command, err)
+	}
+	return 0
+}
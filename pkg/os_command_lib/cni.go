@@ -0,0 +1,23 @@
+package os_command_lib
+
+import "fmt"
+
+// FlannelManifestURL returns URL of Flannel manifest of release version like "v0.25.6"
+func FlannelManifestURL(version string) string {
+	return fmt.Sprintf("https://github.com/flannel-io/flannel/releases/download/%s/kube-flannel.yml", version)
+}
+
+// CalicoManifestURL returns URL of Calico manifest of release version like "v3.28.1"
+func CalicoManifestURL(version string) string {
+	return fmt.Sprintf("https://raw.githubusercontent.com/projectcalico/calico/%s/manifests/calico.yaml", version)
+}
+
+// FlannelPodCIDR returns sed replacing pod network of Flannel manifest by podCIDR
+func FlannelPodCIDR(podCIDR string) *Cmd {
+	return NewCmd("sed", "s#10.244.0.0/16#"+podCIDR+"#")
+}
+
+// CalicoPodCIDR returns sed uncommenting default IP pool of Calico manifest and setting it to podCIDR
+func CalicoPodCIDR(podCIDR string) *Cmd {
+	return NewCmd("sed", "-e", "s|# - name: CALICO_IPV4POOL_CIDR|- name: CALICO_IPV4POOL_CIDR|", "-e", fmt.Sprintf(`s|#   value: "192.168.0.0/16"|  value: "%s"|`, podCIDR))
+}
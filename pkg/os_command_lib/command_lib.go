@@ -1,23 +1,69 @@
 package os_command_lib
 
 import (
+	"fmt"
+	"os"
 	"strings"
+	"time"
 )
 
-type Code string
+// Code is exit status of command
+type Code int
 
 const (
-	ResourceAlreadyBusy Code = "16"
+	Success Code = 0
+	// UnitNotLoaded is returned by systemctl for unit which isn't installed
+	UnitNotLoaded Code = 5
+	// NoExitCode is set to Result of command which didn't exit, e.g. when connection failed
+	NoExitCode Code = -1
 )
 
+// Result is result of command run on node
+type Result struct {
+	ExitCode Code
+	Stdout   []byte
+	Stderr   []byte
+	Duration time.Duration
+}
+
 type Command string
 
-type Parser func(output []byte, extraData interface{}) error
+// Parser checks result of successful command, command with Anyway condition is parsed even when it failed
+type Parser func(result Result, extraData interface{}) error
 
 type CommandAndParser struct {
 	Command   Command
 	Parser    Parser
 	Condition Condition
+	// SuccessCodes are non-zero exit codes which mean success too, e.g. that unit to stop isn't installed.
+	// Other non-zero codes fail the command, failed command with Required condition stops the task
+	SuccessCodes []Code
+	// File is uploaded to the node before Command runs, Command may be empty then
+	File *File
+	// Secrets are arguments of Command which are redacted when it is logged
+	Secrets []string
+}
+
+// File is file uploaded to node over ssh connection, its content doesn't pass through the shell
+type File struct {
+	Path    string
+	Content []byte
+	Mode    os.FileMode
+	// Owner is "user" or "user:group", file is owned by root when it is empty
+	Owner string
+}
+
+// Succeeded reports whether command exited with zero or one of its success codes
+func (c CommandAndParser) Succeeded(code Code) bool {
+	if code == Success {
+		return true
+	}
+	for _, successCode := range c.SuccessCodes {
+		if code == successCode {
+			return true
+		}
+	}
+	return false
 }
 
 type Condition uint8
@@ -28,30 +74,156 @@ const (
 	Anyway
 )
 
+func (c Condition) String() string {
+	switch c {
+	case Sufficient:
+		return "sufficient"
+	case Required:
+		return "required"
+	case Anyway:
+		return "anyway"
+	}
+	return "unknown"
+}
+
+// WithArgs appends quoted args to the last line of the command
 func (c CommandAndParser) WithArgs(args ...string) CommandAndParser {
-	c.Command = c.Command + " " + Command(strings.Join(args, " "))
+	for _, arg := range args {
+		c.Command += Command(" " + Quote(arg))
+	}
 	return c
 }
 
+// String returns the command, uploaded file is shown before it by path, mode and content
 func (c CommandAndParser) String() string {
-	return string(c.Command)
+	if c.File == nil {
+		return string(c.Command)
+	}
+	return fmt.Sprintf("upload %s mode %04o\n%s\n%s", c.File.Path, c.File.Mode.Perm(), c.File.Content, c.Command)
 }
 
-func (c CommandAndParser) WithEnv(env, val, sep string) CommandAndParser {
-	c.Command = Command(env + " = " + val + sep + c.String())
+// Redacted returns the command with its secrets, quoted or not, replaced by placeholder
+func (c CommandAndParser) Redacted(placeholder string) Command {
+	command := string(c.Command)
+	for _, secret := range c.Secrets {
+		if secret == "" {
+			continue
+		}
+		command = strings.ReplaceAll(command, Quote(secret), placeholder)
+		command = strings.ReplaceAll(command, secret, placeholder)
+	}
+	return Command(command)
+}
+
+// WithEnv exports environment variable for every line of the command, value is quoted.
+// Name is written in code, so invalid name panics
+func (c CommandAndParser) WithEnv(name, value string) CommandAndParser {
+	if !envNameRe.MatchString(name) {
+		panic(fmt.Sprintf("invalid environment variable name %q", name))
+	}
+	c.Command = Command("export " + name + "=" + Quote(value) + "\n" + string(c.Command))
 	return c
 }
 
 func (c CommandAndParser) Pipe(cmds ...CommandAndParser) CommandAndParser {
 
 	strCmds := make([]string, 0, 1+len(cmds))
-	strCmds = append(strCmds, c.String())
+	strCmds = append(strCmds, string(c.Command))
 	for i := range cmds {
-		strCmds = append(strCmds, cmds[i].String())
+		strCmds = append(strCmds, string(cmds[i].Command))
 	}
 
 	c.Command = Command(strings.Join(strCmds, " | "))
 	c.Parser = cmds[len(cmds)-1].Parser
 	c.Condition = cmds[len(cmds)-1].Condition
+	c.SuccessCodes = cmds[len(cmds)-1].SuccessCodes
 	return c
 }
+
+// CommandLib builds commands of every operation done on nodes. Implementations differ by distro of the node,
+// kubectl and kubeadm commands are the same for all of them
+type CommandLib interface {
+	// Packages and container runtime
+	SudoUpdate() CommandAndParser
+	SudoFullUpgrade() CommandAndParser
+	AddCRIORepos(minorVersion string) CommandAndParser
+	ImportGPGKey(minorVersion string) CommandAndParser
+	InstallCRIO(versionPin string) CommandAndParser
+	SetCRIOCgroupManager(driver string) CommandAndParser
+	StartCRIO() CommandAndParser
+	RestartCRIO() CommandAndParser
+	StopCRIO() CommandAndParser
+	InstallContainerd() CommandAndParser
+	SetContainerdCgroupDriver(driver string) CommandAndParser
+	StartContainerd() CommandAndParser
+	RestartContainerd() CommandAndParser
+	StopContainerd() CommandAndParser
+	// CgroupDrivers returns cgroup drivers which kubelet and container runtime may use on the distro
+	CgroupDrivers() []string
+	DisableSWAP() CommandAndParser
+	InstallUtils() CommandAndParser
+	SetModprobe() CommandAndParser
+	SetIpForward() CommandAndParser
+	// VerifyNodeSettings fails when swap, kernel modules or sysctl settings aren't applied or won't survive reboot
+	VerifyNodeSettings() CommandAndParser
+	DownloadK8SSigningKey(minorVersion string) CommandAndParser
+	AddK8SRepo(minorVersion string) CommandAndParser
+	InstallKubeadm(versionPin string) CommandAndParser
+	UpgradeKubeadm(versionPin string) CommandAndParser
+	UpgradeKubelet(versionPin string) CommandAndParser
+	StopKubelet() CommandAndParser
+	// DownloadPackages downloads packages of the cluster to dir of the machine building offline bundle,
+	// InstallLocalPackages installs them from dir of the node
+	DownloadPackages(dir, versionPin string) CommandAndParser
+	InstallLocalPackages(dir string) CommandAndParser
+	// PrepareNode returns distro specific preparation like SELinux mode, it may be empty
+	PrepareNode() []CommandAndParser
+
+	// Firewall
+	// OpenPorts opens ports like "6443/tcp" or "30000-32767/tcp" when firewall of the distro is active
+	OpenPorts(ports ...string) CommandAndParser
+	// ClosePorts removes rules of OpenPorts when node is reset, ports which aren't open are skipped
+	ClosePorts(ports ...string) CommandAndParser
+
+	// Files
+	// WriteFile uploads content to path, parent directories are created
+	WriteFile(path, content string, mode os.FileMode) CommandAndParser
+	RemoveFiles(paths ...string) CommandAndParser
+	Hostname() CommandAndParser
+
+	// Kubeadm
+	InitKubeadm(configPath string, uploadCerts bool, parser Parser) CommandAndParser
+	UploadCerts(certificateKey string) CommandAndParser
+	KubeadmJoin(configPath string) CommandAndParser
+	KubeadmReset() CommandAndParser
+	KubeadmUpgradePlan(version string) CommandAndParser
+	KubeadmUpgradeApply(version string) CommandAndParser
+	KubeadmUpgradeNode() CommandAndParser
+	ListKubeadmTokens() CommandAndParser
+	CreateKubeadmToken() CommandAndParser
+	DeleteKubeadmToken(token string) CommandAndParser
+	AddKubeConfig() CommandAndParser
+	CatAdminConfFile() CommandAndParser
+
+	// Kubectl
+	UntaintControlPlane() CommandAndParser
+
+	// CNI
+	AddFlannel(version, podCIDR string) CommandAndParser
+	AddCalico(version, podCIDR string) CommandAndParser
+	InstallCiliumCLI() CommandAndParser
+	AddCilium(version, podCIDR string) CommandAndParser
+	LinkDownCNI0() CommandAndParser
+	IpconfigCNI0Down() CommandAndParser
+	IpconfigFlannelDown() CommandAndParser
+	BrctlDelbr() CommandAndParser
+	DeleteFlannelLinks() CommandAndParser
+	DeleteCalicoLinks() CommandAndParser
+	DeleteCiliumLinks() CommandAndParser
+	DeleteIptablesRules(pattern string) CommandAndParser
+	RemoveCNIState(dirs ...string) CommandAndParser
+
+	// Add-ons
+	CreateFolderForPV(path string) CommandAndParser
+	ResetGrafanaAdminPassword(password string) CommandAndParser
+}
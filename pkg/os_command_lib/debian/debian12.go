@@ -0,0 +1,25 @@
+package debian
+
+import (
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+// Debian12CommandLib builds commands for Debian 12 bookworm. Packages are installed by apt from the same
+// pkgs.k8s.io repositories as on Ubuntu, so repository, runtime, kubeadm and reset commands are shared with
+// Ubuntu 22.04 which boots with cgroup v2 too. Minimal Debian images don't contain iptables, it is installed
+// with other utils
+type Debian12CommandLib struct {
+	ubuntu.Ubuntu2204CommandLib
+}
+
+var _ cl.CommandLib = (*Debian12CommandLib)(nil)
+
+// InstallUtils installs iptables too, reset of network plugins cleans their rules up with it
+func (d *Debian12CommandLib) InstallUtils() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "sudo apt-get install -y ca-certificates curl gpg iptables",
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
@@ -0,0 +1,64 @@
+package debian
+
+import (
+	"testing"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/cltest"
+)
+
+func TestDebian12Commands(t *testing.T) {
+	d := &Debian12CommandLib{}
+
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "utils",
+			command: d.InstallUtils(),
+			want:    []string{"apt-get install -y ca-certificates curl gpg iptables"},
+			notWant: []string{"apt-transport-https"},
+		},
+		{
+			name:    "kubernetes repo",
+			command: d.AddK8SRepo("v1.30"),
+			want:    []string{"signed-by=/etc/apt/keyrings/kubernetes-apt-keyring.gpg", "https://pkgs.k8s.io/core:/stable:/v1.30/deb/ /"},
+		},
+		{
+			name:    "containerd",
+			command: d.SetContainerdCgroupDriver("systemd"),
+			want:    []string{"SystemdCgroup = true"},
+		},
+		{
+			name:    "kernel modules",
+			command: d.SetModprobe(),
+			want:    []string{"modprobe overlay", "modprobe br_netfilter"},
+		},
+		{
+			name:    "sysctl",
+			command: d.SetIpForward(),
+			want:    []string{"/etc/sysctl.d/99-kubernetes.conf", "net.ipv4.ip_forward = 1", "net.bridge.bridge-nf-call-iptables = 1", "\nsudo sysctl --system"},
+			notWant: []string{"/proc/sys", "/etc/sysctl.conf"},
+		},
+		{
+			name:    "reset",
+			command: d.DeleteIptablesRules("flannel"),
+			want:    []string{"iptables-save", "iptables-restore"},
+		},
+		{
+			name:    "firewall reset",
+			command: d.ClosePorts("6443/tcp", "30000-32767/tcp"),
+			want:    []string{"ufw status | grep -q 'Status: active'", "sudo ufw delete allow 6443/tcp && sudo ufw delete allow 30000:32767/tcp"},
+		},
+	}
+	for _, tt := range tests {
+		cltest.AssertContains(t, tt.name, tt.command.String(), tt.want, tt.notWant)
+	}
+
+	if drivers := d.CgroupDrivers(); len(drivers) != 1 || drivers[0] != "systemd" {
+		t.Errorf("cgroup drivers = %v, want [systemd]", drivers)
+	}
+}
@@ -0,0 +1,135 @@
+package distro
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/debian"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/rhel"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+// Distro is "<id>-<version id>" of /etc/os-release, it is stored on the node when the node is registered
+type Distro string
+
+const (
+	Ubuntu2004 Distro = "ubuntu-20.04"
+	Ubuntu2204 Distro = "ubuntu-22.04"
+	Ubuntu2404 Distro = "ubuntu-24.04"
+	Debian12   Distro = "debian-12"
+	Rocky9     Distro = "rocky-9"
+	Alma9      Distro = "almalinux-9"
+)
+
+var ErrUnsupportedDistro = errors.New("unsupported distro")
+
+// commandLibs are implementations of supported distros
+var commandLibs = map[Distro]func() cl.CommandLib{
+	Ubuntu2004: func() cl.CommandLib { return &ubuntu.Ubuntu2004CommandLib{} },
+	Ubuntu2204: func() cl.CommandLib { return &ubuntu.Ubuntu2204CommandLib{} },
+	Ubuntu2404: func() cl.CommandLib { return &ubuntu.Ubuntu2204CommandLib{} },
+	Debian12:   func() cl.CommandLib { return &debian.Debian12CommandLib{} },
+	Rocky9:     func() cl.CommandLib { return &rhel.Rhel9CommandLib{} },
+	Alma9:      func() cl.CommandLib { return &rhel.Rhel9CommandLib{} },
+}
+
+// OSRelease is the part of /etc/os-release used to choose command library
+type OSRelease struct {
+	ID         string
+	VersionID  string
+	PrettyName string
+}
+
+// ReadOSRelease prints /etc/os-release, the file is the same on all supported distros
+func ReadOSRelease() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "cat /etc/os-release",
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// ParseOSRelease parses KEY=value lines of /etc/os-release, values may be quoted
+func ParseOSRelease(data []byte) (OSRelease, error) {
+	var release OSRelease
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+
+		switch key {
+		case "ID":
+			release.ID = strings.ToLower(value)
+		case "VERSION_ID":
+			release.VersionID = value
+		case "PRETTY_NAME":
+			release.PrettyName = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return OSRelease{}, err
+	}
+	if release.ID == "" {
+		return OSRelease{}, fmt.Errorf("%w: no ID in /etc/os-release", ErrUnsupportedDistro)
+	}
+	return release, nil
+}
+
+// Detect returns supported distro of os-release. RHEL family reports minor release in version id like "9.4",
+// these distros are supported by major version
+func Detect(release OSRelease) (Distro, error) {
+	d := Distro(release.ID + "-" + release.VersionID)
+	if _, ok := commandLibs[d]; ok {
+		return d, nil
+	}
+	major, _, _ := strings.Cut(release.VersionID, ".")
+	if _, ok := commandLibs[Distro(release.ID+"-"+major)]; ok {
+		return Distro(release.ID + "-" + major), nil
+	}
+
+	name := release.PrettyName
+	if name == "" {
+		name = string(d)
+	}
+	return "", fmt.Errorf("%w %q, supported are %s", ErrUnsupportedDistro, name, strings.Join(Supported(), ", "))
+}
+
+// Supported returns sorted names of supported distros
+func Supported() []string {
+	names := make([]string, 0, len(commandLibs))
+	for d := range commandLibs {
+		names = append(names, string(d))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CommandLib returns command library of the distro. Nodes registered before distro detection have empty
+// distro, they were provisioned as Ubuntu 20.04
+func CommandLib(d Distro) (cl.CommandLib, error) {
+	if d == "" {
+		d = Ubuntu2004
+	}
+	newLib, ok := commandLibs[d]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, supported are %s", ErrUnsupportedDistro, d, strings.Join(Supported(), ", "))
+	}
+	return newLib(), nil
+}
@@ -0,0 +1,89 @@
+package distro
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease string
+		want      Distro
+		err       error
+	}{
+		{
+			name:      "ubuntu 20.04",
+			osRelease: "NAME=\"Ubuntu\"\nVERSION=\"20.04.6 LTS (Focal Fossa)\"\nID=ubuntu\nID_LIKE=debian\nPRETTY_NAME=\"Ubuntu 20.04.6 LTS\"\nVERSION_ID=\"20.04\"\n",
+			want:      Ubuntu2004,
+		},
+		{
+			name:      "ubuntu 24.04",
+			osRelease: "PRETTY_NAME=\"Ubuntu 24.04.1 LTS\"\nNAME=\"Ubuntu\"\nVERSION_ID=\"24.04\"\nID=ubuntu\nID_LIKE=debian\n",
+			want:      Ubuntu2404,
+		},
+		{
+			name:      "debian 12",
+			osRelease: "PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\nNAME=\"Debian GNU/Linux\"\nVERSION_ID=\"12\"\nVERSION=\"12 (bookworm)\"\nVERSION_CODENAME=bookworm\nID=debian\n",
+			want:      Debian12,
+		},
+		{
+			name:      "rocky 9.4",
+			osRelease: "NAME=\"Rocky Linux\"\nVERSION=\"9.4 (Blue Onyx)\"\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"9.4\"\nPRETTY_NAME=\"Rocky Linux 9.4 (Blue Onyx)\"\n",
+			want:      Rocky9,
+		},
+		{
+			name:      "alma 9.3",
+			osRelease: "NAME=\"AlmaLinux\"\nID=\"almalinux\"\nVERSION_ID=\"9.3\"\n",
+			want:      Alma9,
+		},
+		{
+			name:      "rocky 8",
+			osRelease: "ID=\"rocky\"\nVERSION_ID=\"8.9\"\n",
+			err:       ErrUnsupportedDistro,
+		},
+		{
+			name:      "single quotes and comments",
+			osRelease: "# comment\nID='ubuntu'\nVERSION_ID='20.04'\n",
+			want:      Ubuntu2004,
+		},
+		{
+			name:      "unsupported version",
+			osRelease: "ID=ubuntu\nVERSION_ID=\"18.04\"\nPRETTY_NAME=\"Ubuntu 18.04.6 LTS\"\n",
+			err:       ErrUnsupportedDistro,
+		},
+		{
+			name:      "unsupported distro",
+			osRelease: "ID=alpine\nVERSION_ID=3.19.1\n",
+			err:       ErrUnsupportedDistro,
+		},
+		{
+			name:      "no id",
+			osRelease: "cat: /etc/os-release: No such file or directory\n",
+			err:       ErrUnsupportedDistro,
+		},
+	}
+	for _, tt := range tests {
+		release, err := ParseOSRelease([]byte(tt.osRelease))
+		var got Distro
+		if err == nil {
+			got, err = Detect(release)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: distro = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCommandLib(t *testing.T) {
+	if _, err := CommandLib(""); err != nil {
+		t.Errorf("CommandLib of node registered before detection: %v", err)
+	}
+	if _, err := CommandLib("windows-11"); !errors.Is(err, ErrUnsupportedDistro) {
+		t.Errorf("CommandLib(windows-11) error = %v, want %v", err, ErrUnsupportedDistro)
+	}
+}
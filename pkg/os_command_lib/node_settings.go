@@ -0,0 +1,58 @@
+package os_command_lib
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNodeSettings = errors.New("node settings weren't applied")
+
+// nodeSettings are kernel and swap settings which kubelet needs, they are expected to survive reboot
+var nodeSettings = []struct {
+	Key  string
+	Want string
+	Name string
+}{
+	{Key: "swap", Want: "0", Name: "active swap devices"},
+	{Key: "fstab_swap", Want: "0", Name: "swap entries in /etc/fstab"},
+	{Key: "overlay", Want: "1", Name: "overlay module"},
+	{Key: "br_netfilter", Want: "1", Name: "br_netfilter module"},
+	{Key: "modules_load", Want: "1", Name: "/etc/modules-load.d entry"},
+	{Key: "net.ipv4.ip_forward", Want: "1", Name: "net.ipv4.ip_forward"},
+	{Key: "net.bridge.bridge-nf-call-iptables", Want: "1", Name: "net.bridge.bridge-nf-call-iptables"},
+	{Key: "net.bridge.bridge-nf-call-ip6tables", Want: "1", Name: "net.bridge.bridge-nf-call-ip6tables"},
+	{Key: "sysctl_d", Want: "1", Name: "/etc/sysctl.d entry"},
+}
+
+// ParseNodeSettings checks "key=value" lines printed by verification command, every setting of nodeSettings
+// has to be printed with expected value
+func ParseNodeSettings(result Result, extraData interface{}) error {
+	values := make(map[string]string, len(nodeSettings))
+	scanner := bufio.NewScanner(bytes.NewReader(result.Stdout))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if ok {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, setting := range nodeSettings {
+		value, ok := values[setting.Key]
+		if !ok {
+			failed = append(failed, setting.Name+" wasn't checked")
+		} else if value != setting.Want {
+			failed = append(failed, fmt.Sprintf("%s is %s, want %s", setting.Name, value, setting.Want))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", ErrNodeSettings, strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package os_command_lib
+
+import (
+	"errors"
+	"testing"
+)
+
+const appliedNodeSettings = `swap=0
+fstab_swap=0
+overlay=1
+br_netfilter=1
+modules_load=1
+net.ipv4.ip_forward=1
+net.bridge.bridge-nf-call-iptables=1
+net.bridge.bridge-nf-call-ip6tables=1
+sysctl_d=1
+`
+
+func TestParseNodeSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr bool
+	}{
+		{
+			name:   "applied",
+			output: appliedNodeSettings,
+		},
+		{
+			name:   "spaces and extra lines",
+			output: "Last login: today\n" + appliedNodeSettings + "  swap = 0  \n",
+		},
+		{
+			name:    "swap is on",
+			output:  appliedNodeSettings + "swap=1\n",
+			wantErr: true,
+		},
+		{
+			name:    "swap left in fstab",
+			output:  appliedNodeSettings + "fstab_swap=2\n",
+			wantErr: true,
+		},
+		{
+			name:    "module isn't loaded",
+			output:  appliedNodeSettings + "br_netfilter=0\n",
+			wantErr: true,
+		},
+		{
+			name:    "bridge sysctl is missing",
+			output:  "swap=0\nfstab_swap=0\noverlay=1\nbr_netfilter=1\nmodules_load=1\nnet.ipv4.ip_forward=1\nsysctl_d=1\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			output:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ParseNodeSettings(Result{Stdout: []byte(tt.output)}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNodeSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrNodeSettings) {
+				t.Errorf("ParseNodeSettings() error = %v, want ErrNodeSettings", err)
+			}
+		})
+	}
+}
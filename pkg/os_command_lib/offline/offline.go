@@ -0,0 +1,136 @@
+package offline
+
+import (
+	"path"
+	"strings"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/bundle"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+const (
+	// ArchivePath is where bundle archive is uploaded on nodes, it is kept to skip upload of the same bundle
+	ArchivePath = "/var/lib/paas/bundle.tar.gz"
+	// Dir is where bundle is extracted on nodes
+	Dir = "/var/lib/paas/bundle"
+
+	containerdConfigPath = "/etc/containerd/config.toml"
+)
+
+// CommandLib builds commands of distro library for nodes without access to the internet. Packages, images and
+// network plugin manifest are taken from bundle extracted to Dir, commands adding repositories are skipped.
+// Bundle has only containerd and manifests of Flannel and Calico, so other runtime and plugin commands
+// aren't replaced and settings using them are rejected by bundle validation
+type CommandLib struct {
+	cl.CommandLib
+	manifest *bundle.Manifest
+}
+
+var _ cl.CommandLib = (*CommandLib)(nil)
+
+func NewCommandLib(commandLib cl.CommandLib, manifest *bundle.Manifest) *CommandLib {
+	return &CommandLib{CommandLib: commandLib, manifest: manifest}
+}
+
+// ArchiveChecksum prints SHA-256 of uploaded archive, it fails when there is no archive
+func (o *CommandLib) ArchiveChecksum() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("sha256sum", ArchivePath).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+}
+
+// ExtractBundle replaces Dir by content of uploaded archive and checks files by checksums of the bundle
+func (o *CommandLib) ExtractBundle() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command: cl.Command(strings.Join([]string{
+			cl.NewCmd("rm", "-rf", Dir).Sudo().String(),
+			cl.NewCmd("mkdir", "-p", Dir).Sudo().String(),
+			cl.NewCmd("tar", "-xzf", ArchivePath, "-C", Dir).Sudo().String(),
+			"cd " + cl.Quote(Dir) + " && " + cl.NewCmd("sha256sum", "--quiet", "-c", bundle.ChecksumsFile).String(),
+		}, "\n")),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+func (o *CommandLib) SudoUpdate() cl.CommandAndParser {
+	return skipped("package lists aren't updated offline")
+}
+
+func (o *CommandLib) SudoFullUpgrade() cl.CommandAndParser {
+	return skipped("packages aren't upgraded offline")
+}
+
+// InstallUtils installs all packages of bundle, so kubeadm and containerd are installed by it too
+func (o *CommandLib) InstallUtils() cl.CommandAndParser {
+	return o.CommandLib.InstallLocalPackages(path.Join(Dir, bundle.PackagesDir))
+}
+
+func (o *CommandLib) InstallContainerd() cl.CommandAndParser {
+	return skipped("containerd is installed from offline bundle")
+}
+
+// SetContainerdCgroupDriver also sets sandbox image to pause image of bundle, the one of containerd default
+// config may differ and can't be pulled
+func (o *CommandLib) SetContainerdCgroupDriver(driver string) cl.CommandAndParser {
+	command := o.CommandLib.SetContainerdCgroupDriver(driver)
+	if pause := o.manifest.PauseImage(); pause != "" {
+		command.Command += cl.Command("\n" + cl.NewCmd("sed", "-i", `s|sandbox_image = .*|sandbox_image = "`+pause+`"|`, containerdConfigPath).Sudo().String())
+	}
+	return command
+}
+
+// StartContainerd starts containerd and imports images of bundle to namespace of kubelet
+func (o *CommandLib) StartContainerd() cl.CommandAndParser {
+	command := o.CommandLib.StartContainerd()
+	for _, image := range o.manifest.Images {
+		command.Command += cl.Command("\n" + cl.NewCmd("ctr", "-n", "k8s.io", "images", "import", bundlePath(image.File)).Sudo().String())
+	}
+	return command
+}
+
+func (o *CommandLib) DownloadK8SSigningKey(string) cl.CommandAndParser {
+	return skipped("signing key isn't needed offline")
+}
+
+func (o *CommandLib) AddK8SRepo(string) cl.CommandAndParser {
+	return skipped("kubernetes repository isn't added offline")
+}
+
+func (o *CommandLib) InstallKubeadm(string) cl.CommandAndParser {
+	return skipped("kubeadm is installed from offline bundle")
+}
+
+// AddFlannel applies Flannel manifest of bundle, its version is checked with bundle
+func (o *CommandLib) AddFlannel(_, podCIDR string) cl.CommandAndParser {
+	return o.applyCNIManifest(cl.FlannelPodCIDR(podCIDR))
+}
+
+// AddCalico applies Calico manifest of bundle, its version is checked with bundle
+func (o *CommandLib) AddCalico(_, podCIDR string) cl.CommandAndParser {
+	return o.applyCNIManifest(cl.CalicoPodCIDR(podCIDR))
+}
+
+func (o *CommandLib) applyCNIManifest(sed *cl.Cmd) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   sed.Arg(bundlePath(o.manifest.CNIManifest)).Pipe(cl.NewCmd("kubectl", "apply", "-f", "-")).Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// bundlePath returns path of bundle file on nodes, file can't point outside of Dir
+func bundlePath(file string) string {
+	return path.Join(Dir, path.Clean("/"+file))
+}
+
+// skipped returns no-op command which tells in task log why the step isn't done
+func skipped(reason string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd(":", reason).Command(),
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+}
@@ -0,0 +1,77 @@
+package offline
+
+import (
+	"testing"
+
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/bundle"
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/cltest"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/rhel"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+var manifest = &bundle.Manifest{
+	CNIManifest: "cni/../../kube-flannel.yml",
+	Images: []bundle.Image{
+		{Ref: "registry.k8s.io/pause:3.9", File: "images/registry.k8s.io_pause_3.9.tar"},
+		{Ref: "docker.io/flannel/flannel:v0.25.6", File: "images/docker.io_flannel_flannel_v0.25.6.tar"},
+	},
+}
+
+func TestOfflineCommands(t *testing.T) {
+	u := NewCommandLib(&ubuntu.Ubuntu2204CommandLib{}, manifest)
+	r := NewCommandLib(&rhel.Rhel9CommandLib{}, manifest)
+
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "extract",
+			command: u.ExtractBundle(),
+			want:    []string{"sudo tar -xzf /var/lib/paas/bundle.tar.gz -C /var/lib/paas/bundle", "cd /var/lib/paas/bundle && sha256sum --quiet -c SHA256SUMS"},
+		},
+		{
+			name:    "update",
+			command: u.SudoUpdate(),
+			want:    []string{": 'package lists aren'\\''t updated offline'"},
+			notWant: []string{"apt"},
+		},
+		{
+			name:    "deb packages",
+			command: u.InstallUtils(),
+			want:    []string{"apt-get install -y --no-download --allow-change-held-packages /var/lib/paas/bundle/packages/*.deb", "apt-mark hold kubelet kubeadm kubectl containerd"},
+		},
+		{
+			name:    "rpm packages",
+			command: r.InstallUtils(),
+			want:    []string{"dnf install -y '--disablerepo=*' /var/lib/paas/bundle/packages/*.rpm", "systemctl enable kubelet"},
+		},
+		{
+			name:    "kubernetes repository",
+			command: r.AddK8SRepo("v1.30"),
+			notWant: []string{"pkgs.k8s.io", "yum.repos.d"},
+		},
+		{
+			name:    "sandbox image",
+			command: u.SetContainerdCgroupDriver("systemd"),
+			want:    []string{"SystemdCgroup = true", `sudo sed -i 's|sandbox_image = .*|sandbox_image = "registry.k8s.io/pause:3.9"|' /etc/containerd/config.toml`},
+		},
+		{
+			name:    "images",
+			command: u.StartContainerd(),
+			want:    []string{"systemctl restart containerd.service\nsudo ctr -n k8s.io images import /var/lib/paas/bundle/images/registry.k8s.io_pause_3.9.tar\n", "images/docker.io_flannel_flannel_v0.25.6.tar"},
+		},
+		{
+			name:    "flannel",
+			command: u.AddFlannel("v0.25.6", "10.10.0.0/16"),
+			want:    []string{"sed 's#10.244.0.0/16#10.10.0.0/16#' /var/lib/paas/bundle/kube-flannel.yml | kubectl apply -f -"},
+			notWant: []string{"github.com", "curl"},
+		},
+	}
+	for _, tt := range tests {
+		cltest.AssertContains(t, tt.name, string(tt.command.Command), tt.want, tt.notWant)
+	}
+}
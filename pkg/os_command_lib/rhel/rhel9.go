@@ -0,0 +1,185 @@
+package rhel
+
+import (
+	"fmt"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+const (
+	kubernetesRepoPath = "/etc/yum.repos.d/kubernetes.repo"
+	crioRepoPath       = "/etc/yum.repos.d/cri-o.repo"
+	dockerRepoURL      = "https://download.docker.com/linux/centos/docker-ce.repo"
+)
+
+// Rhel9CommandLib builds commands for Rocky Linux 9 and AlmaLinux 9. Packages are installed by dnf from rpm
+// repositories of pkgs.k8s.io, they are excluded from dnf upgrade like apt-mark hold does on Debian family.
+// Kubeadm, kubectl, reset and file commands don't depend on package manager, so they are shared with
+// Ubuntu 22.04 which boots with cgroup v2 too. Conditions of the steps are the same as on Debian family
+type Rhel9CommandLib struct {
+	ubuntu.Ubuntu2204CommandLib
+}
+
+var _ cl.CommandLib = (*Rhel9CommandLib)(nil)
+
+func (r *Rhel9CommandLib) SudoUpdate() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "sudo dnf makecache",
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+}
+
+func (r *Rhel9CommandLib) SudoFullUpgrade() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "sudo dnf -y upgrade",
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+}
+
+// InstallUtils installs iptables for reset of network plugins, tar for cilium CLI and dnf config-manager for containerd repository
+func (r *Rhel9CommandLib) InstallUtils() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "sudo dnf install -y ca-certificates curl tar iptables-nft iproute-tc dnf-plugins-core",
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// AddCRIORepos adds CRI-O repository of pkgs.k8s.io for minor version like "v1.30"
+func (r *Rhel9CommandLib) AddCRIORepos(minorVersion string) cl.CommandAndParser {
+	return r.WriteFile(crioRepoPath, repoFile("cri-o", "CRI-O", fmt.Sprintf("https://pkgs.k8s.io/addons:/cri-o:/stable:/%s/rpm/", minorVersion), "cri-o"), 0644)
+}
+
+func (r *Rhel9CommandLib) ImportGPGKey(minorVersion string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("rpm", "--import", fmt.Sprintf("https://pkgs.k8s.io/addons:/cri-o:/stable:/%s/rpm/repodata/repomd.xml.key", minorVersion)).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// InstallCRIO installs CRI-O of version matching pattern like "1.30.*"
+func (r *Rhel9CommandLib) InstallCRIO(versionPin string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("dnf", "install", "-y", "--disableexcludes=cri-o", "cri-o-"+versionPin).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// InstallContainerd installs containerd.io of docker repository, RHEL family doesn't ship containerd
+func (r *Rhel9CommandLib) InstallContainerd() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.Command(fmt.Sprintf("sudo dnf config-manager --add-repo %s\nsudo dnf install -y containerd.io", dockerRepoURL)),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// PrepareNode sets SELinux to permissive mode now and after reboot, so containers may access host filesystem
+// like kubeadm requires. Setenforce fails on hosts with SELinux disabled, they need no change now and the
+// failure is tolerated
+func (r *Rhel9CommandLib) PrepareNode() []cl.CommandAndParser {
+	return []cl.CommandAndParser{{
+		Command:   "sudo setenforce 0 || true\nsudo sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config",
+		Parser:    nil,
+		Condition: cl.Required,
+	}}
+}
+
+// OpenPorts opens ports in default zone of firewalld and enables masquerading of pod traffic, nothing is
+// changed when firewalld isn't running
+func (r *Rhel9CommandLib) OpenPorts(ports ...string) cl.CommandAndParser {
+	firewallCmd := cl.NewCmd("firewall-cmd", "--permanent", "--add-masquerade").Sudo()
+	for _, port := range ports {
+		firewallCmd.Flag("add-port", port)
+	}
+	return cl.CommandAndParser{
+		Command:   cl.Command(fmt.Sprintf("if systemctl is-active --quiet firewalld; then %s && sudo firewall-cmd --reload; fi", firewallCmd)),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// ClosePorts removes ports opened by OpenPorts from default zone of firewalld. Masquerading is kept,
+// other services of the node may rely on it
+func (r *Rhel9CommandLib) ClosePorts(ports ...string) cl.CommandAndParser {
+	firewallCmd := cl.NewCmd("firewall-cmd", "--permanent").Sudo()
+	for _, port := range ports {
+		firewallCmd.Flag("remove-port", port)
+	}
+	return cl.CommandAndParser{
+		Command:   cl.Command(fmt.Sprintf("if systemctl is-active --quiet firewalld; then %s && sudo firewall-cmd --reload; fi", firewallCmd)),
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+}
+
+// DownloadK8SSigningKey imports signing key of pkgs.k8s.io repository for minor version like "v1.30"
+func (r *Rhel9CommandLib) DownloadK8SSigningKey(minorVersion string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("rpm", "--import", fmt.Sprintf("https://pkgs.k8s.io/core:/stable:/%s/rpm/repodata/repomd.xml.key", minorVersion)).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+func (r *Rhel9CommandLib) AddK8SRepo(minorVersion string) cl.CommandAndParser {
+	return r.WriteFile(kubernetesRepoPath, repoFile("kubernetes", "Kubernetes", fmt.Sprintf("https://pkgs.k8s.io/core:/stable:/%s/rpm/", minorVersion), "kubelet kubeadm kubectl cri-tools kubernetes-cni"), 0644)
+}
+
+// InstallKubeadm installs kubelet, kubeadm and kubectl of version matching pattern like "1.30.*", kubelet service
+// isn't enabled by rpm package unlike deb one
+func (r *Rhel9CommandLib) InstallKubeadm(versionPin string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.Command(cl.NewCmd("dnf", "install", "-y", "--disableexcludes=kubernetes", "kubelet-"+versionPin, "kubeadm-"+versionPin, "kubectl-"+versionPin).Sudo().String() + " && sudo systemctl enable kubelet"),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// UpgradeKubeadm upgrades only kubeadm package, kubelet and kubectl are upgraded after control plane
+func (r *Rhel9CommandLib) UpgradeKubeadm(versionPin string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("dnf", "install", "-y", "--disableexcludes=kubernetes", "kubeadm-"+versionPin).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// UpgradeKubelet upgrades kubelet and kubectl packages and restarts kubelet
+func (r *Rhel9CommandLib) UpgradeKubelet(versionPin string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.Command(cl.NewCmd("dnf", "install", "-y", "--disableexcludes=kubernetes", "kubelet-"+versionPin, "kubectl-"+versionPin).Sudo().String() + " && sudo systemctl daemon-reload && sudo systemctl restart kubelet"),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// DownloadPackages downloads kubelet, kubeadm, kubectl and containerd.io of version matching pattern like "1.30.*" to dir
+// with dependencies which aren't installed on this machine, docker repository of containerd.io is added first
+func (r *Rhel9CommandLib) DownloadPackages(dir, versionPin string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.Command(fmt.Sprintf("sudo dnf config-manager --add-repo %s\n%s", dockerRepoURL, cl.NewCmd("dnf", "download", "-y", "--resolve", "--disableexcludes=kubernetes", "--destdir="+dir, "kubelet-"+versionPin, "kubeadm-"+versionPin, "kubectl-"+versionPin, "containerd.io").Sudo())),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// InstallLocalPackages installs all packages of dir with repositories disabled, kubelet service is enabled like
+// InstallKubeadm does. Packages aren't upgraded later because repositories of them aren't added
+func (r *Rhel9CommandLib) InstallLocalPackages(dir string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.Command(cl.NewCmd("dnf", "install", "-y", "--disablerepo=*").Sudo().Raw(cl.Quote(dir)+"/*.rpm").String() + "\nsudo systemctl enable kubelet"),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// repoFile renders dnf repository of pkgs.k8s.io, excluded packages are installed only with --disableexcludes
+func repoFile(id, name, baseURL, exclude string) string {
+	return fmt.Sprintf("[%s]\nname=%s\nbaseurl=%s\nenabled=1\ngpgcheck=1\ngpgkey=%srepodata/repomd.xml.key\nexclude=%s\n", id, name, baseURL, baseURL, exclude)
+}
@@ -0,0 +1,170 @@
+package rhel
+
+import (
+	"testing"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/cltest"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/ubuntu"
+)
+
+func TestRhel9Commands(t *testing.T) {
+	r := &Rhel9CommandLib{}
+	u := &ubuntu.Ubuntu2204CommandLib{}
+
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		// debian is the same step of Debian family, its condition has to be kept
+		debian  cl.CommandAndParser
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "update",
+			command: r.SudoUpdate(),
+			debian:  u.SudoUpdate(),
+			want:    []string{"dnf makecache"},
+			notWant: []string{"apt"},
+		},
+		{
+			name:    "upgrade",
+			command: r.SudoFullUpgrade(),
+			debian:  u.SudoFullUpgrade(),
+			want:    []string{"dnf -y upgrade"},
+		},
+		{
+			name:    "utils",
+			command: r.InstallUtils(),
+			debian:  u.InstallUtils(),
+			want:    []string{"dnf install -y", "iptables-nft", "dnf-plugins-core"},
+		},
+		{
+			name:    "kubernetes repo",
+			command: r.AddK8SRepo("v1.30"),
+			debian:  u.AddK8SRepo("v1.30"),
+			want:    []string{"/etc/yum.repos.d/kubernetes.repo", "baseurl=https://pkgs.k8s.io/core:/stable:/v1.30/rpm/", "gpgkey=https://pkgs.k8s.io/core:/stable:/v1.30/rpm/repodata/repomd.xml.key", "exclude=kubelet kubeadm kubectl"},
+		},
+		{
+			name:    "kubernetes signing key",
+			command: r.DownloadK8SSigningKey("v1.30"),
+			debian:  u.DownloadK8SSigningKey("v1.30"),
+			want:    []string{"rpm --import https://pkgs.k8s.io/core:/stable:/v1.30/rpm/repodata/repomd.xml.key"},
+		},
+		{
+			name:    "download packages",
+			command: r.DownloadPackages("/tmp/bundle/packages", "1.30.*"),
+			debian:  u.DownloadPackages("/tmp/bundle/packages", "1.30.*"),
+			want:    []string{"dnf config-manager --add-repo", "dnf download -y --resolve --disableexcludes=kubernetes --destdir=/tmp/bundle/packages 'kubelet-1.30.*'", "containerd.io"},
+			notWant: []string{"apt"},
+		},
+		{
+			name:    "cri-o repo",
+			command: r.AddCRIORepos("v1.30"),
+			debian:  u.AddCRIORepos("v1.30"),
+			want:    []string{"/etc/yum.repos.d/cri-o.repo", "baseurl=https://pkgs.k8s.io/addons:/cri-o:/stable:/v1.30/rpm/", "exclude=cri-o"},
+		},
+		{
+			name:    "cri-o",
+			command: r.InstallCRIO("1.30.*"),
+			debian:  u.InstallCRIO("1.30.*"),
+			want:    []string{"dnf install -y --disableexcludes=cri-o 'cri-o-1.30.*'"},
+		},
+		{
+			name:    "containerd",
+			command: r.InstallContainerd(),
+			debian:  u.InstallContainerd(),
+			want:    []string{"dnf config-manager --add-repo", "dnf install -y containerd.io"},
+		},
+		{
+			name:    "kubeadm",
+			command: r.InstallKubeadm("1.30.2-*"),
+			debian:  u.InstallKubeadm("1.30.2-*"),
+			want:    []string{"--disableexcludes=kubernetes 'kubelet-1.30.2-*' 'kubeadm-1.30.2-*' 'kubectl-1.30.2-*'", "systemctl enable kubelet"},
+		},
+		{
+			name:    "kubeadm upgrade",
+			command: r.UpgradeKubeadm("1.31.*"),
+			debian:  u.UpgradeKubeadm("1.31.*"),
+			want:    []string{"--disableexcludes=kubernetes 'kubeadm-1.31.*'"},
+			notWant: []string{"kubelet"},
+		},
+		{
+			name:    "kubelet upgrade",
+			command: r.UpgradeKubelet("1.31.*"),
+			debian:  u.UpgradeKubelet("1.31.*"),
+			want:    []string{"'kubelet-1.31.*' 'kubectl-1.31.*'", "systemctl restart kubelet"},
+		},
+		{
+			name:    "firewalld",
+			command: r.OpenPorts("6443/tcp", "30000-32767/tcp"),
+			debian:  u.OpenPorts("6443/tcp", "30000-32767/tcp"),
+			want:    []string{"systemctl is-active --quiet firewalld", "firewall-cmd --permanent --add-masquerade --add-port=6443/tcp --add-port=30000-32767/tcp", "firewall-cmd --reload"},
+		},
+		{
+			name:    "firewalld reset",
+			command: r.ClosePorts("6443/tcp", "30000-32767/tcp"),
+			debian:  u.ClosePorts("6443/tcp", "30000-32767/tcp"),
+			want:    []string{"systemctl is-active --quiet firewalld", "firewall-cmd --permanent --remove-port=6443/tcp --remove-port=30000-32767/tcp", "firewall-cmd --reload"},
+			notWant: []string{"masquerade"},
+		},
+		{
+			name:    "sysctl",
+			command: r.SetIpForward(),
+			debian:  u.SetIpForward(),
+			want:    []string{"/etc/sysctl.d/99-kubernetes.conf", "net.ipv4.ip_forward = 1", "sysctl --system"},
+		},
+		{
+			name:    "reset",
+			command: r.KubeadmReset(),
+			debian:  u.KubeadmReset(),
+			want:    []string{"kubeadm reset -f"},
+		},
+		{
+			name:    "iptables cleanup",
+			command: r.DeleteIptablesRules("cali"),
+			debian:  u.DeleteIptablesRules("cali"),
+			want:    []string{"iptables-save", "iptables-restore"},
+		},
+	}
+	for _, tt := range tests {
+		cltest.AssertContains(t, tt.name, tt.command.String(), tt.want, append(tt.notWant, "apt-get", "apt-mark"))
+		if tt.command.Condition != tt.debian.Condition {
+			t.Errorf("%s: condition = %s, Debian family uses %s", tt.name, tt.command.Condition, tt.debian.Condition)
+		}
+	}
+}
+
+func TestRhel9PrepareNode(t *testing.T) {
+	commands := (&Rhel9CommandLib{}).PrepareNode()
+	if len(commands) != 1 {
+		t.Fatalf("prepare node commands = %d, want 1", len(commands))
+	}
+	cltest.AssertContains(t, "SELinux", string(commands[0].Command), []string{"sudo setenforce 0 || true\n", "SELINUX=permissive", "/etc/selinux/config"}, nil)
+	if commands[0].Condition != cl.Required {
+		t.Errorf("SELinux condition = %s, want %s", commands[0].Condition, cl.Required)
+	}
+	if ubuntuCommands := (&ubuntu.Ubuntu2204CommandLib{}).PrepareNode(); len(ubuntuCommands) != 0 {
+		t.Errorf("ubuntu prepare node commands = %v, want none", ubuntuCommands)
+	}
+}
+
+// TestInstallKubeadmExitCode checks that failed dnf install isn't hidden by enabling kubelet after it
+func TestInstallKubeadmExitCode(t *testing.T) {
+	command := string((&Rhel9CommandLib{}).InstallKubeadm("1.30.*").Command)
+	for _, dnf := range []int{0, 1} {
+		if code := cltest.ExitCode(t, command, map[string]int{"dnf": dnf, "systemctl": 0}); code != dnf {
+			t.Errorf("dnf exited with %d: exit code = %d, want %d", dnf, code, dnf)
+		}
+	}
+}
+
+// TestUpgradeKubeletExitCode checks that failed dnf install isn't hidden by restart of kubelet after it
+func TestUpgradeKubeletExitCode(t *testing.T) {
+	command := string((&Rhel9CommandLib{}).UpgradeKubelet("1.31.*").Command)
+	for _, dnf := range []int{0, 1} {
+		if code := cltest.ExitCode(t, command, map[string]int{"dnf": dnf, "systemctl": 0}); code != dnf {
+			t.Errorf("dnf exited with %d: exit code = %d, want %d", dnf, code, dnf)
+		}
+	}
+}
@@ -2,13 +2,26 @@ package ubuntu
 
 import (
 	"fmt"
-	"net/netip"
+	"os"
+	"strings"
 
 	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
 )
 
+const (
+	kubernetesModulesPath = "/etc/modules-load.d/kubernetes.conf"
+	kubernetesSysctlPath  = "/etc/sysctl.d/99-kubernetes.conf"
+	// manifestsDir keeps manifests applied by kubectl, so they can be inspected on the node
+	manifestsDir = "/var/lib/paas/manifests"
+
+	kubernetesModules = "overlay\nbr_netfilter\n"
+	kubernetesSysctl  = "net.ipv4.ip_forward = 1\nnet.bridge.bridge-nf-call-iptables = 1\nnet.bridge.bridge-nf-call-ip6tables = 1\n"
+)
+
 type Ubuntu2004CommandLib struct{}
 
+var _ cl.CommandLib = (*Ubuntu2004CommandLib)(nil)
+
 // Common commands for control-plane and workers
 
 func (u *Ubuntu2004CommandLib) SudoUpdate() cl.CommandAndParser {
@@ -27,30 +40,41 @@ func (u *Ubuntu2004CommandLib) SudoFullUpgrade() cl.CommandAndParser {
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddCRIORepos() cl.CommandAndParser {
+// AddCRIORepos adds CRI-O repository of pkgs.k8s.io for minor version like "v1.30"
+func (u *Ubuntu2004CommandLib) AddCRIORepos(minorVersion string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "echo \"deb https://download.opensuse.org/repositories/devel:/kubic:/libcontainers:/stable/xUbuntu_20.04/ /\"|sudo tee /etc/apt/sources.list.d/devel:kubic:libcontainers:stable.list\necho \"deb http://download.opensuse.org/repositories/devel:/kubic:/libcontainers:/stable:/cri-o:/1.26/xUbuntu_20.04/ /\"|sudo tee /etc/apt/sources.list.d/devel:kubic:libcontainers:stable:cri-o:1.26.list",
+		Command:   aptSource("/etc/apt/keyrings/cri-o-apt-keyring.gpg", fmt.Sprintf("https://pkgs.k8s.io/addons:/cri-o:/stable:/%s/deb/", minorVersion), "/etc/apt/sources.list.d/cri-o.list"),
 		Parser:    nil,
-		Condition: cl.Anyway,
+		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) ImportGPGKey() cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) ImportGPGKey(minorVersion string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "curl -L https://download.opensuse.org/repositories/devel:kubic:libcontainers:stable:cri-o:1.26/xUbuntu_20.04/Release.key | sudo apt-key add -; curl -L https://download.opensuse.org/repositories/devel:/kubic:/libcontainers:/stable/xUbuntu_20.04/Release.key | sudo apt-key add -",
+		Command:   aptKey(fmt.Sprintf("https://pkgs.k8s.io/addons:/cri-o:/stable:/%s/deb/Release.key", minorVersion), "/etc/apt/keyrings/cri-o-apt-keyring.gpg"),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) InstallCRIO() cl.CommandAndParser {
+// InstallCRIO installs CRI-O of version matching apt pattern like "1.30.*"
+func (u *Ubuntu2004CommandLib) InstallCRIO(versionPin string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo apt install -y cri-o cri-o-runc",
+		Command:   cl.Command(cl.NewCmd("apt-get", "install", "-y", "--allow-change-held-packages", "cri-o="+versionPin).Sudo().String() + " && sudo apt-mark hold cri-o"),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
+// SetCRIOCgroupManager makes CRI-O use the same cgroup driver as kubelet
+func (u *Ubuntu2004CommandLib) SetCRIOCgroupManager(driver string) cl.CommandAndParser {
+	conmonCgroup := "system.slice"
+	if driver == "cgroupfs" {
+		conmonCgroup = "pod"
+	}
+	return u.WriteFile("/etc/crio/crio.conf.d/02-cgroup-manager.conf", fmt.Sprintf("[crio.runtime]\ncgroup_manager = \"%s\"\nconmon_cgroup = \"%s\"\n", driver, conmonCgroup), 0644)
+}
+
 func (u *Ubuntu2004CommandLib) StartCRIO() cl.CommandAndParser {
 	return cl.CommandAndParser{
 		Command:   "sudo systemctl enable crio.service\nsudo systemctl start crio.service",
@@ -59,211 +83,381 @@ func (u *Ubuntu2004CommandLib) StartCRIO() cl.CommandAndParser {
 	}
 }
 
-func (u *Ubuntu2004CommandLib) DisableSWAP() cl.CommandAndParser {
+// InstallContainerd installs containerd of the distro repository, it is used instead of CRI-O when chosen in cluster settings
+func (u *Ubuntu2004CommandLib) InstallContainerd() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo swapoff -a",
+		Command:   "sudo apt-get install -y containerd\nsudo apt-mark hold containerd",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) InstallUtils() cl.CommandAndParser {
+// SetContainerdCgroupDriver writes default containerd config with runc using the same cgroup driver as kubelet
+func (u *Ubuntu2004CommandLib) SetContainerdCgroupDriver(driver string) cl.CommandAndParser {
+	systemdCgroup := driver == "systemd"
 	return cl.CommandAndParser{
-		Command:   "sudo apt-get install -y apt-transport-https ca-certificates curl",
+		Command:   cl.Command(fmt.Sprintf("sudo mkdir -p /etc/containerd\ncontainerd config default | sed 's/SystemdCgroup = .*/SystemdCgroup = %t/' | sudo tee /etc/containerd/config.toml > /dev/null", systemdCgroup)),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) DownloadGoogleCloudSigningKey() cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) StartContainerd() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo mkdir -p /etc/apt/keyrings\nsudo touch /etc/apt/keyrings/kubernetes-archive-keyring.gpg\necho \"xsBNBGKItdQBCADWmKTNZEYWgXy73FvKFY5fRro4tGNa4Be4TZW3wZpct9Cj8EjykU7S9EPoJ3EdKpxFltHRu7QbDi6LWSNA4XxwnudQrYGxnxx6Ru1KBHFxHhLfWsvFcGMwit/znpxtIt9UzqCm2YTEW5NUnzQ4rXYqVQK2FLG4weYJ5bKwkY+ZsnRJpzxdHGJ0pBiqwkMT8bfQdJymUBown+SeuQ2HEqfjVMsIRe0dweD2PHWeWo9fTXsz1Q5abiGckyOVyoN9//DgSvLUocUcZsrWvYPaN+o8lXTO3GYFGNVsx069rxarkeCjOpiQOWrQmywXISQudcusSgmmgfsRZYW7FDBy5MQrABEBAAHNUVJhcHR1cmUgQXV0b21hdGljIFNpZ25pbmcgS2V5IChjbG91ZC1yYXB0dXJlLXNpZ25pbmcta2V5LTIwMjItMDMtMDctMDhfMDFfMDEucHViKcLAYgQTAQgAFgUCYoi11AkQtT3IDRPt7wUCGwMCGQEAAMGoCAB8QBNIIN3Q2D3aahrfkb6axd55zOwR0tnriuJRoPHoNuorOpCv9aWMMvQACNWkxsvJxEF8OUbzhSYjAR534RDigjTetjK2i2wKLz/kJjZbuF4ZXMynCm40eVm1XZqU63U9XR2RxmXppyNpMqQO9LrzGEnNJuh23icaZY6no12axymxcle/+SCmda8oDAfa0iyA2iyg/eU05buZv54MC6RB13QtS+8vOrKDGr7RYp/VYvQzYWm+ck6DvlaVX6VB51BkLl23SQknyZIJBVPm8ttU65EyrrgG1jLLHFXDUqJ/RpNKq+PCzWiyt4uy3AfXK89RczLu3uxiD0CQI0T31u/IzsBNBGKItdQBCADIMMJdRcg0Phv7+CrZz3xRE8Fbz8AN+YCLigQeH0B9lijxkjAFr+thB0IrOu7ruwNY+mvdP6dAewUur+pJaIjEe+4s8JBEFb4BxJfBBPuEbGSxbi4OPEJuwT53TMJMEs7+gIxCCmwioTggTBp6JzDsT/cdBeyWCusCQwDWpqoYCoUWJLrUQ6dOlI7s6p+iIUNIamtyBCwb4izs27HdEpX8gvO9rEdtcb7399HyO3oD4gHgcuFiuZTpvWHdn9WYwPGM6npJNG7crtLnctTR0cP9KutSPNzpySeAniHx8L9ebdD9tNPCWC+OtOcGRrcBeEznkYh1C4kzdP1ORm5upnknABEBAAHCwF8EGAEIABMFAmKItdQJELU9yA0T7e8FAhsMAABJmAgAhRPk/dFj71bU/UTXrkEkZZzE9JzUgan/ttyRrV6QbFZABByf4pYjBj+yLKw3280//JWurKox2uzEq1hdXPedRHICRuh1Fjd00otaQ+wGF3kY74zlWivB6Wp6tnL9STQ1oVYBUv7HhSHoJ5shELyedxxHxurUgFAD+pbFXIiK8cnAHfXTJMcrmPpC+YWEC/DeqIyEcNPkzRhtRSuERXcq1n+KJvMUAKMD/tezwvujzBaaSWapmdnGmtRjjL7IxUeGamVWOwLQbUr+34MwzdeJdcL8fav5LA8Uk0ulyeXdwiAK8FKQsixI+xZvz7HUs8ln4pZwGw/TpvO9cMkHogtgzQ==\" | base64 -d | sudo tee -a /etc/apt/keyrings/kubernetes-archive-keyring.gpg",
+		Command:   "sudo systemctl enable containerd.service\nsudo systemctl restart containerd.service",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddK8SRepo() cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) RestartContainerd() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "echo \"deb [signed-by=/etc/apt/keyrings/kubernetes-archive-keyring.gpg] https://apt.kubernetes.io/ kubernetes-xenial main\" | sudo tee /etc/apt/sources.list.d/kubernetes.list",
+		Command:   "sudo systemctl restart containerd.service",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) InstallKubeadm() cl.CommandAndParser {
+// CgroupDrivers of Ubuntu 20.04 include cgroupfs, the distro boots with cgroup v1 hierarchy
+func (u *Ubuntu2004CommandLib) CgroupDrivers() []string {
+	return []string{"systemd", "cgroupfs"}
+}
+
+// PrepareNode is empty, AppArmor of Ubuntu doesn't need changes for Kubernetes
+func (u *Ubuntu2004CommandLib) PrepareNode() []cl.CommandAndParser {
+	return nil
+}
+
+// OpenPorts allows ports in ufw, ufw is inactive by default and nothing is changed then
+func (u *Ubuntu2004CommandLib) OpenPorts(ports ...string) cl.CommandAndParser {
+	rules := make([]string, 0, len(ports))
+	for _, port := range ports {
+		rules = append(rules, cl.NewCmd("ufw", "allow", strings.Replace(port, "-", ":", 1)).Sudo().String())
+	}
 	return cl.CommandAndParser{
-		Command:   "sudo apt-get install -y kubelet kubeadm kubectl\nsudo apt-mark hold kubelet kubeadm kubectl",
+		Command:   cl.Command(fmt.Sprintf("if sudo ufw status | grep -q 'Status: active'; then %s; fi", strings.Join(rules, " && "))),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) SetModprobe() cl.CommandAndParser {
+// ClosePorts deletes ufw rules added by OpenPorts, missing rules are skipped by ufw
+func (u *Ubuntu2004CommandLib) ClosePorts(ports ...string) cl.CommandAndParser {
+	rules := make([]string, 0, len(ports))
+	for _, port := range ports {
+		rules = append(rules, cl.NewCmd("ufw", "delete", "allow", strings.Replace(port, "-", ":", 1)).Sudo().String())
+	}
+	return cl.CommandAndParser{
+		Command:   cl.Command(fmt.Sprintf("if sudo ufw status | grep -q 'Status: active'; then %s; fi", strings.Join(rules, " && "))),
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+}
+
+// DisableSWAP turns swap off and comments swap entries of fstab out, so swap doesn't come back after reboot
+func (u *Ubuntu2004CommandLib) DisableSWAP() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo modprobe br_netfilter",
+		Command:   `sudo swapoff -a` + "\n" + `sudo sed -i -E 's/^([^#][^[:space:]]*[[:space:]]+[^[:space:]]+[[:space:]]+swap[[:space:]].*)$/#\1/' /etc/fstab`,
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) SudoSu() cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) InstallUtils() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo su",
+		Command:   "sudo apt-get install -y apt-transport-https ca-certificates curl gpg",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) SetIpForward() cl.CommandAndParser {
+// DownloadK8SSigningKey downloads signing key of pkgs.k8s.io repository for minor version like "v1.30"
+func (u *Ubuntu2004CommandLib) DownloadK8SSigningKey(minorVersion string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "echo '1' | sudo tee -a /proc/sys/net/ipv4/ip_forward",
+		Command:   aptKey(fmt.Sprintf("https://pkgs.k8s.io/core:/stable:/%s/deb/Release.key", minorVersion), "/etc/apt/keyrings/kubernetes-apt-keyring.gpg"),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) Exit() cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) AddK8SRepo(minorVersion string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "exit",
+		Command:   aptSource("/etc/apt/keyrings/kubernetes-apt-keyring.gpg", fmt.Sprintf("https://pkgs.k8s.io/core:/stable:/%s/deb/", minorVersion), "/etc/apt/sources.list.d/kubernetes.list"),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-// Control-plane
+// InstallKubeadm installs kubelet, kubeadm and kubectl of version matching apt pattern like "1.30.*"
+func (u *Ubuntu2004CommandLib) InstallKubeadm(versionPin string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.Command(cl.NewCmd("apt-get", "install", "-y", "--allow-change-held-packages", "kubelet="+versionPin, "kubeadm="+versionPin, "kubectl="+versionPin).Sudo().String() + " && sudo apt-mark hold kubelet kubeadm kubectl"),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
 
-func (u *Ubuntu2004CommandLib) InitKubeadm(parser cl.Parser) cl.CommandAndParser {
+// UpgradeKubeadm upgrades only kubeadm package, kubelet and kubectl are upgraded after control plane
+func (u *Ubuntu2004CommandLib) UpgradeKubeadm(versionPin string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo kubeadm init --pod-network-cidr=10.244.0.0/16",
-		Parser:    parser,
+		Command:   cl.Command(cl.NewCmd("apt-get", "install", "-y", "--allow-change-held-packages", "kubeadm="+versionPin).Sudo().String() + " && sudo apt-mark hold kubeadm"),
+		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) UntaintControlPlane() cl.CommandAndParser {
+// UpgradeKubelet upgrades kubelet and kubectl packages and restarts kubelet
+func (u *Ubuntu2004CommandLib) UpgradeKubelet(versionPin string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "kubectl taint nodes --all node-role.kubernetes.io/control-plane-",
+		Command:   cl.Command(cl.NewCmd("apt-get", "install", "-y", "--allow-change-held-packages", "kubelet="+versionPin, "kubectl="+versionPin).Sudo().String() + " && sudo apt-mark hold kubelet kubectl && sudo systemctl daemon-reload && sudo systemctl restart kubelet"),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddKubeConfig() cl.CommandAndParser {
+// DownloadPackages downloads kubelet, kubeadm, kubectl and containerd of version matching pattern like "1.30.*" to dir
+// with dependencies which aren't installed on this machine, so it has to be fresh machine of the nodes' distro
+func (u *Ubuntu2004CommandLib) DownloadPackages(dir, versionPin string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "rm -r  $HOME/.kube \n mkdir -p $HOME/.kube\nsudo cp -i /etc/kubernetes/admin.conf $HOME/.kube/config\nsudo chown $(id -u):$(id -g) $HOME/.kube/config",
+		Command: cl.Command(strings.Join([]string{
+			cl.NewCmd("mkdir", "-p", dir+"/partial").Sudo().String(),
+			cl.NewCmd("apt-get", "install", "-y", "--download-only", "--reinstall", "-o", "Dir::Cache::archives="+dir, "kubelet="+versionPin, "kubeadm="+versionPin, "kubectl="+versionPin, "containerd").Sudo().String(),
+			cl.NewCmd("rm", "-rf", dir+"/partial", dir+"/lock").Sudo().String(),
+		}, "\n")),
 		Parser:    nil,
-		Condition: cl.Anyway,
+		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddFlannel() cl.CommandAndParser {
+// InstallLocalPackages installs all packages of dir without access to repositories and holds them like InstallKubeadm does
+func (u *Ubuntu2004CommandLib) InstallLocalPackages(dir string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "kubectl apply -f https://github.com/flannel-io/flannel/releases/latest/download/kube-flannel.yml",
+		Command:   cl.Command(cl.NewCmd("apt-get", "install", "-y", "--no-download", "--allow-change-held-packages").Sudo().Raw(cl.Quote(dir)+"/*.deb").String() + "\nsudo apt-mark hold kubelet kubeadm kubectl containerd"),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) InstallHelm() cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) RestartCRIO() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo snap install helm --classic",
+		Command:   "sudo systemctl restart crio.service",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddBitnamiRepo() cl.CommandAndParser {
+// KubeadmUpgradePlan checks that cluster can be upgraded to version like "1.31.2" or "1.31" by installed kubeadm
+func (u *Ubuntu2004CommandLib) KubeadmUpgradePlan(version string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "helm repo add bitnami https://charts.bitnami.com/bitnami",
+		Command:   cl.Command(kubeadmUpgradeTarget(version) + ` && sudo kubeadm upgrade plan "$version"`),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) InstallPrometheus() cl.CommandAndParser {
+// KubeadmUpgradeApply upgrades control plane of the cluster to version like "1.31.2" or "1.31" by installed kubeadm
+func (u *Ubuntu2004CommandLib) KubeadmUpgradeApply(version string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "helm install prometheus bitnami/kube-prometheus",
+		Command:   cl.Command(kubeadmUpgradeTarget(version) + ` && sudo kubeadm upgrade apply -y "$version"`),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) CreateFolderForPV() cl.CommandAndParser {
+// KubeadmUpgradeNode upgrades kubelet config of worker or control plane of additional master
+func (u *Ubuntu2004CommandLib) KubeadmUpgradeNode() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "sudo mkdir -p /devkube/postgres",
+		Command:   "sudo kubeadm upgrade node",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddStorageClass() cl.CommandAndParser {
+// SetModprobe loads overlay and br_netfilter modules now and lists them in modules-load.d for next boots.
+// Container runtimes store images on overlay, bridged pod traffic is filtered by iptables with br_netfilter
+func (u *Ubuntu2004CommandLib) SetModprobe() cl.CommandAndParser {
+	command := u.WriteFile(kubernetesModulesPath, kubernetesModules, 0644)
+	command.Command = "sudo modprobe overlay\nsudo modprobe br_netfilter"
+	return command
+}
+
+func (u *Ubuntu2004CommandLib) SudoSu() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "kubectl apply -f - <<EOF \nkind: StorageClass\napiVersion: storage.k8s.io/v1\nmetadata:\n  name: local-storage\n  annotations:\n    storageclass.kubernetes.io/is-default-class: \"true\"\nprovisioner: kubernetes.io/no-provisioner\nvolumeBindingMode: Immediate\nEOF",
+		Command:   "sudo su",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddMetallbConf(ip string) cl.CommandAndParser {
+// SetIpForward enables forwarding and filtering of bridged traffic by sysctl.d file, it is applied now and on every boot.
+// Modules have to be loaded before, bridge settings don't exist without br_netfilter
+func (u *Ubuntu2004CommandLib) SetIpForward() cl.CommandAndParser {
+	command := u.WriteFile(kubernetesSysctlPath, kubernetesSysctl, 0644)
+	command.Command = "sudo sysctl --system"
+	return command
+}
+
+// VerifyNodeSettings prints swap, modules and sysctl state of the node and checks it by cl.ParseNodeSettings.
+// Files are read instead of running swapon and sysctl, they aren't in PATH of regular users on some distros
+func (u *Ubuntu2004CommandLib) VerifyNodeSettings() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   cl.Command(fmt.Sprintf("kubectl apply -f - <<EOF \n---\napiVersion: metallb.io/v1beta1\nkind: IPAddressPool\nmetadata:\n  name: default\n  namespace: default\nspec:\n  addresses:\n  - %s/32\n  autoAssign: true\n---\napiVersion: metallb.io/v1beta1\nkind: L2Advertisement\nmetadata:\n  name: default\n  namespace: default\nspec:\n  ipAddressPools:\n  - default\nEOF", ip)),
+		Command: cl.Command(strings.Join([]string{
+			`echo "swap=$(($(wc -l < /proc/swaps) - 1))"`,
+			`echo "fstab_swap=$(awk '$1 !~ /^#/ && $3 == "swap"' /etc/fstab | wc -l)"`,
+			`echo "overlay=$(test -d /sys/module/overlay && echo 1 || echo 0)"`,
+			`echo "br_netfilter=$(test -d /sys/module/br_netfilter && echo 1 || echo 0)"`,
+			fmt.Sprintf(`echo "modules_load=$(grep -qx overlay %[1]s && grep -qx br_netfilter %[1]s && echo 1 || echo 0)"`, kubernetesModulesPath),
+			`echo "net.ipv4.ip_forward=$(cat /proc/sys/net/ipv4/ip_forward)"`,
+			`echo "net.bridge.bridge-nf-call-iptables=$(cat /proc/sys/net/bridge/bridge-nf-call-iptables)"`,
+			`echo "net.bridge.bridge-nf-call-ip6tables=$(cat /proc/sys/net/bridge/bridge-nf-call-ip6tables)"`,
+			fmt.Sprintf(`echo "sysctl_d=$(grep -qx 'net.ipv4.ip_forward = 1' %s && echo 1 || echo 0)"`, kubernetesSysctlPath),
+		}, "\n")),
+		Parser:    cl.ParseNodeSettings,
+		Condition: cl.Required,
+	}
+}
+
+func (u *Ubuntu2004CommandLib) Exit() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "exit",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddPostgresPV(hostname string, number int) cl.CommandAndParser {
+// Control-plane
+
+// InitKubeadm creates cluster from kubeadm config file. When uploadCerts is set control-plane certificates are uploaded
+// encrypted with certificate key from the config, so other masters can join the cluster
+func (u *Ubuntu2004CommandLib) InitKubeadm(configPath string, uploadCerts bool, parser cl.Parser) cl.CommandAndParser {
+	cp := cl.CommandAndParser{
+		Command:   cl.NewCmd("kubeadm", "init").Flag("config", configPath).Sudo().Command(),
+		Parser:    parser,
+		Condition: cl.Required,
+	}
+	if uploadCerts {
+		cp = cp.WithArgs("--upload-certs")
+	}
+	return cp
+}
+
+// UploadCerts uploads control-plane certificates of the running master again, uploaded certificates are deleted after two hours
+func (u *Ubuntu2004CommandLib) UploadCerts(certificateKey string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   cl.Command(fmt.Sprintf("kubectl apply -f - <<EOF \napiVersion: v1\nkind: PersistentVolume\nmetadata:\n  name: pv-%i\n  labels:\n    type: local\nspec:\n  capacity:\n    storage: 1Gi\n  volumeMode: Filesystem\n  accessModes:\n  - ReadWriteOnce\n  persistentVolumeReclaimPolicy: Retain\n  storageClassName: local-storage\n  local:\n    path: /devkube/postgresql\n  nodeAffinity:\n    required:\n      nodeSelectorTerms:\n      - matchExpressions:\n        - key: kubernetes.io/hostname\n          operator: In\n          values:\n          - %s\nEOF", number, hostname)),
+		Command:   cl.NewCmd("kubeadm", "init", "phase", "upload-certs", "--upload-certs").Flag("certificate-key", certificateKey).Sudo().Command(),
 		Parser:    nil,
 		Condition: cl.Required,
+		Secrets:   []string{certificateKey},
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddGrafanaPV(hostname string) cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) UntaintControlPlane() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   cl.Command(fmt.Sprintf("kubectl apply -f - <<EOF\napiVersion: v1\nkind: PersistentVolume\nmetadata:\n  name: pv-grafana\n  labels:\n    type: local\nspec:\n  capacity:\n    storage: 10Gi\n  volumeMode: Filesystem\n  accessModes:\n  - ReadWriteOnce\n  persistentVolumeReclaimPolicy: Retain\n  storageClassName: local-storage\n  local:\n    path: /devkube/postgresql\n  nodeAffinity:\n    required:\n      nodeSelectorTerms:\n      - matchExpressions:\n        - key: kubernetes.io/hostname\n          operator: In\n          values:\n          - %s\nEOF", hostname)),
+		Command:   "kubectl taint nodes --all node-role.kubernetes.io/control-plane-",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddGrafanaIngress(userNum int) cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) AddKubeConfig() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   cl.Command(fmt.Sprintf("kubectl apply -f - <<EOF\napiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: grafana-ingress\nspec:\n  ingressClassName: nginx\n  rules:\n  - host: \"grafana.user%d.huginn.pro\"\n    http:\n      paths:\n      - path: /\n        pathType: Prefix\n        backend:\n          service:\n            name: grafana\n            port:\n              number: 3000\nEOF", userNum)),
+		Command:   "rm -rf $HOME/.kube\nmkdir -p $HOME/.kube\nsudo cp -i /etc/kubernetes/admin.conf $HOME/.kube/config\nsudo chown $(id -u):$(id -g) $HOME/.kube/config",
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+}
+
+// AddFlannel applies Flannel manifest of the version with pod network replaced by podCIDR
+func (u *Ubuntu2004CommandLib) AddFlannel(version, podCIDR string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command: cl.NewCmd("curl", "-fsSL", cl.FlannelManifestURL(version)).Pipe(
+			cl.FlannelPodCIDR(podCIDR),
+			cl.NewCmd("kubectl", "apply", "-f", "-"),
+		).Command(),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddGrafanaDashboardConfigMap() cl.CommandAndParser {
+// AddCalico applies Calico manifest of the version with default IP pool set to podCIDR
+func (u *Ubuntu2004CommandLib) AddCalico(version, podCIDR string) cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "kubectl apply -f - <<EOF\napiVersion: v1\ndata:\n  def_dashboard.json: |2\n\n    {\n      \"annotations\": {\n        \"list\": [\n          {\n            \"builtIn\": 1,\n            \"datasource\": {\n              \"type\": \"grafana\",\n              \"uid\": \"-- Grafana --\"\n            },\n            \"enable\": true,\n            \"hide\": true,\n            \"iconColor\": \"rgba(0, 211, 255, 1)\",\n            \"name\": \"Annotations & Alerts\",\n            \"target\": {\n              \"limit\": 100,\n              \"matchAny\": false,\n              \"tags\": [],\n              \"type\": \"dashboard\"\n            },\n            \"type\": \"dashboard\"\n          }\n        ]\n      },\n      \"description\": \"Monitors Kubernetes cluster using Prometheus. Shows overall cluster CPU / Memory / Filesystem usage as well as individual pod, containers, systemd services statistics. Uses cAdvisor metrics only.\",\n      \"editable\": true,\n      \"fiscalYearStartMonth\": 0,\n      \"gnetId\": 315,\n      \"graphTooltip\": 0,\n      \"id\": 4,\n      \"links\": [],\n      \"liveNow\": false,\n      \"panels\": [\n        {\n          \"collapsed\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 0\n          },\n          \"id\": 33,\n          \"panels\": [],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Network I/O pressure\",\n          \"type\": \"row\"\n        },\n        {\n          \"aliasColors\": {},\n          \"bars\": false,\n          \"dashLength\": 10,\n          \"dashes\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"decimals\": 2,\n          \"editable\": true,\n          \"error\": false,\n          \"fill\": 1,\n          \"fillGradient\": 0,\n          \"grid\": {},\n          \"gridPos\": {\n            \"h\": 6,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 1\n          },\n          \"height\": \"200px\",\n          \"hiddenSeries\": false,\n          \"id\": 32,\n          \"isNew\": true,\n          \"legend\": {\n            \"alignAsTable\": false,\n            \"avg\": true,\n            \"current\": true,\n            \"max\": false,\n            \"min\": false,\n            \"rightSide\": false,\n            \"show\": false,\n            \"sideWidth\": 200,\n            \"sort\": \"current\",\n            \"sortDesc\": true,\n            \"total\": false,\n            \"values\": true\n          },\n          \"lines\": true,\n          \"linewidth\": 2,\n          \"links\": [],\n          \"nullPointMode\": \"connected\",\n          \"options\": {\n            \"alertThreshold\": true\n          },\n          \"percentage\": false,\n          \"pluginVersion\": \"9.4.7\",\n          \"pointradius\": 5,\n          \"points\": false,\n          \"renderer\": \"flot\",\n          \"seriesOverrides\": [],\n          \"spaceLength\": 10,\n          \"stack\": false,\n          \"steppedLine\": false,\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (rate (container_network_receive_bytes_total{kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m]))\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"legendFormat\": \"Received\",\n              \"metric\": \"network\",\n              \"refId\": \"A\",\n              \"step\": 10\n            },\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"- sum (rate (container_network_transmit_bytes_total{kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m]))\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"legendFormat\": \"Sent\",\n              \"metric\": \"network\",\n              \"refId\": \"B\",\n              \"step\": 10\n            }\n          ],\n          \"thresholds\": [],\n          \"timeRegions\": [],\n          \"title\": \"Network I/O pressure\",\n          \"tooltip\": {\n            \"msResolution\": false,\n            \"shared\": true,\n            \"sort\": 0,\n            \"value_type\": \"cumulative\"\n          },\n          \"type\": \"graph\",\n          \"xaxis\": {\n            \"mode\": \"time\",\n            \"show\": true,\n            \"values\": []\n          },\n          \"yaxes\": [\n            {\n              \"format\": \"Bps\",\n              \"logBase\": 1,\n              \"show\": true\n            },\n            {\n              \"format\": \"Bps\",\n              \"logBase\": 1,\n              \"show\": false\n            }\n          ],\n          \"yaxis\": {\n            \"align\": false\n          }\n        },\n        {\n          \"collapsed\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 7\n          },\n          \"id\": 34,\n          \"panels\": [],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Total usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"max\": 100,\n              \"min\": 0,\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"rgba(50, 172, 45, 0.97)\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"rgba(237, 129, 40, 0.89)\",\n                    \"value\": 65\n                  },\n                  {\n                    \"color\": \"rgba(245, 54, 54, 0.9)\",\n                    \"value\": 90\n                  }\n                ]\n              },\n              \"unit\": \"percent\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 5,\n            \"w\": 8,\n            \"x\": 0,\n            \"y\": 8\n          },\n          \"id\": 4,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"showThresholdLabels\": false,\n            \"showThresholdMarkers\": true\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (container_memory_working_set_bytes{id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) / sum (machine_memory_bytes{kubernetes_io_hostname=~\\\"^$Node$\\\"}) * 100\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Cluster memory usage\",\n          \"type\": \"gauge\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"max\": 100,\n              \"min\": 0,\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"rgba(50, 172, 45, 0.97)\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"rgba(237, 129, 40, 0.89)\",\n                    \"value\": 65\n                  },\n                  {\n                    \"color\": \"rgba(245, 54, 54, 0.9)\",\n                    \"value\": 90\n                  }\n                ]\n              },\n              \"unit\": \"percent\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 5,\n            \"w\": 8,\n            \"x\": 8,\n            \"y\": 8\n          },\n          \"id\": 6,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"showThresholdLabels\": false,\n            \"showThresholdMarkers\": true\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (rate (container_cpu_usage_seconds_total{id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) / sum (machine_cpu_cores{kubernetes_io_hostname=~\\\"^$Node$\\\"}) * 100\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Cluster CPU usage (1m avg)\",\n          \"type\": \"gauge\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"max\": 100,\n              \"min\": 0,\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"rgba(50, 172, 45, 0.97)\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"rgba(237, 129, 40, 0.89)\",\n                    \"value\": 65\n                  },\n                  {\n                    \"color\": \"rgba(245, 54, 54, 0.9)\",\n                    \"value\": 90\n                  }\n                ]\n              },\n              \"unit\": \"percent\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 5,\n            \"w\": 8,\n            \"x\": 16,\n            \"y\": 8\n          },\n          \"id\": 7,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"showThresholdLabels\": false,\n            \"showThresholdMarkers\": true\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (container_fs_usage_bytes{device=~\\\"^/dev/[sv]d[a-z][1-9]$\\\",id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) / sum (container_fs_limit_bytes{device=~\\\"^/dev/[sv]d[a-z][1-9]$\\\",id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) * 100\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"legendFormat\": \"\",\n              \"metric\": \"\",\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Cluster filesystem usage\",\n          \"type\": \"gauge\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"green\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"red\",\n                    \"value\": 80\n                  }\n                ]\n              },\n              \"unit\": \"bytes\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 3,\n            \"w\": 4,\n            \"x\": 0,\n            \"y\": 13\n          },\n          \"id\": 9,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"colorMode\": \"none\",\n            \"graphMode\": \"none\",\n            \"justifyMode\": \"auto\",\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"textMode\": \"auto\"\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (container_memory_working_set_bytes{id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"})\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Used\",\n          \"type\": \"stat\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"green\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"red\",\n                    \"value\": 80\n                  }\n                ]\n              },\n              \"unit\": \"bytes\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 3,\n            \"w\": 4,\n            \"x\": 4,\n            \"y\": 13\n          },\n          \"id\": 10,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"colorMode\": \"none\",\n            \"graphMode\": \"none\",\n            \"justifyMode\": \"auto\",\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"textMode\": \"auto\"\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (machine_memory_bytes{kubernetes_io_hostname=~\\\"^$Node$\\\"})\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Total\",\n          \"type\": \"stat\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"green\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"red\",\n                    \"value\": 80\n                  }\n                ]\n              },\n              \"unit\": \"none\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 3,\n            \"w\": 4,\n            \"x\": 8,\n            \"y\": 13\n          },\n          \"id\": 11,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"colorMode\": \"none\",\n            \"graphMode\": \"none\",\n            \"justifyMode\": \"auto\",\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"textMode\": \"auto\"\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (rate (container_cpu_usage_seconds_total{id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m]))\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Used\",\n          \"type\": \"stat\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"green\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"red\",\n                    \"value\": 80\n                  }\n                ]\n              },\n              \"unit\": \"none\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 3,\n            \"w\": 4,\n            \"x\": 12,\n            \"y\": 13\n          },\n          \"id\": 12,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"colorMode\": \"none\",\n            \"graphMode\": \"none\",\n            \"justifyMode\": \"auto\",\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"textMode\": \"auto\"\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (machine_cpu_cores{kubernetes_io_hostname=~\\\"^$Node$\\\"})\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Total\",\n          \"type\": \"stat\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"green\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"red\",\n                    \"value\": 80\n                  }\n                ]\n              },\n              \"unit\": \"bytes\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 3,\n            \"w\": 4,\n            \"x\": 16,\n            \"y\": 13\n          },\n          \"id\": 13,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"colorMode\": \"none\",\n            \"graphMode\": \"none\",\n            \"justifyMode\": \"auto\",\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"textMode\": \"auto\"\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (container_fs_usage_bytes{device=~\\\"^/dev/[sv]d[a-z][1-9]$\\\",id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"})\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Used\",\n          \"type\": \"stat\"\n        },\n        {\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"fieldConfig\": {\n            \"defaults\": {\n              \"color\": {\n                \"mode\": \"thresholds\"\n              },\n              \"decimals\": 2,\n              \"mappings\": [\n                {\n                  \"options\": {\n                    \"match\": \"null\",\n                    \"result\": {\n                      \"text\": \"N/A\"\n                    }\n                  },\n                  \"type\": \"special\"\n                }\n              ],\n              \"thresholds\": {\n                \"mode\": \"absolute\",\n                \"steps\": [\n                  {\n                    \"color\": \"green\",\n                    \"value\": null\n                  },\n                  {\n                    \"color\": \"red\",\n                    \"value\": 80\n                  }\n                ]\n              },\n              \"unit\": \"bytes\"\n            },\n            \"overrides\": []\n          },\n          \"gridPos\": {\n            \"h\": 3,\n            \"w\": 4,\n            \"x\": 20,\n            \"y\": 13\n          },\n          \"id\": 14,\n          \"links\": [],\n          \"maxDataPoints\": 100,\n          \"options\": {\n            \"colorMode\": \"none\",\n            \"graphMode\": \"none\",\n            \"justifyMode\": \"auto\",\n            \"orientation\": \"horizontal\",\n            \"reduceOptions\": {\n              \"calcs\": [\n                \"lastNotNull\"\n              ],\n              \"fields\": \"\",\n              \"values\": false\n            },\n            \"textMode\": \"auto\"\n          },\n          \"pluginVersion\": \"9.4.7\",\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (container_fs_limit_bytes{device=~\\\"^/dev/[sv]d[a-z][1-9]$\\\",id=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"})\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"title\": \"Total\",\n          \"type\": \"stat\"\n        },\n        {\n          \"collapsed\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 16\n          },\n          \"id\": 35,\n          \"panels\": [],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Pods CPU usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"aliasColors\": {},\n          \"bars\": false,\n          \"dashLength\": 10,\n          \"dashes\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"decimals\": 3,\n          \"editable\": true,\n          \"error\": false,\n          \"fill\": 0,\n          \"fillGradient\": 0,\n          \"grid\": {},\n          \"gridPos\": {\n            \"h\": 7,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 17\n          },\n          \"height\": \"\",\n          \"hiddenSeries\": false,\n          \"id\": 17,\n          \"isNew\": true,\n          \"legend\": {\n            \"alignAsTable\": true,\n            \"avg\": true,\n            \"current\": true,\n            \"max\": false,\n            \"min\": false,\n            \"rightSide\": true,\n            \"show\": true,\n            \"sort\": \"current\",\n            \"sortDesc\": true,\n            \"total\": false,\n            \"values\": true\n          },\n          \"lines\": true,\n          \"linewidth\": 2,\n          \"links\": [],\n          \"nullPointMode\": \"connected\",\n          \"options\": {\n            \"alertThreshold\": true\n          },\n          \"percentage\": false,\n          \"pluginVersion\": \"9.4.7\",\n          \"pointradius\": 5,\n          \"points\": false,\n          \"renderer\": \"flot\",\n          \"seriesOverrides\": [],\n          \"spaceLength\": 10,\n          \"stack\": false,\n          \"steppedLine\": true,\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (rate (container_cpu_usage_seconds_total{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (pod_name)\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"legendFormat\": \"{{ pod_name }}\",\n              \"metric\": \"container_cpu\",\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"thresholds\": [],\n          \"timeRegions\": [],\n          \"title\": \"Pods CPU usage (1m avg)\",\n          \"tooltip\": {\n            \"msResolution\": true,\n            \"shared\": true,\n            \"sort\": 2,\n            \"value_type\": \"cumulative\"\n          },\n          \"type\": \"graph\",\n          \"xaxis\": {\n            \"mode\": \"time\",\n            \"show\": true,\n            \"values\": []\n          },\n          \"yaxes\": [\n            {\n              \"format\": \"none\",\n              \"label\": \"cores\",\n              \"logBase\": 1,\n              \"show\": true\n            },\n            {\n              \"format\": \"short\",\n              \"logBase\": 1,\n              \"show\": false\n            }\n          ],\n          \"yaxis\": {\n            \"align\": false\n          }\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 24\n          },\n          \"id\": 36,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 3,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 0,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 7,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 24\n              },\n              \"height\": \"\",\n              \"id\": 23,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": true,\n                \"show\": true,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": true,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_cpu_usage_seconds_total{systemd_service_name!=\\\"\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (systemd_service_name)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"{{ systemd_service_name }}\",\n                  \"metric\": \"container_cpu\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"System services CPU usage (1m avg)\",\n              \"tooltip\": {\n                \"msResolution\": true,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"none\",\n                  \"label\": \"cores\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"System services CPU usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 25\n          },\n          \"id\": 37,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 3,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 0,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 7,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 25\n              },\n              \"height\": \"\",\n              \"id\": 24,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"hideEmpty\": false,\n                \"hideZero\": false,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": true,\n                \"show\": true,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": true,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_cpu_usage_seconds_total{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",container_name!=\\\"POD\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (container_name, pod_name)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"pod: {{ pod_name }} | {{ container_name }}\",\n                  \"metric\": \"container_cpu\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_cpu_usage_seconds_total{image!=\\\"\\\",name!~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (kubernetes_io_hostname, name, image)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"docker: {{ kubernetes_io_hostname }} | {{ image }} ({{ name }})\",\n                  \"metric\": \"container_cpu\",\n                  \"refId\": \"B\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_cpu_usage_seconds_total{rkt_container_name!=\\\"\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (kubernetes_io_hostname, rkt_container_name)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"rkt: {{ kubernetes_io_hostname }} | {{ rkt_container_name }}\",\n                  \"metric\": \"container_cpu\",\n                  \"refId\": \"C\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"Containers CPU usage (1m avg)\",\n              \"tooltip\": {\n                \"msResolution\": true,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"none\",\n                  \"label\": \"cores\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Containers CPU usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 26\n          },\n          \"id\": 38,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 3,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 0,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 14,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 26\n              },\n              \"id\": 20,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": false,\n                \"show\": true,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": true,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_cpu_usage_seconds_total{id!=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (id)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"{{ id }}\",\n                  \"metric\": \"container_cpu\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"All processes CPU usage (1m avg)\",\n              \"tooltip\": {\n                \"msResolution\": true,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"none\",\n                  \"label\": \"cores\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"All processes CPU usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"collapsed\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 27\n          },\n          \"id\": 39,\n          \"panels\": [],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Pods memory usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"aliasColors\": {},\n          \"bars\": false,\n          \"dashLength\": 10,\n          \"dashes\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"decimals\": 2,\n          \"editable\": true,\n          \"error\": false,\n          \"fill\": 0,\n          \"fillGradient\": 0,\n          \"grid\": {},\n          \"gridPos\": {\n            \"h\": 7,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 28\n          },\n          \"hiddenSeries\": false,\n          \"id\": 25,\n          \"isNew\": true,\n          \"legend\": {\n            \"alignAsTable\": true,\n            \"avg\": true,\n            \"current\": true,\n            \"max\": false,\n            \"min\": false,\n            \"rightSide\": true,\n            \"show\": true,\n            \"sideWidth\": 200,\n            \"sort\": \"current\",\n            \"sortDesc\": true,\n            \"total\": false,\n            \"values\": true\n          },\n          \"lines\": true,\n          \"linewidth\": 2,\n          \"links\": [],\n          \"nullPointMode\": \"connected\",\n          \"options\": {\n            \"alertThreshold\": true\n          },\n          \"percentage\": false,\n          \"pluginVersion\": \"9.4.7\",\n          \"pointradius\": 5,\n          \"points\": false,\n          \"renderer\": \"flot\",\n          \"seriesOverrides\": [],\n          \"spaceLength\": 10,\n          \"stack\": false,\n          \"steppedLine\": true,\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (container_memory_working_set_bytes{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) by (pod_name)\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"legendFormat\": \"{{ pod_name }}\",\n              \"metric\": \"container_memory_usage:sort_desc\",\n              \"refId\": \"A\",\n              \"step\": 10\n            }\n          ],\n          \"thresholds\": [],\n          \"timeRegions\": [],\n          \"title\": \"Pods memory usage\",\n          \"tooltip\": {\n            \"msResolution\": false,\n            \"shared\": true,\n            \"sort\": 2,\n            \"value_type\": \"cumulative\"\n          },\n          \"type\": \"graph\",\n          \"xaxis\": {\n            \"mode\": \"time\",\n            \"show\": true,\n            \"values\": []\n          },\n          \"yaxes\": [\n            {\n              \"format\": \"bytes\",\n              \"logBase\": 1,\n              \"show\": true\n            },\n            {\n              \"format\": \"short\",\n              \"logBase\": 1,\n              \"show\": false\n            }\n          ],\n          \"yaxis\": {\n            \"align\": false\n          }\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 35\n          },\n          \"id\": 40,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 2,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 0,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 7,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 35\n              },\n              \"id\": 26,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": true,\n                \"show\": true,\n                \"sideWidth\": 200,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": true,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (container_memory_working_set_bytes{systemd_service_name!=\\\"\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) by (systemd_service_name)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"{{ systemd_service_name }}\",\n                  \"metric\": \"container_memory_usage:sort_desc\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"System services memory usage\",\n              \"tooltip\": {\n                \"msResolution\": false,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"bytes\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"System services memory usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 36\n          },\n          \"id\": 41,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 2,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 0,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 7,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 36\n              },\n              \"id\": 27,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": true,\n                \"show\": true,\n                \"sideWidth\": 200,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": true,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (container_memory_working_set_bytes{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",container_name!=\\\"POD\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) by (container_name, pod_name)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"pod: {{ pod_name }} | {{ container_name }}\",\n                  \"metric\": \"container_memory_usage:sort_desc\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (container_memory_working_set_bytes{image!=\\\"\\\",name!~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) by (kubernetes_io_hostname, name, image)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"docker: {{ kubernetes_io_hostname }} | {{ image }} ({{ name }})\",\n                  \"metric\": \"container_memory_usage:sort_desc\",\n                  \"refId\": \"B\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (container_memory_working_set_bytes{rkt_container_name!=\\\"\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) by (kubernetes_io_hostname, rkt_container_name)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"rkt: {{ kubernetes_io_hostname }} | {{ rkt_container_name }}\",\n                  \"metric\": \"container_memory_usage:sort_desc\",\n                  \"refId\": \"C\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"Containers memory usage\",\n              \"tooltip\": {\n                \"msResolution\": false,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"bytes\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Containers memory usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 37\n          },\n          \"id\": 42,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 2,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 0,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 14,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 37\n              },\n              \"id\": 28,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": false,\n                \"show\": true,\n                \"sideWidth\": 200,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": true,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (container_memory_working_set_bytes{id!=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}) by (id)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"{{ id }}\",\n                  \"metric\": \"container_memory_usage:sort_desc\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"All processes memory usage\",\n              \"tooltip\": {\n                \"msResolution\": false,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"bytes\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"All processes memory usage\",\n          \"type\": \"row\"\n        },\n        {\n          \"collapsed\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 38\n          },\n          \"id\": 43,\n          \"panels\": [],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Pods network I/O\",\n          \"type\": \"row\"\n        },\n        {\n          \"aliasColors\": {},\n          \"bars\": false,\n          \"dashLength\": 10,\n          \"dashes\": false,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"decimals\": 2,\n          \"editable\": true,\n          \"error\": false,\n          \"fill\": 1,\n          \"fillGradient\": 0,\n          \"grid\": {},\n          \"gridPos\": {\n            \"h\": 7,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 39\n          },\n          \"hiddenSeries\": false,\n          \"id\": 16,\n          \"isNew\": true,\n          \"legend\": {\n            \"alignAsTable\": true,\n            \"avg\": true,\n            \"current\": true,\n            \"max\": false,\n            \"min\": false,\n            \"rightSide\": true,\n            \"show\": true,\n            \"sideWidth\": 200,\n            \"sort\": \"current\",\n            \"sortDesc\": true,\n            \"total\": false,\n            \"values\": true\n          },\n          \"lines\": true,\n          \"linewidth\": 2,\n          \"links\": [],\n          \"nullPointMode\": \"connected\",\n          \"options\": {\n            \"alertThreshold\": true\n          },\n          \"percentage\": false,\n          \"pluginVersion\": \"9.4.7\",\n          \"pointradius\": 5,\n          \"points\": false,\n          \"renderer\": \"flot\",\n          \"seriesOverrides\": [],\n          \"spaceLength\": 10,\n          \"stack\": false,\n          \"steppedLine\": false,\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"sum (rate (container_network_receive_bytes_total{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (pod_name)\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"legendFormat\": \"-> {{ pod_name }}\",\n              \"metric\": \"network\",\n              \"refId\": \"A\",\n              \"step\": 10\n            },\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"expr\": \"- sum (rate (container_network_transmit_bytes_total{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (pod_name)\",\n              \"interval\": \"10s\",\n              \"intervalFactor\": 1,\n              \"legendFormat\": \"<- {{ pod_name }}\",\n              \"metric\": \"network\",\n              \"refId\": \"B\",\n              \"step\": 10\n            }\n          ],\n          \"thresholds\": [],\n          \"timeRegions\": [],\n          \"title\": \"Pods network I/O (1m avg)\",\n          \"tooltip\": {\n            \"msResolution\": false,\n            \"shared\": true,\n            \"sort\": 2,\n            \"value_type\": \"cumulative\"\n          },\n          \"type\": \"graph\",\n          \"xaxis\": {\n            \"mode\": \"time\",\n            \"show\": true,\n            \"values\": []\n          },\n          \"yaxes\": [\n            {\n              \"format\": \"Bps\",\n              \"logBase\": 1,\n              \"show\": true\n            },\n            {\n              \"format\": \"short\",\n              \"logBase\": 1,\n              \"show\": false\n            }\n          ],\n          \"yaxis\": {\n            \"align\": false\n          }\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 46\n          },\n          \"id\": 44,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 2,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 1,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 7,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 46\n              },\n              \"id\": 30,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": true,\n                \"show\": true,\n                \"sideWidth\": 200,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": false,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_network_receive_bytes_total{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (container_name, pod_name)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"-> pod: {{ pod_name }} | {{ container_name }}\",\n                  \"metric\": \"network\",\n                  \"refId\": \"B\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"- sum (rate (container_network_transmit_bytes_total{image!=\\\"\\\",name=~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (container_name, pod_name)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"<- pod: {{ pod_name }} | {{ container_name }}\",\n                  \"metric\": \"network\",\n                  \"refId\": \"D\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_network_receive_bytes_total{image!=\\\"\\\",name!~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (kubernetes_io_hostname, name, image)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"-> docker: {{ kubernetes_io_hostname }} | {{ image }} ({{ name }})\",\n                  \"metric\": \"network\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"- sum (rate (container_network_transmit_bytes_total{image!=\\\"\\\",name!~\\\"^k8s_.*\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (kubernetes_io_hostname, name, image)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"<- docker: {{ kubernetes_io_hostname }} | {{ image }} ({{ name }})\",\n                  \"metric\": \"network\",\n                  \"refId\": \"C\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_network_transmit_bytes_total{rkt_container_name!=\\\"\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (kubernetes_io_hostname, rkt_container_name)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"-> rkt: {{ kubernetes_io_hostname }} | {{ rkt_container_name }}\",\n                  \"metric\": \"network\",\n                  \"refId\": \"E\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"- sum (rate (container_network_transmit_bytes_total{rkt_container_name!=\\\"\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (kubernetes_io_hostname, rkt_container_name)\",\n                  \"hide\": false,\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"<- rkt: {{ kubernetes_io_hostname }} | {{ rkt_container_name }}\",\n                  \"metric\": \"network\",\n                  \"refId\": \"F\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"Containers network I/O (1m avg)\",\n              \"tooltip\": {\n                \"msResolution\": false,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"Bps\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"Containers network I/O\",\n          \"type\": \"row\"\n        },\n        {\n          \"collapsed\": true,\n          \"datasource\": {\n            \"type\": \"prometheus\",\n            \"uid\": \"prometheusuid\"\n          },\n          \"gridPos\": {\n            \"h\": 1,\n            \"w\": 24,\n            \"x\": 0,\n            \"y\": 47\n          },\n          \"id\": 45,\n          \"panels\": [\n            {\n              \"aliasColors\": {},\n              \"bars\": false,\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"decimals\": 2,\n              \"editable\": true,\n              \"error\": false,\n              \"fill\": 1,\n              \"grid\": {},\n              \"gridPos\": {\n                \"h\": 14,\n                \"w\": 24,\n                \"x\": 0,\n                \"y\": 47\n              },\n              \"id\": 29,\n              \"isNew\": true,\n              \"legend\": {\n                \"alignAsTable\": true,\n                \"avg\": true,\n                \"current\": true,\n                \"max\": false,\n                \"min\": false,\n                \"rightSide\": false,\n                \"show\": true,\n                \"sideWidth\": 200,\n                \"sort\": \"current\",\n                \"sortDesc\": true,\n                \"total\": false,\n                \"values\": true\n              },\n              \"lines\": true,\n              \"linewidth\": 2,\n              \"links\": [],\n              \"nullPointMode\": \"connected\",\n              \"percentage\": false,\n              \"pointradius\": 5,\n              \"points\": false,\n              \"renderer\": \"flot\",\n              \"seriesOverrides\": [],\n              \"stack\": false,\n              \"steppedLine\": false,\n              \"targets\": [\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"sum (rate (container_network_receive_bytes_total{id!=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (id)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"-> {{ id }}\",\n                  \"metric\": \"network\",\n                  \"refId\": \"A\",\n                  \"step\": 10\n                },\n                {\n                  \"datasource\": {\n                    \"type\": \"prometheus\",\n                    \"uid\": \"prometheusuid\"\n                  },\n                  \"expr\": \"- sum (rate (container_network_transmit_bytes_total{id!=\\\"/\\\",kubernetes_io_hostname=~\\\"^$Node$\\\"}[1m])) by (id)\",\n                  \"interval\": \"10s\",\n                  \"intervalFactor\": 1,\n                  \"legendFormat\": \"<- {{ id }}\",\n                  \"metric\": \"network\",\n                  \"refId\": \"B\",\n                  \"step\": 10\n                }\n              ],\n              \"thresholds\": [],\n              \"title\": \"All processes network I/O (1m avg)\",\n              \"tooltip\": {\n                \"msResolution\": false,\n                \"shared\": true,\n                \"sort\": 2,\n                \"value_type\": \"cumulative\"\n              },\n              \"type\": \"graph\",\n              \"xaxis\": {\n                \"show\": true\n              },\n              \"yaxes\": [\n                {\n                  \"format\": \"Bps\",\n                  \"logBase\": 1,\n                  \"show\": true\n                },\n                {\n                  \"format\": \"short\",\n                  \"logBase\": 1,\n                  \"show\": false\n                }\n              ]\n            }\n          ],\n          \"targets\": [\n            {\n              \"datasource\": {\n                \"type\": \"prometheus\",\n                \"uid\": \"prometheusuid\"\n              },\n              \"refId\": \"A\"\n            }\n          ],\n          \"title\": \"All processes network I/O\",\n          \"type\": \"row\"\n        }\n      ],\n      \"refresh\": \"10s\",\n      \"revision\": 1,\n      \"schemaVersion\": 38,\n      \"style\": \"dark\",\n      \"tags\": [\n        \"kubernetes\"\n      ],\n      \"templating\": {\n        \"list\": [\n          {\n            \"allValue\": \".*\",\n            \"current\": {\n              \"selected\": false,\n              \"text\": \"All\",\n              \"value\": \"$__all\"\n            },\n            \"datasource\": {\n              \"type\": \"prometheus\",\n              \"uid\": \"prometheusuid\"\n            },\n            \"definition\": \"\",\n            \"hide\": 0,\n            \"includeAll\": true,\n            \"multi\": false,\n            \"name\": \"Node\",\n            \"options\": [],\n            \"query\": {\n              \"query\": \"label_values(kubernetes_io_hostname)\",\n              \"refId\": \"Prometheus-Node-Variable-Query\"\n            },\n            \"refresh\": 1,\n            \"regex\": \"\",\n            \"skipUrlSync\": false,\n            \"sort\": 0,\n            \"type\": \"query\"\n          }\n        ]\n      },\n      \"time\": {\n        \"from\": \"now-5m\",\n        \"to\": \"now\"\n      },\n      \"timepicker\": {\n        \"refresh_intervals\": [\n          \"5s\",\n          \"10s\",\n          \"30s\",\n          \"1m\",\n          \"5m\",\n          \"15m\",\n          \"30m\",\n          \"1h\",\n          \"2h\",\n          \"1d\"\n        ],\n        \"time_options\": [\n          \"5m\",\n          \"15m\",\n          \"1h\",\n          \"6h\",\n          \"12h\",\n          \"24h\",\n          \"2d\",\n          \"7d\",\n          \"30d\"\n        ]\n      },\n      \"timezone\": \"browser\",\n      \"title\": \"Kubernetes cluster monitoring (via Prometheus)\",\n      \"uid\": \"nMnqQpEVk\",\n      \"version\": 2,\n      \"weekStart\": \"\"\n    }\nkind: ConfigMap\nmetadata:\n  name: grafana-dashboard\n  namespace: default\nEOF",
+		Command: cl.NewCmd("curl", "-fsSL", cl.CalicoManifestURL(version)).Pipe(
+			cl.CalicoPodCIDR(podCIDR),
+			cl.NewCmd("kubectl", "apply", "-f", "-"),
+		).Command(),
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-func (u *Ubuntu2004CommandLib) AddGrafanaDatasourceSecret() cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) InstallCiliumCLI() cl.CommandAndParser {
 	return cl.CommandAndParser{
-		Command:   "kubectl apply -f - <<EOF\napiVersion: v1\ndata:\n  datasource-secret.yaml: YXBpVmVyc2lvbjogMQoKZGF0YXNvdXJjZXM6CiAgLSBuYW1lOiBQcm9tZXRoZXVzCiAgICB1aWQ6IHByb21ldGhldXN1aWQKICAgIHR5cGU6IHByb21ldGhldXMKICAgICMgQWNjZXNzIG1vZGUgLSBwcm94eSAoc2VydmVyIGluIHRoZSBVSSkgb3IgZGlyZWN0IChicm93c2VyIGluIHRoZSBVSSkuCiAgICBhY2Nlc3M6IHByb3h5CiAgICB1cmw6IGh0dHA6Ly9wcm9tZXRoZXVzLWt1YmUtcHJvbWV0aGV1cy1wcm9tZXRoZXVzOjkwOTAK\nkind: Secret\nmetadata:\n  name: datasource-secret\n  namespace: default\ntype: Opaque\nEOF",
+		Command:   "CILIUM_CLI_VERSION=$(curl -fsSL https://raw.githubusercontent.com/cilium/cilium-cli/main/stable.txt)\ncurl -fsSL https://github.com/cilium/cilium-cli/releases/download/${CILIUM_CLI_VERSION}/cilium-linux-amd64.tar.gz | sudo tar xzvfC - /usr/local/bin",
 		Parser:    nil,
 		Condition: cl.Required,
 	}
 }
 
-// Join cluster
+// AddCilium installs Cilium of the version allocating pod addresses from podCIDR
+func (u *Ubuntu2004CommandLib) AddCilium(version, podCIDR string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("cilium", "install", "--version", version, "--set", "ipam.mode=cluster-pool", "--set", "ipam.operator.clusterPoolIPv4PodCIDRList="+podCIDR).Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+func (u *Ubuntu2004CommandLib) CreateFolderForPV(path string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("mkdir", "-p", path).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
 
-func (u *Ubuntu2004CommandLib) KubeadmJoin(ip netip.AddrPort, token, tokenHash string) cl.CommandAndParser {
+func (u *Ubuntu2004CommandLib) AddPostgresPV(hostname string, number int) cl.CommandAndParser {
+	return kubectlApply(fmt.Sprintf("pv-%d.yaml", number), fmt.Sprintf("apiVersion: v1\nkind: PersistentVolume\nmetadata:\n  name: pv-%d\n  labels:\n    type: local\nspec:\n  capacity:\n    storage: 1Gi\n  volumeMode: Filesystem\n  accessModes:\n  - ReadWriteOnce\n  persistentVolumeReclaimPolicy: Retain\n  storageClassName: local-storage\n  local:\n    path: /devkube/postgresql\n  nodeAffinity:\n    required:\n      nodeSelectorTerms:\n      - matchExpressions:\n        - key: kubernetes.io/hostname\n          operator: In\n          values:\n          - %q\n", number, hostname))
+}
 
-	cp := cl.CommandAndParser{
-		Command:   "sudo kubeadm join",
+func (u *Ubuntu2004CommandLib) ResetGrafanaAdminPassword(password string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command: cl.NewCmd("kubectl", "exec", "--namespace", "default", "-it").
+			Raw(`$(kubectl get pods --namespace default -lapp.kubernetes.io/name=grafana -o jsonpath="{.items[0].metadata.name}")`).
+			Arg("grafana-cli", "admin", "reset-admin-password", password).Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+		Secrets:   []string{password},
+	}
+}
+
+// Join cluster
+
+// KubeadmJoin joins node to cluster as worker or as master depending on kubeadm config file
+func (u *Ubuntu2004CommandLib) KubeadmJoin(configPath string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("kubeadm", "join").Flag("config", configPath).Sudo().Command(),
 		Parser:    nil,
 		Condition: 0,
 	}
-	cp = cp.WithArgs(ip.String(), "--token", token, "--discovery-token-ca-cert-hash", tokenHash)
-	return cp
+}
+
+// WriteFile uploads file with content readable according to mode, parent directories are created too
+func (u *Ubuntu2004CommandLib) WriteFile(path, content string, mode os.FileMode) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "",
+		Parser:    nil,
+		Condition: cl.Required,
+		File:      &cl.File{Path: path, Content: []byte(content), Mode: mode},
+	}
+}
+
+func (u *Ubuntu2004CommandLib) RemoveFiles(paths ...string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("rm", "-rf").Arg(paths...).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
 }
 
 // Reset Kubeadm
@@ -277,20 +471,35 @@ func (u *Ubuntu2004CommandLib) KubeadmReset() cl.CommandAndParser {
 	return cp
 }
 
+// StopKubelet stops kubelet, node without kubelet has nothing to stop, so unit which isn't loaded is success
 func (u *Ubuntu2004CommandLib) StopKubelet() cl.CommandAndParser {
 	cp := cl.CommandAndParser{
-		Command:   "sudo systemctl stop kubelet",
-		Parser:    nil,
-		Condition: cl.Anyway,
+		Command:      "sudo systemctl stop kubelet",
+		Parser:       nil,
+		Condition:    cl.Required,
+		SuccessCodes: []cl.Code{cl.UnitNotLoaded},
 	}
 	return cp
 }
 
+// StopCRIO stops CRI-O, it isn't installed on nodes with containerd and unit which isn't loaded is success
 func (u *Ubuntu2004CommandLib) StopCRIO() cl.CommandAndParser {
 	cp := cl.CommandAndParser{
-		Command:   "sudo systemctl stop crio.service",
-		Parser:    nil,
-		Condition: cl.Anyway,
+		Command:      "sudo systemctl stop crio.service",
+		Parser:       nil,
+		Condition:    cl.Required,
+		SuccessCodes: []cl.Code{cl.UnitNotLoaded},
+	}
+	return cp
+}
+
+// StopContainerd stops containerd, it isn't installed on nodes with CRI-O and unit which isn't loaded is success
+func (u *Ubuntu2004CommandLib) StopContainerd() cl.CommandAndParser {
+	cp := cl.CommandAndParser{
+		Command:      "sudo systemctl stop containerd.service",
+		Parser:       nil,
+		Condition:    cl.Required,
+		SuccessCodes: []cl.Code{cl.UnitNotLoaded},
 	}
 	return cp
 }
@@ -331,6 +540,90 @@ func (u *Ubuntu2004CommandLib) BrctlDelbr() cl.CommandAndParser {
 	return cp
 }
 
+func (u *Ubuntu2004CommandLib) Hostname() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "hostname",
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// DeleteFlannelLinks deletes links of Flannel, links which don't exist are skipped
+func (u *Ubuntu2004CommandLib) DeleteFlannelLinks() cl.CommandAndParser {
+	cp := cl.CommandAndParser{
+		Command:   "sudo ip link delete cni0 || true\nsudo ip link delete flannel.1 || true",
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+	return cp
+}
+
+// DeleteCalicoLinks deletes links of Calico, links which don't exist are skipped
+func (u *Ubuntu2004CommandLib) DeleteCalicoLinks() cl.CommandAndParser {
+	cp := cl.CommandAndParser{
+		Command:   "sudo ip link set tunl0 down || true\nsudo ip link delete vxlan.calico || true\nfor link in $(ip -o link show | awk -F': ' '{print $2}' | cut -d@ -f1 | grep '^cali'); do sudo ip link delete $link; done",
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+	return cp
+}
+
+// DeleteCiliumLinks deletes links of Cilium, links which don't exist are skipped
+func (u *Ubuntu2004CommandLib) DeleteCiliumLinks() cl.CommandAndParser {
+	cp := cl.CommandAndParser{
+		Command:   "sudo ip link delete cilium_host || true\nsudo ip link delete cilium_vxlan || true",
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+	return cp
+}
+
+// DeleteIptablesRules removes iptables rules and chains which names contain pattern
+func (u *Ubuntu2004CommandLib) DeleteIptablesRules(pattern string) cl.CommandAndParser {
+	cp := cl.CommandAndParser{
+		Command:   cl.NewCmd("iptables-save").Sudo().Pipe(cl.NewCmd("grep", "-iv", pattern), cl.NewCmd("iptables-restore").Sudo()).Command(),
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+	return cp
+}
+
+// RemoveCNIState removes CNI configs and state directories which kubeadm reset leaves on the node
+func (u *Ubuntu2004CommandLib) RemoveCNIState(dirs ...string) cl.CommandAndParser {
+	cp := cl.CommandAndParser{
+		Command:   cl.NewCmd("rm", "-rf", "/etc/cni/net.d").Arg(dirs...).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Anyway,
+	}
+	return cp
+}
+
+// ListKubeadmTokens prints every bootstrap token of the cluster as separate JSON object
+func (u *Ubuntu2004CommandLib) ListKubeadmTokens() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "sudo kubeadm token list -o json",
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+func (u *Ubuntu2004CommandLib) CreateKubeadmToken() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "sudo kubeadm token create --print-join-command",
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+func (u *Ubuntu2004CommandLib) DeleteKubeadmToken(token string) cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("kubeadm", "token", "delete", token).Sudo().Command(),
+		Parser:    nil,
+		Condition: cl.Anyway,
+		Secrets:   []string{token},
+	}
+}
+
 func (u *Ubuntu2004CommandLib) CatAdminConfFile() cl.CommandAndParser {
 	return cl.CommandAndParser{
 		Command:   cl.Command("sudo cat /etc/kubernetes/admin.conf"),
@@ -338,3 +631,36 @@ func (u *Ubuntu2004CommandLib) CatAdminConfFile() cl.CommandAndParser {
 		Condition: cl.Required,
 	}
 }
+
+// kubectlApply uploads manifest to manifestsDir and applies it, values of the manifest have to be quoted YAML scalars
+func kubectlApply(name, manifest string) cl.CommandAndParser {
+	path := manifestsDir + "/" + name
+	return cl.CommandAndParser{
+		Command:   cl.NewCmd("kubectl", "apply", "-f", path).Command(),
+		Parser:    nil,
+		Condition: cl.Required,
+		File:      &cl.File{Path: path, Content: []byte(manifest), Mode: 0644},
+	}
+}
+
+// kubeadmUpgradeTarget sets $version to version of installed kubeadm and fails unless it is version like "1.31.2"
+// or a patch of minor version like "1.31". Control plane isn't upgraded to the version it runs already then,
+// when kubeadm package of the target version isn't installed
+func kubeadmUpgradeTarget(version string) string {
+	pattern := "v" + version
+	if strings.Count(version, ".") == 1 {
+		pattern += ".*"
+	}
+	return fmt.Sprintf(`version="$(kubeadm version -o short)" && case "$version" in %s) ;; *) echo "kubeadm $version isn't of version %s" >&2; exit 1 ;; esac`, pattern, version)
+}
+
+// aptKey downloads signing key of apt repository to keyring
+func aptKey(url, keyring string) cl.Command {
+	return cl.Command(cl.NewCmd("mkdir", "-p", "-m", "755", "/etc/apt/keyrings").Sudo().String() + "\n" +
+		cl.NewCmd("curl", "-fsSL", url).Pipe(cl.NewCmd("gpg", "--dearmor", "--yes", "-o", keyring).Sudo()).String())
+}
+
+// aptSource writes apt source list of flat repository signed by keyring
+func aptSource(keyring, url, path string) cl.Command {
+	return cl.NewCmd("echo", fmt.Sprintf("deb [signed-by=%s] %s /", keyring, url)).Pipe(cl.NewCmd("tee", path).Sudo()).Command()
+}
@@ -0,0 +1,29 @@
+package ubuntu
+
+import (
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+// Ubuntu2204CommandLib builds commands for Ubuntu 22.04 and 24.04. These releases boot with unified cgroup v2
+// hierarchy, so kubelet and container runtime have to use systemd cgroup driver. Repositories are added with
+// signed-by keyrings like on Ubuntu 20.04, the other commands are inherited from it
+type Ubuntu2204CommandLib struct {
+	Ubuntu2004CommandLib
+}
+
+var _ cl.CommandLib = (*Ubuntu2204CommandLib)(nil)
+
+// InstallUtils doesn't install apt-transport-https, apt supports https itself since 1.5
+func (u *Ubuntu2204CommandLib) InstallUtils() cl.CommandAndParser {
+	return cl.CommandAndParser{
+		Command:   "sudo apt-get install -y ca-certificates curl gpg",
+		Parser:    nil,
+		Condition: cl.Required,
+	}
+}
+
+// CgroupDrivers of cgroup v2 releases contain only systemd, it manages the unified hierarchy and cgroupfs driver
+// would make the second cgroup manager on the node
+func (u *Ubuntu2204CommandLib) CgroupDrivers() []string {
+	return []string{"systemd"}
+}
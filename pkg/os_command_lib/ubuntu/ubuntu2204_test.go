@@ -0,0 +1,227 @@
+package ubuntu
+
+import (
+	"reflect"
+	"testing"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+	"github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib/cltest"
+)
+
+func TestUbuntu2204Commands(t *testing.T) {
+	u := &Ubuntu2204CommandLib{}
+
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "utils",
+			command: u.InstallUtils(),
+			want:    []string{"apt-get install -y ca-certificates curl gpg"},
+			notWant: []string{"apt-transport-https"},
+		},
+		{
+			name:    "kubernetes signing key",
+			command: u.DownloadK8SSigningKey("v1.30"),
+			want:    []string{"https://pkgs.k8s.io/core:/stable:/v1.30/deb/Release.key", "gpg --dearmor --yes -o /etc/apt/keyrings/kubernetes-apt-keyring.gpg"},
+			notWant: []string{"apt-key add"},
+		},
+		{
+			name:    "kubernetes repo",
+			command: u.AddK8SRepo("v1.30"),
+			want:    []string{"deb [signed-by=/etc/apt/keyrings/kubernetes-apt-keyring.gpg] https://pkgs.k8s.io/core:/stable:/v1.30/deb/ /"},
+			notWant: []string{"xenial", "apt.kubernetes.io"},
+		},
+		{
+			name:    "cri-o signing key",
+			command: u.ImportGPGKey("v1.30"),
+			want:    []string{"https://pkgs.k8s.io/addons:/cri-o:/stable:/v1.30/deb/Release.key", "/etc/apt/keyrings/cri-o-apt-keyring.gpg"},
+			notWant: []string{"apt-key add"},
+		},
+		{
+			name:    "cri-o repo",
+			command: u.AddCRIORepos("v1.30"),
+			want:    []string{"deb [signed-by=/etc/apt/keyrings/cri-o-apt-keyring.gpg] https://pkgs.k8s.io/addons:/cri-o:/stable:/v1.30/deb/ /"},
+			notWant: []string{"xUbuntu", "opensuse.org"},
+		},
+		{
+			name:    "cri-o systemd cgroup manager",
+			command: u.SetCRIOCgroupManager("systemd"),
+			want:    []string{`cgroup_manager = "systemd"`, `conmon_cgroup = "system.slice"`},
+		},
+		{
+			name:    "containerd",
+			command: u.InstallContainerd(),
+			want:    []string{"apt-get install -y containerd", "apt-mark hold containerd"},
+		},
+		{
+			name:    "download packages",
+			command: u.DownloadPackages("/tmp/bundle/packages", "1.30.*"),
+			want:    []string{"apt-get install -y --download-only --reinstall -o Dir::Cache::archives=/tmp/bundle/packages 'kubelet=1.30.*'", "containerd", "rm -rf /tmp/bundle/packages/partial"},
+		},
+		{
+			name:    "containerd systemd cgroup driver",
+			command: u.SetContainerdCgroupDriver("systemd"),
+			want:    []string{"containerd config default", "SystemdCgroup = true", "/etc/containerd/config.toml"},
+		},
+		{
+			name:    "kernel modules",
+			command: u.SetModprobe(),
+			want:    []string{"/etc/modules-load.d/kubernetes.conf", "overlay\nbr_netfilter\n", "modprobe overlay", "modprobe br_netfilter"},
+		},
+		{
+			name:    "swap",
+			command: u.DisableSWAP(),
+			want:    []string{"swapoff -a", "/etc/fstab"},
+		},
+		{
+			name:    "sysctl",
+			command: u.SetIpForward(),
+			want:    []string{"/etc/sysctl.d/99-kubernetes.conf", "net.bridge.bridge-nf-call-ip6tables = 1", "sysctl --system"},
+			notWant: []string{"tee -a /proc/sys"},
+		},
+		{
+			name:    "verify node settings",
+			command: u.VerifyNodeSettings(),
+			want:    []string{"/proc/swaps", "/etc/fstab", "/sys/module/br_netfilter", "/etc/modules-load.d/kubernetes.conf", "/proc/sys/net/ipv4/ip_forward", "/etc/sysctl.d/99-kubernetes.conf"},
+			notWant: []string{"sudo"},
+		},
+	}
+	for _, tt := range tests {
+		cltest.AssertContains(t, tt.name, tt.command.String(), tt.want, tt.notWant)
+		if tt.command.Condition != cl.Required {
+			t.Errorf("%s: condition = %s, want %s", tt.name, tt.command.Condition, cl.Required)
+		}
+	}
+}
+
+func TestStopUnits(t *testing.T) {
+	u := &Ubuntu2204CommandLib{}
+	for _, command := range []cl.CommandAndParser{u.StopKubelet(), u.StopCRIO(), u.StopContainerd()} {
+		if command.Condition != cl.Required {
+			t.Errorf("%s: condition = %s, want %s", command.Command, command.Condition, cl.Required)
+		}
+		if !command.Succeeded(cl.UnitNotLoaded) {
+			t.Errorf("%s: unit which isn't loaded isn't success", command.Command)
+		}
+		if command.Succeeded(1) {
+			t.Errorf("%s: failed stop is success", command.Command)
+		}
+	}
+}
+
+func TestCgroupDrivers(t *testing.T) {
+	tests := []struct {
+		name       string
+		commandLib cl.CommandLib
+		want       []string
+	}{
+		{name: "ubuntu 20.04", commandLib: &Ubuntu2004CommandLib{}, want: []string{"systemd", "cgroupfs"}},
+		{name: "ubuntu 22.04", commandLib: &Ubuntu2204CommandLib{}, want: []string{"systemd"}},
+	}
+	for _, tt := range tests {
+		if got := tt.commandLib.CgroupDrivers(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: cgroup drivers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuotedValues(t *testing.T) {
+	u := &Ubuntu2004CommandLib{}
+
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		want    []string
+	}{
+		{
+			name:    "postgres pv",
+			command: u.AddPostgresPV("node\nEOF\n$(reboot)", 2),
+			want:    []string{"upload /var/lib/paas/manifests/pv-2.yaml mode 0644\n", "name: pv-2", `- "node\nEOF\n$(reboot)"`, "\nkubectl apply -f /var/lib/paas/manifests/pv-2.yaml"},
+		},
+		{
+			name:    "grafana password",
+			command: u.ResetGrafanaAdminPassword("p@ss'; reboot"),
+			want:    []string{`reset-admin-password 'p@ss'\''; reboot'`},
+		},
+		{
+			name:    "write file",
+			command: u.WriteFile("/etc/app/a b.conf", "EOF\nkey = $value", 0600),
+			want:    []string{"upload /etc/app/a b.conf mode 0600\nEOF\nkey = $value\n"},
+		},
+		{
+			name:    "iptables pattern",
+			command: u.DeleteIptablesRules("cali' | reboot #"),
+			want:    []string{`grep -iv 'cali'\'' | reboot #' | sudo iptables-restore`},
+		},
+	}
+	for _, tt := range tests {
+		cltest.AssertContains(t, tt.name, tt.command.String(), tt.want, nil)
+	}
+}
+
+// TestInstallExitCode checks that failed apt-get install isn't hidden by apt-mark hold run after it
+func TestInstallExitCode(t *testing.T) {
+	u := &Ubuntu2204CommandLib{}
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		aptGet  int
+		want    int
+	}{
+		{name: "cri-o", command: u.InstallCRIO("1.30.*"), want: 0},
+		{name: "cri-o install failed", command: u.InstallCRIO("1.30.*"), aptGet: 100, want: 100},
+		{name: "kubeadm", command: u.InstallKubeadm("1.30.*"), want: 0},
+		{name: "kubeadm install failed", command: u.InstallKubeadm("1.30.*"), aptGet: 100, want: 100},
+	}
+	for _, tt := range tests {
+		code := cltest.ExitCode(t, string(tt.command.Command), map[string]int{"apt-get": tt.aptGet, "apt-mark": 0})
+		if code != tt.want {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+// TestUpgradeExitCode checks that failed package install fails upgrade step and that control plane isn't upgraded
+// by kubeadm of other version than the target one
+func TestUpgradeExitCode(t *testing.T) {
+	u := &Ubuntu2204CommandLib{}
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		stubs   map[string]int
+		want    int
+	}{
+		{name: "kubeadm", command: u.UpgradeKubeadm("1.31.*"), stubs: map[string]int{"apt-get": 0, "apt-mark": 0}, want: 0},
+		{name: "kubeadm install failed", command: u.UpgradeKubeadm("1.31.*"), stubs: map[string]int{"apt-get": 100, "apt-mark": 0}, want: 100},
+		{name: "kubelet", command: u.UpgradeKubelet("1.31.*"), stubs: map[string]int{"apt-get": 0, "apt-mark": 0, "systemctl": 0}, want: 0},
+		{name: "kubelet install failed", command: u.UpgradeKubelet("1.31.*"), stubs: map[string]int{"apt-get": 100, "apt-mark": 0, "systemctl": 0}, want: 100},
+		{name: "kubelet restart failed", command: u.UpgradeKubelet("1.31.*"), stubs: map[string]int{"apt-get": 0, "apt-mark": 0, "systemctl": 1}, want: 1},
+		// kubeadm stub prints no version, so it isn't of the target one
+		{name: "plan by other kubeadm", command: u.KubeadmUpgradePlan("1.31"), stubs: map[string]int{"kubeadm": 0}, want: 1},
+		{name: "apply by other kubeadm", command: u.KubeadmUpgradeApply("1.31.2"), stubs: map[string]int{"kubeadm": 0}, want: 1},
+	}
+	for _, tt := range tests {
+		if code := cltest.ExitCode(t, string(tt.command.Command), tt.stubs); code != tt.want {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestKubeadmUpgradeTarget(t *testing.T) {
+	u := &Ubuntu2204CommandLib{}
+	tests := []struct {
+		name    string
+		command cl.CommandAndParser
+		want    []string
+	}{
+		{name: "plan of minor version", command: u.KubeadmUpgradePlan("1.31"), want: []string{`case "$version" in v1.31.*)`, `&& sudo kubeadm upgrade plan "$version"`}},
+		{name: "apply of patch version", command: u.KubeadmUpgradeApply("1.31.2"), want: []string{`case "$version" in v1.31.2)`, `&& sudo kubeadm upgrade apply -y "$version"`}},
+	}
+	for _, tt := range tests {
+		cltest.AssertContains(t, tt.name, tt.command.String(), tt.want, nil)
+	}
+}
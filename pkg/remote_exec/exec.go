@@ -0,0 +1,85 @@
+package remote_exec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	cc "github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+	sshcc "github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn/ssh"
+	"golang.org/x/crypto/ssh"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+// Run runs command on conn and copies its stdout and stderr to the given writers while the command is still running.
+// Connections which can't stream get their whole output written to stdout after the command finishes, their
+// result has combined output as stdout. Result has exit code of failed command, error is returned like
+// ClientConn.Exec does. Command is run by cl.Strict, so its failed line or pipeline stage fails the whole command
+func Run(conn cc.ClientConn, command string, stdout, stderr io.Writer) (cl.Result, error) {
+	start := time.Now()
+	command = cl.Strict(command)
+	sshConn, ok := conn.(*sshcc.SSH)
+	if !ok {
+		output, err := conn.Exec(command)
+		if stdout != nil {
+			_, _ = stdout.Write(output)
+		}
+		return cl.Result{ExitCode: exitCode(err), Stdout: output, Duration: time.Since(start)}, err
+	}
+
+	session, err := sshConn.C.NewSession()
+	if err != nil {
+		result := cl.Result{ExitCode: cl.NoExitCode, Duration: time.Since(start)}
+		var openChannelErrTarget *ssh.OpenChannelError
+		if errors.As(err, &openChannelErrTarget) {
+			return result, errors.Join(cc.ErrOpenChannel, err)
+		}
+		return result, errors.Join(cc.ErrUnknown, err)
+	}
+	defer func(session *ssh.Session) {
+		_ = session.Close()
+	}(session)
+
+	// every buffer is written by its own copying goroutine of ssh session
+	var stdoutBuf, stderrBuf bytes.Buffer
+	session.Stdout = teeWriter(&stdoutBuf, stdout)
+	session.Stderr = teeWriter(&stderrBuf, stderr)
+
+	err = session.Run(command)
+	result := cl.Result{ExitCode: exitCode(err), Stdout: stdoutBuf.Bytes(), Stderr: stderrBuf.Bytes(), Duration: time.Since(start)}
+	if err != nil {
+		var exitMissingErrTarget *ssh.ExitMissingError
+		if errors.As(err, &exitMissingErrTarget) {
+			return result, errors.Join(cc.ErrExitStatusMissing, err)
+		}
+
+		var exitErrTarget *ssh.ExitError
+		if errors.As(err, &exitErrTarget) {
+			return result, errors.Join(cc.ErrExitStatus, err)
+		}
+
+		return result, errors.Join(cc.ErrUnknown, err)
+	}
+	return result, nil
+}
+
+// exitCode returns exit status carried by error of ssh session
+func exitCode(err error) cl.Code {
+	if err == nil {
+		return cl.Success
+	}
+	var exitErrTarget *ssh.ExitError
+	if errors.As(err, &exitErrTarget) {
+		return cl.Code(exitErrTarget.ExitStatus())
+	}
+	return cl.NoExitCode
+}
+
+func teeWriter(buf io.Writer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
+}
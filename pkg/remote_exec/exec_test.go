@@ -0,0 +1,92 @@
+package remote_exec
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+
+	cc "github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+// execConn is connection which can't stream, Exec returns its fields
+type execConn struct {
+	output []byte
+	err    error
+}
+
+func (c *execConn) Exec(string) ([]byte, error) {
+	return c.output, c.err
+}
+
+func (c *execConn) Close() error {
+	return nil
+}
+
+// shellConn runs commands by local shell like ssh server runs them on node
+type shellConn struct {
+	sh string
+}
+
+func (c *shellConn) Exec(command string) ([]byte, error) {
+	return exec.Command(c.sh, "-c", command).CombinedOutput()
+}
+
+func (c *shellConn) Close() error {
+	return nil
+}
+
+func TestRunWithoutStreaming(t *testing.T) {
+	tests := []struct {
+		name string
+		conn *execConn
+		code cl.Code
+	}{
+		{name: "success", conn: &execConn{output: []byte("ok\n")}, code: cl.Success},
+		{name: "connection failure", conn: &execConn{err: errors.Join(cc.ErrOpenChannel, errors.New("channel rejected"))}, code: cl.NoExitCode},
+	}
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		result, err := Run(tt.conn, "true", &stdout, nil)
+		if !errors.Is(err, tt.conn.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.conn.err)
+		}
+		if result.ExitCode != tt.code {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, result.ExitCode, tt.code)
+		}
+		if !bytes.Equal(result.Stdout, tt.conn.output) || !bytes.Equal(stdout.Bytes(), tt.conn.output) {
+			t.Errorf("%s: stdout = %q, streamed %q, want %q", tt.name, result.Stdout, stdout.Bytes(), tt.conn.output)
+		}
+	}
+}
+
+func TestRunStopsAtFailedLine(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh isn't found")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't found")
+	}
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		wantErr bool
+	}{
+		{name: "all lines succeed", command: "echo first\necho second", want: "first\nsecond\n"},
+		{name: "failed line", command: "echo first\nfalse\necho second", want: "first\n", wantErr: true},
+		{name: "failed pipeline stage", command: "false | cat\necho second", want: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		result, err := Run(&shellConn{sh: sh}, tt.command, nil, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %t", tt.name, err, tt.wantErr)
+		}
+		if string(result.Stdout) != tt.want {
+			t.Errorf("%s: stdout = %q, want %q", tt.name, result.Stdout, tt.want)
+		}
+	}
+}
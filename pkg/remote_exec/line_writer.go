@@ -0,0 +1,44 @@
+package remote_exec
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LineWriter splits written data into lines and passes every completed line to the callback
+// without the trailing newline. Call Flush after the command finishes to get the last unterminated line.
+type LineWriter struct {
+	buf    []byte
+	mu     sync.Mutex
+	onLine func(line string)
+}
+
+func NewLineWriter(onLine func(line string)) *LineWriter {
+	return &LineWriter{onLine: onLine}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.onLine(string(bytes.TrimSuffix(w.buf[:i], []byte("\r"))))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) != 0 {
+		w.onLine(string(w.buf))
+		w.buf = nil
+	}
+}
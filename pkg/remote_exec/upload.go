@@ -0,0 +1,129 @@
+package remote_exec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	cc "github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn"
+	sshcc "github.com/Killer-Feature/PaaS_ServerSide/pkg/client_conn/ssh"
+	"github.com/pkg/sftp"
+
+	cl "github.com/Killer-Feature/PaaS_ClientSide/pkg/os_command_lib"
+)
+
+// sftpServerCommand runs sftp-server of OpenSSH as root, so files are written to any path without temporary copies.
+// Debian family and RHEL family install it to different directories
+const sftpServerCommand = `for p in /usr/lib/openssh/sftp-server /usr/libexec/openssh/sftp-server /usr/lib/ssh/sftp-server; do ` +
+	`if [ -x "$p" ]; then exec sudo "$p"; fi; done; echo "sftp-server isn't found" >&2; exit 127`
+
+var (
+	ErrUploadUnsupported = errors.New("connection can't upload files")
+	ErrChecksumMismatch  = errors.New("checksum of uploaded file doesn't match")
+)
+
+// File is file uploaded to node by Upload
+type File struct {
+	Path    string
+	Content io.Reader
+	Mode    os.FileMode
+	// Owner is "user" or "user:group" of chown, file is owned by root when it is empty
+	Owner string
+}
+
+// Upload writes file to node by SFTP over ssh connection of conn. Parent directories are created. Content is
+// written to temporary file next to the path, which is moved to the path only when its SHA-256 computed on the node
+// matches the uploaded one, so the path never has partial content. Returns hex encoded SHA-256 of the content
+func Upload(conn cc.ClientConn, file File) (string, error) {
+	sshConn, ok := conn.(*sshcc.SSH)
+	if !ok {
+		return "", ErrUploadUnsupported
+	}
+
+	session, err := sshConn.C.NewSession()
+	if err != nil {
+		return "", errors.Join(cc.ErrOpenChannel, err)
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err = session.Start(sftpServerCommand); err != nil {
+		return "", errors.Join(cc.ErrUnknown, err)
+	}
+	client, err := sftp.NewClientPipe(stdout, stdin)
+	if err != nil {
+		return "", fmt.Errorf("start sftp: %w", err)
+	}
+	defer client.Close()
+
+	return upload(client, file, func(tmp string) (string, error) {
+		command := cl.NewCmd("sha256sum", tmp).Sudo().String()
+		if file.Owner != "" {
+			command = cl.NewCmd("chown", file.Owner, tmp).Sudo().String() + " && " + command
+		}
+		output, err := sshConn.Exec(command)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w: %s", command, err, strings.TrimSpace(string(output)))
+		}
+		checksum, _, _ := strings.Cut(string(output), " ")
+		return checksum, nil
+	})
+}
+
+// upload writes file to temporary path by client and moves it to the path when checksum returned by verify
+// for temporary path is equal to checksum of the content. Temporary file gets mode of the file before content
+// is written and it is removed when upload fails
+func upload(client *sftp.Client, file File, verify func(tmp string) (string, error)) (checksum string, err error) {
+	dir := path.Dir(file.Path)
+	if err = client.MkdirAll(dir); err != nil {
+		return "", fmt.Errorf("create %s: %w", dir, err)
+	}
+	tmp := path.Join(dir, "."+path.Base(file.Path)+".upload")
+	// file left by failed upload may have other mode and owner, so temporary file is always created anew
+	_ = client.Remove(tmp)
+	f, err := client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", fmt.Errorf("create %s: %w", tmp, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = client.Remove(tmp)
+		}
+	}()
+
+	h := sha256.New()
+	err = f.Chmod(file.Mode)
+	if err == nil {
+		_, err = f.ReadFrom(io.TeeReader(file.Content, h))
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("write %s: %w", tmp, err)
+	}
+
+	checksum = hex.EncodeToString(h.Sum(nil))
+	remote, err := verify(tmp)
+	if err != nil {
+		return "", err
+	}
+	if remote != checksum {
+		return "", fmt.Errorf("%w: %s has %s, uploaded %s", ErrChecksumMismatch, file.Path, remote, checksum)
+	}
+	if err = client.PosixRename(tmp, file.Path); err != nil {
+		return "", fmt.Errorf("move %s to %s: %w", tmp, file.Path, err)
+	}
+	return checksum, nil
+}
@@ -0,0 +1,190 @@
+package remote_exec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// sftpClient returns client of sftp server serving local filesystem over pipes
+func sftpClient(t *testing.T) *sftp.Client {
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve()
+	}()
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// server closes pipe read by client first, otherwise client waits for its response reader
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+	return client
+}
+
+// localChecksum is verify of upload computing checksum of the local file
+func localChecksum(tmp string) (string, error) {
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func TestUpload(t *testing.T) {
+	client := sftpClient(t)
+	dir := t.TempDir()
+	content := "apiVersion: kubeadm.k8s.io/v1beta3\nkind: InitConfiguration\n"
+	tests := []struct {
+		name string
+		path string
+		mode os.FileMode
+	}{
+		{name: "new directory", path: filepath.Join(dir, "etc", "kubernetes", "kubeadm.yaml"), mode: 0600},
+		{name: "existing file", path: filepath.Join(dir, "etc", "kubernetes", "kubeadm.yaml"), mode: 0644},
+	}
+	for _, tt := range tests {
+		checksum, err := upload(client, File{Path: tt.path, Content: strings.NewReader(content), Mode: tt.mode}, localChecksum)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		h := sha256.Sum256([]byte(content))
+		if want := hex.EncodeToString(h[:]); checksum != want {
+			t.Errorf("%s: checksum = %s, want %s", tt.name, checksum, want)
+		}
+		data, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("%s: file has %q, want %q", tt.name, data, content)
+		}
+		info, err := os.Stat(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if info.Mode().Perm() != tt.mode {
+			t.Errorf("%s: mode = %04o, want %04o", tt.name, info.Mode().Perm(), tt.mode)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "etc", "kubernetes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d files, want only uploaded one", len(entries))
+	}
+}
+
+func TestUploadChecksumMismatch(t *testing.T) {
+	client := sftpClient(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kubeadm.yaml")
+	if err := os.WriteFile(path, []byte("previous"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := upload(client, File{Path: path, Content: strings.NewReader("truncated"), Mode: 0600}, func(string) (string, error) {
+		return "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", nil
+	})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("error = %v, want %v", err, ErrChecksumMismatch)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "previous" {
+		t.Errorf("file has %q, want previous content to be kept", data)
+	}
+	if _, err = os.Stat(filepath.Join(dir, ".kubeadm.yaml.upload")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file isn't removed: %v", err)
+	}
+}
+
+// modeCheckReader checks mode of the file when content is read first time, i.e. before it is written to the file
+type modeCheckReader struct {
+	io.Reader
+	t       *testing.T
+	path    string
+	mode    os.FileMode
+	checked bool
+}
+
+func (r *modeCheckReader) Read(p []byte) (int, error) {
+	if !r.checked {
+		r.checked = true
+		info, err := os.Stat(r.path)
+		if err != nil {
+			r.t.Errorf("temporary file: %v", err)
+		} else if info.Mode().Perm() != r.mode {
+			r.t.Errorf("temporary file mode = %04o before content is written, want %04o", info.Mode().Perm(), r.mode)
+		}
+	}
+	return r.Reader.Read(p)
+}
+
+func TestUploadTemporaryFile(t *testing.T) {
+	client := sftpClient(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kubeadm.yaml")
+	tmp := filepath.Join(dir, ".kubeadm.yaml.upload")
+	// temporary file left by failed upload is readable by everyone
+	if err := os.WriteFile(tmp, []byte("stale"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	content := &modeCheckReader{Reader: strings.NewReader("token: secret\n"), t: t, path: tmp, mode: 0600}
+	if _, err := upload(client, File{Path: path, Content: content, Mode: 0600}, localChecksum); err != nil {
+		t.Fatal(err)
+	}
+	if !content.checked {
+		t.Error("content wasn't read")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "token: secret\n" {
+		t.Errorf("file has %q, want uploaded content", data)
+	}
+}
+
+func TestUploadRenameFailed(t *testing.T) {
+	client := sftpClient(t)
+	dir := t.TempDir()
+	// directory in place of the file can't be replaced by rename
+	path := filepath.Join(dir, "kubeadm.yaml")
+	if err := os.MkdirAll(filepath.Join(path, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := upload(client, File{Path: path, Content: strings.NewReader("token: secret\n"), Mode: 0600}, localChecksum); err == nil {
+		t.Fatal("upload over non-empty directory succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".kubeadm.yaml.upload")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file isn't removed: %v", err)
+	}
+}